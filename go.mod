@@ -1,10 +1,20 @@
 module github.com/lucastomic/msBaseProj
 
-go 1.21.5
+go 1.24
 
 require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/andybalholm/brotli v1.1.1
+	github.com/fxamacker/cbor/v2 v2.7.0
+	github.com/klauspost/compress v1.17.11
 	github.com/rs/cors v1.11.0
 	github.com/sirupsen/logrus v1.9.3
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+)
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/contextypes"
+)
+
+// Entry is a cached HTTP response.
+type Entry struct {
+	Status     int         // Status is the status code of the response.
+	Header     http.Header // Header holds the response headers.
+	Body       []byte      // Body is the response body, as sent to the client.
+	StoredAt   time.Time   // StoredAt is when the response was generated.
+	Expires    time.Time   // Expires is when the entry stops being fresh.
+	StaleUntil time.Time   // StaleUntil is until when the entry can be served stale while it's revalidated.
+}
+
+// Fresh reports whether the entry can be served as is at the given time.
+func (e Entry) Fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// Usable reports whether the entry can be served at the given time, either fresh or stale while revalidating.
+func (e Entry) Usable(now time.Time) bool {
+	return now.Before(e.Expires) || now.Before(e.StaleUntil)
+}
+
+// size approximates the memory held by the entry.
+func (e Entry) size() int {
+	size := len(e.Body)
+	for key, values := range e.Header {
+		size += len(key)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	return size
+}
+
+// Store is where the cache middleware keeps responses. Implementations must be safe for concurrent use,
+// so shared stores, like one backed by Redis, can be plugged in instead of the in-memory one.
+type Store interface {
+	// Get returns the entry stored under key, if there is one.
+	Get(key string) (Entry, bool)
+
+	// Set stores the entry under key, replacing any previous one.
+	Set(key string, entry Entry)
+
+	// DeletePrefix removes every entry whose key starts with prefix and returns how many were removed.
+	DeletePrefix(prefix string) int
+
+	// Generation returns a counter increased by every DeletePrefix. Writers take it before generating
+	// an entry, so they can tell whether the cached responses were invalidated meanwhile.
+	Generation() uint64
+
+	// SetIfGeneration stores the entry under key, like Set, unless the generation changed since it was
+	// the given one. It reports whether the entry was stored.
+	SetIfGeneration(key string, entry Entry, generation uint64) bool
+}
+
+// WithStore returns a copy of ctx carrying the given store, so handlers can invalidate cached responses.
+func WithStore(ctx context.Context, store Store) context.Context {
+	return context.WithValue(ctx, contextypes.ContextCacheKey{}, store)
+}
+
+// Invalidate removes the cached responses whose key starts with prefix from the store carried by ctx.
+// Keys start with the method and the path, so handlers modifying a resource can invalidate every cached
+// read of it, e.g. Invalidate(ctx, "GET /api/boats") after creating a boat.
+// It does nothing if ctx carries no store.
+func Invalidate(ctx context.Context, prefix string) int {
+	store, ok := ctx.Value(contextypes.ContextCacheKey{}).(Store)
+	if !ok {
+		return 0
+	}
+	return store.DeletePrefix(prefix)
+}
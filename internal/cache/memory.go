@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store which evicts the least recently used entries once it holds
+// more than its maximum number of entries or bytes.
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int                      // maxEntries is the maximum number of entries. Zero means unbounded.
+	maxBytes   int                      // maxBytes is the maximum size of all the entries. Zero means unbounded.
+	size       int                      // size is the current size of all the entries.
+	lru        *list.List               // lru holds the entries from the most to the least recently used.
+	items      map[string]*list.Element // items indexes the elements of lru by key.
+	generation uint64                   // generation is increased by every DeletePrefix.
+}
+
+// memoryItem is the value stored in every element of MemoryStore.lru.
+type memoryItem struct {
+	key   string
+	entry Entry
+	size  int
+}
+
+// NewMemoryStore creates a MemoryStore bounded to maxEntries entries and maxBytes bytes.
+// A zero bound disables it.
+func NewMemoryStore(maxEntries int, maxBytes int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+// Get implements Store. Entries which can't be served anymore, not even stale, are removed.
+func (m *MemoryStore) Get(key string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	item := elem.Value.(*memoryItem)
+	if !item.entry.Usable(time.Now()) {
+		m.remove(elem)
+		return Entry{}, false
+	}
+	m.lru.MoveToFront(elem)
+	return item.entry, true
+}
+
+// Set implements Store. Entries larger than the whole store are not stored.
+func (m *MemoryStore) Set(key string, entry Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(key, entry)
+}
+
+// SetIfGeneration implements Store.
+func (m *MemoryStore) SetIfGeneration(key string, entry Entry, generation uint64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.generation != generation {
+		return false
+	}
+	m.set(key, entry)
+	return true
+}
+
+// Generation implements Store.
+func (m *MemoryStore) Generation() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.generation
+}
+
+// set stores the entry under key, evicting the least recently used entries if the store is full.
+// Entries larger than the whole store are not stored. The caller must hold the lock.
+func (m *MemoryStore) set(key string, entry Entry) {
+	item := &memoryItem{key, entry, len(key) + entry.size()}
+	if m.maxBytes > 0 && item.size > m.maxBytes {
+		return
+	}
+	if elem, ok := m.items[key]; ok {
+		m.remove(elem)
+	}
+	m.items[key] = m.lru.PushFront(item)
+	m.size += item.size
+	for (m.maxEntries > 0 && m.lru.Len() > m.maxEntries) || (m.maxBytes > 0 && m.size > m.maxBytes) {
+		m.remove(m.lru.Back())
+	}
+}
+
+// DeletePrefix implements Store.
+func (m *MemoryStore) DeletePrefix(prefix string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generation++
+	removed := 0
+	for key, elem := range m.items {
+		if strings.HasPrefix(key, prefix) {
+			m.remove(elem)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len returns the number of entries in the store.
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// remove deletes the element from the store. The caller must hold the lock.
+func (m *MemoryStore) remove(elem *list.Element) {
+	item := m.lru.Remove(elem).(*memoryItem)
+	delete(m.items, item.key)
+	m.size -= item.size
+}
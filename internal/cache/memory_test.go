@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func freshEntry(body string) Entry {
+	return Entry{Status: 200, Body: []byte(body), Expires: time.Now().Add(time.Minute)}
+}
+
+// TestMemoryStoreEviction checks the least recently used entry is evicted when the store is full.
+func TestMemoryStoreEviction(t *testing.T) {
+	store := NewMemoryStore(2, 0)
+	store.Set("a", freshEntry("a"))
+	store.Set("b", freshEntry("b"))
+	store.Get("a")
+	store.Set("c", freshEntry("c"))
+
+	if _, ok := store.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Errorf("Expected a to be kept")
+	}
+	if store.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %v", store.Len())
+	}
+}
+
+// TestMemoryStoreBytes checks entries are evicted to keep the store under its size bound.
+func TestMemoryStoreBytes(t *testing.T) {
+	store := NewMemoryStore(0, 20)
+	store.Set("a", freshEntry("0123456789"))
+	store.Set("b", freshEntry("0123456789"))
+	if store.Len() != 1 {
+		t.Errorf("Expected 1 entry, got %v", store.Len())
+	}
+	store.Set("c", freshEntry("this body is larger than the whole store"))
+	if _, ok := store.Get("c"); ok {
+		t.Errorf("Expected an entry larger than the store not to be stored")
+	}
+}
+
+// TestMemoryStoreExpiration checks entries past their stale window are not returned.
+func TestMemoryStoreExpiration(t *testing.T) {
+	store := NewMemoryStore(0, 0)
+	entry := freshEntry("a")
+	entry.Expires = time.Now().Add(-time.Minute)
+	entry.StaleUntil = time.Now().Add(time.Minute)
+	store.Set("stale", entry)
+	entry.StaleUntil = time.Now().Add(-time.Second)
+	store.Set("expired", entry)
+
+	if _, ok := store.Get("stale"); !ok {
+		t.Errorf("Expected a stale entry to be returned")
+	}
+	if _, ok := store.Get("expired"); ok {
+		t.Errorf("Expected an expired entry not to be returned")
+	}
+}
+
+// TestMemoryStoreDeletePrefix checks every entry under a prefix is removed.
+func TestMemoryStoreDeletePrefix(t *testing.T) {
+	store := NewMemoryStore(0, 0)
+	store.Set("GET /api/boats|", freshEntry("a"))
+	store.Set("GET /api/boats/1|", freshEntry("b"))
+	store.Set("GET /api/ports|", freshEntry("c"))
+	if removed := store.DeletePrefix("GET /api/boats"); removed != 2 {
+		t.Errorf("Expected 2 entries removed, got %v", removed)
+	}
+	if store.Len() != 1 {
+		t.Errorf("Expected 1 entry left, got %v", store.Len())
+	}
+}
+
+// TestMemoryStoreSetIfGeneration checks entries aren't stored once the store was invalidated since the given generation.
+func TestMemoryStoreSetIfGeneration(t *testing.T) {
+	store := NewMemoryStore(0, 0)
+	generation := store.Generation()
+	if !store.SetIfGeneration("a", freshEntry("a"), generation) {
+		t.Errorf("Expected the entry to be stored")
+	}
+	store.DeletePrefix("b")
+	if store.SetIfGeneration("c", freshEntry("c"), generation) {
+		t.Errorf("Expected the entry not to be stored after an invalidation")
+	}
+	if _, ok := store.Get("c"); ok {
+		t.Errorf("Expected c not to be in the store")
+	}
+}
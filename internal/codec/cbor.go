@@ -0,0 +1,32 @@
+package codec
+
+import (
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBOR is the Codec for application/cbor (RFC 8949). Struct fields are named after their json tags
+// when they don't declare a cbor one.
+type CBOR struct{}
+
+// cborDecMode rejects unknown fields like the JSON codec does.
+var cborDecMode, _ = cbor.DecOptions{ExtraReturnErrors: cbor.ExtraDecErrorUnknownField}.DecMode()
+
+// MediaTypes implements Codec.
+func (CBOR) MediaTypes() []string {
+	return []string{"application/cbor"}
+}
+
+// Encode implements Codec.
+func (CBOR) Encode(w io.Writer, v any) error {
+	return cbor.NewEncoder(w).Encode(v)
+}
+
+// Decode implements Codec.
+func (CBOR) Decode(r io.Reader, v any) error {
+	if err := cborDecMode.NewDecoder(r).Decode(v); err != nil {
+		return bodyError(err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package codec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lucastomic/msBaseProj/internal/contextypes"
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// Codec encodes response contents and decodes request bodies for a set of media types.
+type Codec interface {
+	// MediaTypes returns the media types handled by the codec. The first one is the canonical type,
+	// used as the Content-Type of the encoded responses.
+	MediaTypes() []string
+
+	// Encode writes the encoding of v to w.
+	Encode(w io.Writer, v any) error
+
+	// Decode reads an encoded value from r and stores it in the value pointed to by v.
+	Decode(r io.Reader, v any) error
+}
+
+// Selective is an optional interface for codecs which can only encode some kinds of values,
+// like CSV which only represents collections. Codecs which don't implement it are assumed to
+// encode any value.
+type Selective interface {
+	// CanEncode reports whether the codec is able to encode v.
+	CanEncode(v any) bool
+}
+
+// Registry holds the codecs available for content negotiation. The first registered codec is
+// the default one, used when the client expresses no preference. It is safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	codecs []Codec
+}
+
+// NewRegistry creates a Registry with the given codecs, in order of preference.
+func NewRegistry(codecs ...Codec) *Registry {
+	return &Registry{codecs: codecs}
+}
+
+// NewDefaultRegistry creates a Registry with every codec shipped in this package:
+// JSON (the default), XML, YAML, MessagePack, CBOR and CSV.
+func NewDefaultRegistry() *Registry {
+	return NewRegistry(JSON{}, XML{}, YAML{}, MessagePack{}, CBOR{}, CSV{})
+}
+
+// Register adds a codec to the registry. If a codec with the same canonical media type is already
+// registered it is replaced in place, keeping its preference.
+func (r *Registry) Register(c Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, registered := range r.codecs {
+		if strings.EqualFold(registered.MediaTypes()[0], c.MediaTypes()[0]) {
+			r.codecs[i] = c
+			return
+		}
+	}
+	r.codecs = append(r.codecs, c)
+}
+
+// Default returns the codec used when the client has no preference, or nil if the registry is empty.
+func (r *Registry) Default() Codec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.codecs) == 0 {
+		return nil
+	}
+	return r.codecs[0]
+}
+
+// MediaTypes returns every media type handled by the registered codecs.
+func (r *Registry) MediaTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var mediaTypes []string
+	for _, c := range r.codecs {
+		mediaTypes = append(mediaTypes, c.MediaTypes()...)
+	}
+	return mediaTypes
+}
+
+// ForContentType returns the codec registered for the media type of the given Content-Type header.
+// Parameters such as charset are ignored.
+func (r *Registry) ForContentType(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.codecs {
+		for _, mt := range c.MediaTypes() {
+			if strings.EqualFold(mt, mediaType) {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Negotiate selects the codec which best satisfies the given Accept header for encoding v,
+// together with the media type to announce in the Content-Type header.
+// Media ranges are weighed by their q-values and, among equally weighted codecs, registration
+// order decides. An empty Accept header selects the default codec. The boolean is false when no
+// codec is acceptable, in which case the server should answer with 406 Not Acceptable.
+func (r *Registry) Negotiate(accept string, v any) (Codec, string, bool) {
+	ranges := ParseAccept(accept)
+	if len(ranges) == 0 {
+		ranges = []MediaRange{{Type: "*/*", Q: 1}}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var best Codec
+	var bestType string
+	bestQ := 0.0
+	for _, c := range r.codecs {
+		if s, ok := c.(Selective); ok && !s.CanEncode(v) {
+			continue
+		}
+		for _, mt := range c.MediaTypes() {
+			q := quality(ranges, mt)
+			if q > bestQ {
+				best, bestType, bestQ = c, mt, q
+			}
+		}
+	}
+	return best, bestType, best != nil
+}
+
+// MediaRange is a single entry of an Accept header.
+type MediaRange struct {
+	Type string  // Type is the media range, like application/json, text/* or */*.
+	Q    float64 // Q is the weight of the range, between 0 and 1.
+}
+
+// ParseAccept parses an Accept header into its media ranges, sorted from the most to the least
+// preferred one. Entries which can't be parsed are skipped.
+func ParseAccept(accept string) []MediaRange {
+	var ranges []MediaRange
+	for _, part := range strings.Split(accept, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, MediaRange{mediaType, q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Q > ranges[j].Q
+	})
+	return ranges
+}
+
+// Quality returns the weight the given Accept header assigns to mediaType, between 0 and 1.
+// An empty Accept header accepts any media type.
+func Quality(accept string, mediaType string) float64 {
+	ranges := ParseAccept(accept)
+	if len(ranges) == 0 {
+		return 1
+	}
+	return quality(ranges, mediaType)
+}
+
+// MatchMediaType reports whether mediaType matches pattern. The pattern may be "*/*" or use
+// a wildcard subtype like "text/*". The comparison is case-insensitive.
+func MatchMediaType(pattern string, mediaType string) bool {
+	pattern = strings.ToLower(pattern)
+	mediaType = strings.ToLower(mediaType)
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return false
+}
+
+// quality returns the weight the given ranges assign to mediaType. As mandated by RFC 9110 the most
+// specific matching range wins, so "text/csv;q=0" excludes CSV even when "*/*" is accepted.
+func quality(ranges []MediaRange, mediaType string) float64 {
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		if !MatchMediaType(mr.Type, mediaType) {
+			continue
+		}
+		s := 2
+		if mr.Type == "*/*" {
+			s = 0
+		} else if strings.HasSuffix(mr.Type, "/*") {
+			s = 1
+		}
+		if s > specificity {
+			q, specificity = mr.Q, s
+		}
+	}
+	return q
+}
+
+// bodyError maps an error returned while reading or decoding a request body to an errs.I18nError,
+// wrapping errs.ErrPayloadTooLarge when an http.MaxBytesReader limit was hit and errs.ErrInvalidInput otherwise.
+func bodyError(err error) error {
+	maxBytesErr := &http.MaxBytesError{}
+	if errors.As(err, &maxBytesErr) {
+		return errs.NewI18NError("request body too large: %w", errs.ErrPayloadTooLarge, "payloadtoolarge")
+	}
+	return errs.NewI18NError("malformed request body: %w", errors.Join(errs.ErrInvalidInput, err), "malformedbody")
+}
+
+// WithRegistry returns a copy of ctx carrying the given registry, so request handlers decode
+// bodies with the same codecs the server negotiates responses with.
+func WithRegistry(ctx context.Context, r *Registry) context.Context {
+	return context.WithValue(ctx, contextypes.ContextCodecsKey{}, r)
+}
+
+// RegistryFromCtx returns the registry stored in ctx by WithRegistry, or a default registry if there is none.
+func RegistryFromCtx(ctx context.Context) *Registry {
+	if r, ok := ctx.Value(contextypes.ContextCodecsKey{}).(*Registry); ok {
+		return r
+	}
+	return defaultRegistry
+}
+
+// defaultRegistry is used by RegistryFromCtx when no registry was stored in the context.
+var defaultRegistry = NewDefaultRegistry()
@@ -0,0 +1,97 @@
+package codec
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type boat struct {
+	ID   int    `json:"id" yaml:"id" xml:"id"`
+	Name string `json:"name" yaml:"name" xml:"name"`
+}
+
+// TestNegotiate checks the codec is selected following the q-values of the Accept header,
+// the most specific range wins and selective codecs are skipped for contents they can't encode.
+func TestNegotiate(t *testing.T) {
+	registry := NewDefaultRegistry()
+	list := []boat{{1, "a"}}
+	single := boat{1, "a"}
+	cases := []struct {
+		accept   string
+		content  any
+		expected string
+	}{
+		{"", single, "application/json"},
+		{"*/*", single, "application/json"},
+		{"application/xml;q=0.5, application/yaml", single, "application/yaml"},
+		{"text/xml", single, "text/xml"},
+		{"text/csv;q=0.9, application/json;q=0.1", list, "text/csv"},
+		{"text/csv;q=0.9, application/json;q=0.1", single, "application/json"},
+		{"*/*, application/json;q=0", single, "application/xml"},
+	}
+	for _, c := range cases {
+		_, mediaType, ok := registry.Negotiate(c.accept, c.content)
+		if !ok || mediaType != c.expected {
+			t.Errorf("Expected %s for Accept %q, got %s (%v)", c.expected, c.accept, mediaType, ok)
+		}
+	}
+}
+
+// TestNegotiateNotAcceptable checks no codec is selected when nothing matches the Accept header.
+func TestNegotiateNotAcceptable(t *testing.T) {
+	registry := NewDefaultRegistry()
+	if _, _, ok := registry.Negotiate("image/png", boat{}); ok {
+		t.Errorf("Expected no acceptable codec for image/png")
+	}
+	if _, _, ok := registry.Negotiate("text/csv", boat{}); ok {
+		t.Errorf("Expected CSV not to be acceptable for a single value")
+	}
+}
+
+// TestRoundTrip checks every built-in codec decodes what it encodes.
+func TestRoundTrip(t *testing.T) {
+	in := []boat{{1, "sea"}, {2, "lake, river"}}
+	for _, c := range []Codec{JSON{}, XML{}, YAML{}, MessagePack{}, CBOR{}, CSV{}} {
+		var buf bytes.Buffer
+		var value any = in
+		var out any = &[]boat{}
+		if _, ok := c.(XML); ok {
+			value, out = in[0], &boat{}
+		}
+		if err := c.Encode(&buf, value); err != nil {
+			t.Errorf("%s: unexpected encoding error: %v", c.MediaTypes()[0], err)
+			continue
+		}
+		if err := c.Decode(&buf, out); err != nil {
+			t.Errorf("%s: unexpected decoding error: %v", c.MediaTypes()[0], err)
+			continue
+		}
+		if !reflect.DeepEqual(reflect.ValueOf(out).Elem().Interface(), value) {
+			t.Errorf("%s: expected %v, got %v", c.MediaTypes()[0], value, out)
+		}
+	}
+}
+
+// TestXMLMap checks maps with string keys, like error contents, can be encoded as XML.
+func TestXMLMap(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (XML{}).Encode(&buf, map[string]string{"error": "not found"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(buf.String(), "<response><error>not found</error></response>") {
+		t.Errorf("Unexpected XML %s", buf.String())
+	}
+}
+
+// TestCSVEncode checks the header of a slice of structs is taken from the json tags.
+func TestCSVEncode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (CSV{}).Encode(&buf, []boat{{1, "sea"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if buf.String() != "id,name\n1,sea\n" {
+		t.Errorf("Unexpected CSV %q", buf.String())
+	}
+}
@@ -0,0 +1,297 @@
+package codec
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errCSVUnsupported is returned when a value can't be represented as CSV.
+var errCSVUnsupported = errors.New("csv: value is not a collection")
+
+// CSV is the Codec for text/csv. It only encodes collections, so content negotiation skips it for
+// any other content:
+//   - slices of structs produce a header row with the fields' json names and a row per element.
+//   - slices of maps with string keys produce a header row with every key, sorted, and a row per element.
+//   - slices of slices produce a row per element and no header.
+//   - slices of scalars produce a single "value" column.
+//
+// Values which aren't scalars are written as JSON. Decoding supports pointers to slices of structs,
+// of maps with string values and of string slices.
+type CSV struct{}
+
+// MediaTypes implements Codec.
+func (CSV) MediaTypes() []string {
+	return []string{"text/csv"}
+}
+
+// CanEncode implements Selective. Only slices and arrays with a static element type, except byte slices,
+// can be encoded.
+func (CSV) CanEncode(v any) bool {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false
+	}
+	kind := indirectType(rv.Type().Elem()).Kind()
+	return kind != reflect.Uint8 && kind != reflect.Interface
+}
+
+// Encode implements Codec.
+func (c CSV) Encode(w io.Writer, v any) error {
+	if !c.CanEncode(v) {
+		return errCSVUnsupported
+	}
+	rv := reflect.ValueOf(v)
+	header, rows := csvRecords(rv)
+	cw := csv.NewWriter(w)
+	if header != nil {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Decode implements Codec.
+func (CSV) Decode(r io.Reader, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("csv: can't decode into %T", v)
+	}
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return bodyError(err)
+	}
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	if elemType.Kind() == reflect.Slice && elemType.Elem().Kind() == reflect.String {
+		for _, record := range records {
+			slice.Set(reflect.Append(slice, reflect.ValueOf(record).Convert(elemType)))
+		}
+		return nil
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	header, records := records[0], records[1:]
+	for _, record := range records {
+		elem := reflect.New(elemType).Elem()
+		if err := csvDecodeRecord(elem, header, record); err != nil {
+			return bodyError(err)
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+	return nil
+}
+
+// csvRecords converts the elements of rv into CSV records and their header, which is nil when
+// the elements have no named fields.
+func csvRecords(rv reflect.Value) ([]string, [][]string) {
+	elemType := indirectType(rv.Type().Elem())
+	var header []string
+	switch {
+	case elemType.Kind() == reflect.Struct:
+		for _, f := range csvFields(elemType) {
+			header = append(header, f.name)
+		}
+	case elemType.Kind() == reflect.Map && elemType.Key().Kind() == reflect.String:
+		header = csvMapKeys(rv)
+	case elemType.Kind() == reflect.Slice || elemType.Kind() == reflect.Array:
+	default:
+		header = []string{"value"}
+	}
+
+	rows := make([][]string, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		elem := reflect.Indirect(rv.Index(i))
+		var row []string
+		switch {
+		case !elem.IsValid():
+			row = make([]string, len(header))
+		case elemType.Kind() == reflect.Struct:
+			for _, f := range csvFields(elemType) {
+				row = append(row, csvFormat(elem.FieldByIndex(f.index)))
+			}
+		case elemType.Kind() == reflect.Map:
+			for _, key := range header {
+				row = append(row, csvFormat(elem.MapIndex(reflect.ValueOf(key).Convert(elemType.Key()))))
+			}
+		case elemType.Kind() == reflect.Slice || elemType.Kind() == reflect.Array:
+			for j := 0; j < elem.Len(); j++ {
+				row = append(row, csvFormat(elem.Index(j)))
+			}
+		default:
+			row = []string{csvFormat(elem)}
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// csvField is an exported struct field together with the column name it's written under.
+type csvField struct {
+	name  string
+	index []int
+}
+
+// csvFields returns the exported fields of t named after their json tags. Fields tagged "-" are skipped.
+func csvFields(t reflect.Type) []csvField {
+	var fields []csvField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		fields = append(fields, csvField{name, f.Index})
+	}
+	return fields
+}
+
+// csvMapKeys returns the union of the keys of every map in rv, sorted.
+func csvMapKeys(rv reflect.Value) []string {
+	seen := map[string]bool{}
+	for i := 0; i < rv.Len(); i++ {
+		elem := reflect.Indirect(rv.Index(i))
+		if !elem.IsValid() {
+			continue
+		}
+		for _, key := range elem.MapKeys() {
+			seen[key.String()] = true
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// csvFormat formats a single value as a CSV cell. Missing values produce an empty cell and
+// values which aren't scalars are written as JSON.
+func csvFormat(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface())
+	default:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+}
+
+// csvDecodeRecord stores the cells of record into elem, which is a struct or a map with string values,
+// using header to know which field or key each cell belongs to.
+func csvDecodeRecord(elem reflect.Value, header []string, record []string) error {
+	if elem.Kind() == reflect.Map {
+		if elem.Type().Key().Kind() != reflect.String || elem.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("csv: can't decode into %s", elem.Type())
+		}
+		elem.Set(reflect.MakeMap(elem.Type()))
+		for i, name := range header {
+			if i < len(record) {
+				elem.SetMapIndex(reflect.ValueOf(name), reflect.ValueOf(record[i]))
+			}
+		}
+		return nil
+	}
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("csv: can't decode into %s", elem.Type())
+	}
+	fields := map[string][]int{}
+	for _, f := range csvFields(elem.Type()) {
+		fields[f.name] = f.index
+	}
+	for i, name := range header {
+		index, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("csv: unknown column %q", name)
+		}
+		if i >= len(record) {
+			continue
+		}
+		if err := csvSet(elem.FieldByIndex(index), record[i]); err != nil {
+			return fmt.Errorf("csv: column %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// csvSet parses cell into the scalar field v.
+func csvSet(v reflect.Value, cell string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(cell)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(cell, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(cell, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(cell, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// indirectType returns the type pointed to by t if it's a pointer, or t otherwise.
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,109 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// DefaultMaxDepth is the maximum nesting of objects and arrays accepted by DecodeJSON
+// when no explicit depth is given.
+const DefaultMaxDepth = 32
+
+// JSON is the Codec for application/json. Decoding is strict, see DecodeJSON.
+type JSON struct {
+	// MaxDepth is the maximum nesting accepted when decoding. Zero uses DefaultMaxDepth.
+	MaxDepth int
+}
+
+// MediaTypes implements Codec.
+func (JSON) MediaTypes() []string {
+	return []string{"application/json"}
+}
+
+// Encode implements Codec. The output is terminated by a newline.
+func (JSON) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// Decode implements Codec by calling DecodeJSON.
+func (j JSON) Decode(r io.Reader, v any) error {
+	return DecodeJSON(r, v, j.MaxDepth)
+}
+
+// DecodeJSON strictly decodes a single JSON document read from r into dst.
+// Unlike a plain json.Decoder it rejects unknown fields, documents nested deeper than maxDepth
+// (DefaultMaxDepth when maxDepth <= 0) and any data following the first document.
+// Every failure is returned as an errs.I18nError, wrapping errs.ErrPayloadTooLarge when the body
+// exceeded an http.MaxBytesReader limit and errs.ErrInvalidInput otherwise.
+func DecodeJSON(r io.Reader, dst any, maxDepth int) error {
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return bodyError(err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return errs.NewI18NError("request body is empty: %w", errs.ErrInvalidInput, "emptybody")
+	}
+	if err := checkDepth(body, maxDepth); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errs.NewI18NError("request body has trailing data: %w", errs.ErrInvalidInput, "trailingdata")
+	}
+	return nil
+}
+
+// decodeError maps an error returned by json.Decoder.Decode to an errs.I18nError.
+// Unknown fields get their own translation code so clients can tell them apart from syntax errors.
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return errs.NewI18NError("malformed request body: %w", errors.Join(errs.ErrInvalidInput, err), "malformedbody")
+	case errors.As(err, &typeErr):
+		return errs.NewI18NError("invalid field type: %w", errors.Join(errs.ErrInvalidInput, err), "invalidfieldtype")
+	case strings.HasPrefix(err.Error(), "json: unknown field"):
+		return errs.NewI18NError("unknown field: %w", errors.Join(errs.ErrInvalidInput, err), "unknownfield")
+	default:
+		return errs.NewI18NError("invalid request body: %w", errors.Join(errs.ErrInvalidInput, err), "malformedbody")
+	}
+}
+
+// checkDepth walks the tokens of the first JSON document in body and fails as soon as
+// the nesting of objects and arrays goes beyond maxDepth.
+func checkDepth(body []byte, maxDepth int) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return decodeError(err)
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > maxDepth {
+				return errs.NewI18NError("request body nested too deep: %w", errs.ErrInvalidInput, "bodytoodeep")
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
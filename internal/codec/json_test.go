@@ -0,0 +1,66 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+type decodeTarget struct {
+	Name  string `json:"name"`
+	Items []any  `json:"items"`
+}
+
+// TestDecodeJSON checks that a well formed document is decoded into the target.
+func TestDecodeJSON(t *testing.T) {
+	var dst decodeTarget
+	if err := DecodeJSON(strings.NewReader(`{"name":"boat","items":[1,2]}`), &dst, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if dst.Name != "boat" || len(dst.Items) != 2 {
+		t.Errorf("Unexpected decoded value %+v", dst)
+	}
+}
+
+// TestDecodeJSONRejections checks every strict decoding rule returns a translatable ErrInvalidInput
+// with its own code.
+func TestDecodeJSONRejections(t *testing.T) {
+	cases := map[string]string{
+		"":                              "emptybody",
+		`{"name":`:                      "malformedbody",
+		`{"name":"boat","color":"red"}`: "unknownfield",
+		`{"name":1}`:                    "invalidfieldtype",
+		`{"name":"boat"} {"name":"x"}`:  "trailingdata",
+		`{"items":[[[[1]]]]}`:           "bodytoodeep",
+	}
+	for body, code := range cases {
+		var dst decodeTarget
+		err := DecodeJSON(strings.NewReader(body), &dst, 4)
+		i18n := &errs.I18nError{}
+		if !errors.As(err, i18n) {
+			t.Errorf("Expected I18nError for %q, got %v", body, err)
+			continue
+		}
+		if i18n.Code != code {
+			t.Errorf("Expected code %q for %q, got %q", code, body, i18n.Code)
+		}
+		if !errors.Is(err, errs.ErrInvalidInput) {
+			t.Errorf("Expected ErrInvalidInput for %q, got %v", body, err)
+		}
+	}
+}
+
+// TestDecodeJSONTooLarge checks that exceeding an http.MaxBytesReader limit maps to ErrPayloadTooLarge.
+func TestDecodeJSONTooLarge(t *testing.T) {
+	body := http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader(`{"name":"a very long boat name"}`)), 8)
+	var dst decodeTarget
+	err := DecodeJSON(body, &dst, 0)
+	if !errors.Is(err, errs.ErrPayloadTooLarge) {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+}
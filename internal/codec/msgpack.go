@@ -0,0 +1,35 @@
+package codec
+
+import (
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MessagePack is the Codec for application/msgpack, also accepting the application/x-msgpack and
+// application/vnd.msgpack types. Struct fields are named after their json tags so the same types
+// serve both formats.
+type MessagePack struct{}
+
+// MediaTypes implements Codec.
+func (MessagePack) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+// Encode implements Codec.
+func (MessagePack) Encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+// Decode implements Codec.
+func (MessagePack) Decode(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(true)
+	if err := dec.Decode(v); err != nil {
+		return bodyError(err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package codec
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// XML is the Codec for application/xml and text/xml.
+// Besides the values supported by encoding/xml, it encodes maps with string keys as an element
+// per key wrapped in a <response> root, so generic contents like error messages can be served as XML.
+type XML struct{}
+
+// MediaTypes implements Codec.
+func (XML) MediaTypes() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+// Encode implements Codec.
+func (XML) Encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if isStringMap(v) {
+		v = xmlMap{"response", v}
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// Decode implements Codec.
+func (XML) Decode(r io.Reader, v any) error {
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return bodyError(err)
+	}
+	return nil
+}
+
+// xmlMap marshals a map with string keys as an element named name containing one child element per key,
+// sorted by key. Nested maps are marshaled the same way.
+type xmlMap struct {
+	name  string
+	value any
+}
+
+// MarshalXML implements xml.Marshaler.
+func (m xmlMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: m.name}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(m.value)
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, key := range keys {
+		value := rv.MapIndex(key).Interface()
+		if isStringMap(value) {
+			value = xmlMap{key.String(), value}
+		}
+		elem := xml.StartElement{Name: xml.Name{Local: key.String()}}
+		if err := e.EncodeElement(value, elem); err != nil {
+			return fmt.Errorf("encoding key %s: %w", key.String(), err)
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// isStringMap reports whether v is a map whose keys are strings.
+func isStringMap(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String
+}
@@ -0,0 +1,35 @@
+package codec
+
+import (
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAML is the Codec for application/yaml, also accepting the legacy application/x-yaml and text/yaml types.
+// Field names are taken from the yaml struct tags, falling back to the lowercased field name.
+type YAML struct{}
+
+// MediaTypes implements Codec.
+func (YAML) MediaTypes() []string {
+	return []string{"application/yaml", "application/x-yaml", "text/yaml"}
+}
+
+// Encode implements Codec.
+func (YAML) Encode(w io.Writer, v any) error {
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Decode implements Codec. Unknown fields are rejected like in the JSON codec.
+func (YAML) Decode(r io.Reader, v any) error {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil {
+		return bodyError(err)
+	}
+	return nil
+}
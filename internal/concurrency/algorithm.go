@@ -0,0 +1,92 @@
+package concurrency
+
+import (
+	"math"
+	"time"
+)
+
+// Algorithm adapts a concurrency limit to the latency observed by the requests.
+// Update is called with the limiter's lock held, so implementations don't need their own synchronization
+// as long as every Limiter gets its own instance.
+type Algorithm interface {
+	// Update returns the new limit given the current one, the latency of a finished request,
+	// the number of requests in flight when it finished and whether it was dropped because of overload.
+	Update(limit int, rtt time.Duration, inflight int, dropped bool) int
+}
+
+// AIMD is an additive increase, multiplicative decrease Algorithm: the limit grows by one while requests
+// succeed within LatencyThreshold and the limit is actually being used, and shrinks by Backoff when a
+// request is dropped or too slow.
+type AIMD struct {
+	LatencyThreshold time.Duration // LatencyThreshold is the latency above which the service is considered overloaded.
+	Backoff          float64       // Backoff is the factor applied to the limit on overload, between 0 and 1.
+}
+
+// NewAIMD creates an AIMD algorithm. A zero backoff uses 0.9.
+func NewAIMD(latencyThreshold time.Duration, backoff float64) *AIMD {
+	if backoff <= 0 || backoff >= 1 {
+		backoff = 0.9
+	}
+	return &AIMD{latencyThreshold, backoff}
+}
+
+// Update implements Algorithm.
+func (a *AIMD) Update(limit int, rtt time.Duration, inflight int, dropped bool) int {
+	if dropped || (a.LatencyThreshold > 0 && rtt > a.LatencyThreshold) {
+		return int(float64(limit) * a.Backoff)
+	}
+	if inflight*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// Gradient is an Algorithm which compares the latency of every request with the lowest latency observed,
+// which represents the service without queueing. While latencies stay close to it the limit grows,
+// leaving room for a queue of √limit requests, and as they grow the limit shrinks proportionally.
+// The lowest latency slowly decays so the algorithm adapts when the service gets slower for good.
+type Gradient struct {
+	Smoothing float64 // Smoothing is how much every sample moves the limit, between 0 and 1.
+	Tolerance float64 // Tolerance is how much the latency can grow over the lowest one before the limit shrinks.
+
+	minRTT time.Duration // minRTT is the lowest latency observed, slowly decaying.
+}
+
+// NewGradient creates a Gradient algorithm. A zero smoothing uses 0.2 and a zero tolerance 1.5.
+func NewGradient(smoothing float64, tolerance float64) *Gradient {
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+	if tolerance < 1 {
+		tolerance = 1.5
+	}
+	return &Gradient{Smoothing: smoothing, Tolerance: tolerance}
+}
+
+// Update implements Algorithm.
+func (g *Gradient) Update(limit int, rtt time.Duration, inflight int, dropped bool) int {
+	if dropped {
+		return int(float64(limit) * (1 - g.Smoothing/2))
+	}
+	if rtt <= 0 {
+		return limit
+	}
+	if g.minRTT == 0 || rtt < g.minRTT {
+		g.minRTT = rtt
+	} else {
+		// Let the lowest latency drift up slowly so a lasting slowdown isn't treated as overload forever.
+		g.minRTT += (rtt - g.minRTT) / 1000
+	}
+	// Only grow the limit if it's being used, otherwise it would grow without bounds while idle.
+	if inflight*2 < limit {
+		return limit
+	}
+
+	gradient := math.Max(0.5, math.Min(1, g.Tolerance*float64(g.minRTT)/float64(rtt)))
+	newLimit := float64(limit)*gradient + math.Sqrt(float64(limit))
+	smoothed := float64(limit)*(1-g.Smoothing) + newLimit*g.Smoothing
+	if smoothed > float64(limit) {
+		return int(math.Ceil(smoothed))
+	}
+	return int(math.Floor(smoothed))
+}
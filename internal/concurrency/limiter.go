@@ -0,0 +1,219 @@
+package concurrency
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLimitExceeded is returned by Acquire when the limit is reached and the wait queue is full.
+	ErrLimitExceeded = errors.New("concurrency limit exceeded")
+	// ErrShed is returned by Acquire when a waiting request is evicted by a higher priority one.
+	ErrShed = errors.New("request shed in favour of a higher priority one")
+	// ErrQueueTimeout is returned by Acquire when a request waited longer than the queue timeout.
+	ErrQueueTimeout = errors.New("timed out waiting for a concurrency slot")
+)
+
+// Config defines a concurrency limit and the queue of the requests waiting for a slot.
+type Config struct {
+	Limit        int           // Limit is the initial limit of concurrent requests. Zero disables limiting.
+	MinLimit     int           // MinLimit is the lowest limit an adaptive Algorithm can set. One when zero.
+	MaxLimit     int           // MaxLimit is the highest limit an adaptive Algorithm can set. Limit when zero.
+	MaxQueue     int           // MaxQueue is the number of requests which can wait for a slot. Zero rejects them straight away.
+	QueueTimeout time.Duration // QueueTimeout is how long a request can wait for a slot. Unbounded, but for the request's context, when zero.
+	Algorithm    Algorithm     // Algorithm adapts the limit to the observed latency. The limit is fixed when nil.
+}
+
+// Enabled reports whether the config limits anything.
+func (c Config) Enabled() bool {
+	return c.Limit > 0
+}
+
+// Limiter bounds the number of requests in flight. Requests over the limit wait in a queue, where higher
+// priority ones are served first and, when it's full, evict lower priority ones.
+type Limiter struct {
+	mu       sync.Mutex
+	config   Config
+	limit    int       // limit is the current limit.
+	inflight int       // inflight is the number of requests holding a slot.
+	queue    waitQueue // queue holds the requests waiting for a slot.
+	seq      uint64    // seq orders the waiting requests of the same priority.
+}
+
+// NewLimiter creates a Limiter with the given config.
+func NewLimiter(config Config) *Limiter {
+	if config.MinLimit <= 0 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit <= 0 {
+		config.MaxLimit = config.Limit
+	}
+	return &Limiter{config: config, limit: config.Limit}
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// InFlight returns the number of requests holding a slot.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+// Acquire takes a slot for a request with the given priority, waiting in the queue if the limit is reached.
+// The returned Token must be released when the request finishes. It fails with ErrLimitExceeded, ErrShed,
+// ErrQueueTimeout or the context's error when no slot could be taken.
+func (l *Limiter) Acquire(ctx context.Context, priority int) (*Token, error) {
+	l.mu.Lock()
+	if l.inflight < l.limit && l.queue.Len() == 0 {
+		l.inflight++
+		l.mu.Unlock()
+		return l.newToken(), nil
+	}
+	if l.queue.Len() >= l.config.MaxQueue {
+		lowest := l.queue.lowest()
+		if lowest == nil || lowest.priority >= priority {
+			l.mu.Unlock()
+			return nil, ErrLimitExceeded
+		}
+		heap.Remove(&l.queue, lowest.index)
+		lowest.ready <- ErrShed
+	}
+	l.seq++
+	w := &waiter{priority: priority, seq: l.seq, ready: make(chan error, 1)}
+	heap.Push(&l.queue, w)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.config.QueueTimeout > 0 {
+		timer := time.NewTimer(l.config.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case readyErr := <-w.ready:
+		if readyErr != nil {
+			return nil, readyErr
+		}
+		return l.newToken(), nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrQueueTimeout
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.index >= 0 {
+		heap.Remove(&l.queue, w.index)
+		return nil, err
+	}
+	// The waiter was dequeued while giving up. If it was granted a slot, hand it over to the next one.
+	if granted := <-w.ready; granted == nil {
+		l.inflight--
+		l.grant()
+	}
+	return nil, err
+}
+
+// newToken creates the token of a request which just took a slot.
+func (l *Limiter) newToken() *Token {
+	return &Token{limiter: l, start: time.Now()}
+}
+
+// release frees the slot of a request which took rtt and, if the limit is adaptive, updates it.
+func (l *Limiter) release(rtt time.Duration, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.config.Algorithm != nil {
+		limit := l.config.Algorithm.Update(l.limit, rtt, l.inflight, dropped)
+		l.limit = min(max(limit, l.config.MinLimit), l.config.MaxLimit)
+	}
+	l.inflight--
+	l.grant()
+}
+
+// grant hands the free slots to the waiting requests with the highest priority. The caller must hold the lock.
+func (l *Limiter) grant() {
+	for l.inflight < l.limit && l.queue.Len() > 0 {
+		w := heap.Pop(&l.queue).(*waiter)
+		l.inflight++
+		w.ready <- nil
+	}
+}
+
+// Token is a slot held by a request.
+type Token struct {
+	limiter *Limiter
+	start   time.Time
+	once    sync.Once
+}
+
+// Release frees the slot. dropped reports whether the request failed because of overload, like a timeout,
+// which makes adaptive algorithms lower the limit. Only the first call has effect.
+func (t *Token) Release(dropped bool) {
+	t.once.Do(func() {
+		t.limiter.release(time.Since(t.start), dropped)
+	})
+}
+
+// waiter is a request waiting for a slot.
+type waiter struct {
+	priority int
+	seq      uint64
+	ready    chan error // ready receives nil when the slot is granted or the reason the request was rejected.
+	index    int        // index is the position in the queue, or -1 once dequeued.
+}
+
+// waitQueue is a heap of waiters ordered by descending priority and then by arrival.
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// lowest returns the waiter which would be served last, or nil if the queue is empty.
+func (q waitQueue) lowest() *waiter {
+	var lowest *waiter
+	for _, w := range q {
+		if lowest == nil || w.priority < lowest.priority || (w.priority == lowest.priority && w.seq > lowest.seq) {
+			lowest = w
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,96 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestLimiterRejects checks requests over the limit are rejected when there's no queue.
+func TestLimiterRejects(t *testing.T) {
+	limiter := NewLimiter(Config{Limit: 1})
+	token, err := limiter.Acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := limiter.Acquire(context.Background(), 0); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded, got %v", err)
+	}
+	token.Release(false)
+	if limiter.InFlight() != 0 {
+		t.Errorf("Expected no requests in flight, got %d", limiter.InFlight())
+	}
+}
+
+// TestLimiterPriority checks waiting requests are served by priority and a full queue sheds the lowest one.
+func TestLimiterPriority(t *testing.T) {
+	limiter := NewLimiter(Config{Limit: 1, MaxQueue: 2})
+	token, _ := limiter.Acquire(context.Background(), 0)
+
+	results := make(chan string, 3)
+	acquire := func(name string, priority int) {
+		token, err := limiter.Acquire(context.Background(), priority)
+		if err != nil {
+			results <- name + ":" + err.Error()
+			return
+		}
+		results <- name
+		token.Release(false)
+	}
+	go acquire("low", 0)
+	waitQueued(t, limiter, 1)
+	go acquire("mid", 1)
+	waitQueued(t, limiter, 2)
+	go acquire("high", 2)
+
+	if result := <-results; result != "low:"+ErrShed.Error() {
+		t.Errorf("Expected the low priority request to be shed, got %s", result)
+	}
+	waitQueued(t, limiter, 2)
+	token.Release(false)
+	if first, second := <-results, <-results; first != "high" || second != "mid" {
+		t.Errorf("Expected high then mid, got %s then %s", first, second)
+	}
+}
+
+// TestLimiterQueueTimeout checks requests give up after waiting for the queue timeout.
+func TestLimiterQueueTimeout(t *testing.T) {
+	limiter := NewLimiter(Config{Limit: 1, MaxQueue: 1, QueueTimeout: 10 * time.Millisecond})
+	limiter.Acquire(context.Background(), 0)
+	if _, err := limiter.Acquire(context.Background(), 0); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Expected ErrQueueTimeout, got %v", err)
+	}
+	if limiter.queue.Len() != 0 {
+		t.Errorf("Expected the queue to be empty, got %d", limiter.queue.Len())
+	}
+}
+
+// TestAIMD checks the limit grows while used and shrinks on drops.
+func TestAIMD(t *testing.T) {
+	aimd := NewAIMD(time.Second, 0.5)
+	if limit := aimd.Update(10, time.Millisecond, 8, false); limit != 11 {
+		t.Errorf("Expected the limit to grow to 11, got %d", limit)
+	}
+	if limit := aimd.Update(10, time.Millisecond, 1, false); limit != 10 {
+		t.Errorf("Expected an unused limit to stay at 10, got %d", limit)
+	}
+	if limit := aimd.Update(10, 2*time.Second, 8, false); limit != 5 {
+		t.Errorf("Expected a slow request to halve the limit, got %d", limit)
+	}
+}
+
+// waitQueued waits until the limiter has n requests waiting.
+func waitQueued(t *testing.T, limiter *Limiter, n int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		limiter.mu.Lock()
+		queued := limiter.queue.Len()
+		limiter.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d requests queued", n)
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/lucastomic/msBaseProj/internal/concurrency"
+	"github.com/lucastomic/msBaseProj/internal/controller"
+	"github.com/lucastomic/msBaseProj/internal/features"
+	"github.com/lucastomic/msBaseProj/internal/logging"
+	"github.com/lucastomic/msBaseProj/internal/middleware"
+	"github.com/lucastomic/msBaseProj/internal/ratelimit"
+	"github.com/lucastomic/msBaseProj/internal/server"
+	"github.com/lucastomic/msBaseProj/internal/tlsconfig"
+	"github.com/lucastomic/msBaseProj/internal/translator"
+)
+
+// NewServer builds the server described by the config, serving the given controllers with the given
+// middlewares. The options given are applied after the config's ones, so they take precedence.
+// When the admin server is enabled over TCP, it's protected by the client certificates named in
+// server.admin.allowedClients.
+func (c Config) NewServer(
+	controllers []controller.Controller,
+	logger logging.Logger,
+	middlewares []middleware.Middleware,
+	authMiddleware middleware.Middleware,
+	opts ...server.Option,
+) (server.Server, error) {
+	configOpts, err := c.ServerOptions()
+	if err != nil {
+		return server.Server{}, err
+	}
+	return server.New(
+		c.Server.Listen,
+		controllers,
+		logger,
+		middlewares,
+		authMiddleware,
+		c.CORS.AllowOrigins,
+		append(configOpts, opts...)...,
+	), nil
+}
+
+// ServerOptions returns the server.Options applying the config.
+func (c Config) ServerOptions() ([]server.Option, error) {
+	s := c.Server
+	mode, err := etagMode(s.ETag)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := s.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+	rule, err := s.RateLimit.rule()
+	if err != nil {
+		return nil, err
+	}
+	opts := []server.Option{
+		server.WithMaxBodyBytes(s.MaxBodyBytes),
+		server.WithETagMode(mode),
+		server.WithTimeouts(server.Timeouts{
+			ReadHeader: s.Timeouts.ReadHeader,
+			Read:       s.Timeouts.Read,
+			Write:      s.Timeouts.Write,
+			Idle:       s.Timeouts.Idle,
+			Handler:    s.Timeouts.Handler,
+			Drain:      s.Timeouts.Drain,
+			Shutdown:   s.Timeouts.Shutdown,
+		}),
+		server.WithTLS(tlsConfig),
+		server.WithRateLimit(rule),
+		server.WithConcurrencyLimit(concurrency.Config{
+			Limit:        s.Concurrency.Limit,
+			MaxQueue:     s.Concurrency.MaxQueue,
+			QueueTimeout: s.Concurrency.QueueTimeout,
+		}),
+		server.WithCORS(c.corsSettings()),
+	}
+	for _, listener := range s.Listeners {
+		spec, err := server.ParseListenerSpec(listener)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, server.WithListenerSpecs(spec))
+	}
+	if s.Admin.Listener != "" {
+		spec, err := server.ParseListenerSpec(s.Admin.Listener)
+		if err != nil {
+			return nil, err
+		}
+		admin := server.AdminConfig{Listener: spec, Config: func() any { return c }}
+		if spec.Kind != server.ListenUnix {
+			admin.AuthMiddleware = middleware.NewMTLSAuthMiddleware(s.Admin.AllowedClients...)
+		}
+		opts = append(opts, server.WithAdmin(admin))
+	}
+	return opts, nil
+}
+
+// NewLogger builds the logger described by the config.
+func (c Config) NewLogger() (logging.Logger, error) {
+	opts, err := c.Log.options()
+	if err != nil {
+		return nil, err
+	}
+	return logging.NewConfiguredLogrusLogger(opts)
+}
+
+// LoadTranslations loads the translations described by the config into the translator.
+func (c Config) LoadTranslations() error {
+	if err := translator.Load(c.I18n.LocalesDir, c.I18n.Languages...); err != nil {
+		return err
+	}
+	translator.SetDefaultLang(c.I18n.DefaultLang)
+	return nil
+}
+
+// SetFeatures makes the feature flags of the config the ones in effect.
+func (c Config) SetFeatures() {
+	features.Set(c.Features)
+}
+
+// reloadable returns the settings of the server which can be changed while it runs.
+func (c Config) reloadable() (server.Reloadable, error) {
+	rule, err := c.Server.RateLimit.rule()
+	if err != nil {
+		return server.Reloadable{}, err
+	}
+	return server.Reloadable{
+		AllowOrigins: c.CORS.AllowOrigins,
+		CORS:         c.corsSettings(),
+		RateLimit:    rule,
+	}, nil
+}
+
+// corsSettings returns the server.CORS settings of the config.
+func (c Config) corsSettings() server.CORS {
+	return server.CORS{
+		AllowedMethods:   c.CORS.AllowedMethods,
+		AllowedHeaders:   c.CORS.AllowedHeaders,
+		ExposedHeaders:   c.CORS.ExposedHeaders,
+		AllowCredentials: c.CORS.AllowCredentials,
+		MaxAge:           c.CORS.MaxAge,
+	}
+}
+
+// options returns the logging options of the config, checking the level and format are known.
+func (l Log) options() (logging.Options, error) {
+	switch strings.ToLower(l.Level) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		return logging.Options{}, fmt.Errorf("unknown level %q", l.Level)
+	}
+	switch l.Format {
+	case "", "text", "json":
+	default:
+		return logging.Options{}, fmt.Errorf("unknown format %q", l.Format)
+	}
+	return logging.Options{Level: l.Level, Format: l.Format, File: l.File}, nil
+}
+
+// config returns the tlsconfig.Config described by the TLS settings.
+func (t TLS) config() (tlsconfig.Config, error) {
+	config := tlsconfig.Config{
+		CertFile:       t.CertFile,
+		KeyFile:        t.KeyFile,
+		ClientCAFiles:  t.ClientCAFiles,
+		ReloadInterval: t.ReloadInterval,
+	}
+	if t.MinVersion != "" {
+		version, ok := tlsVersions[t.MinVersion]
+		if !ok {
+			return tlsconfig.Config{}, fmt.Errorf("unknown minVersion %q", t.MinVersion)
+		}
+		config.MinVersion = version
+	}
+	if t.ClientAuth != "" {
+		clientAuth, ok := clientAuthTypes[t.ClientAuth]
+		if !ok {
+			return tlsconfig.Config{}, fmt.Errorf("unknown clientAuth %q", t.ClientAuth)
+		}
+		config.ClientAuth = clientAuth
+	}
+	suites := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	for _, name := range t.CipherSuites {
+		id, ok := suites[name]
+		if !ok {
+			return tlsconfig.Config{}, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		config.CipherSuites = append(config.CipherSuites, id)
+	}
+	return config, nil
+}
+
+// rule returns the ratelimit.Rule described by the rate limit settings.
+func (r RateLimit) rule() (ratelimit.Rule, error) {
+	rule := ratelimit.Rule{Limit: r.Limit, Window: r.Window}
+	switch r.Algorithm {
+	case "", "token-bucket":
+		rule.Algorithm = ratelimit.TokenBucket
+	case "sliding-window":
+		rule.Algorithm = ratelimit.SlidingWindow
+	default:
+		return ratelimit.Rule{}, fmt.Errorf("unknown algorithm %q", r.Algorithm)
+	}
+	switch key := r.Key; {
+	case key == "" || key == "ip":
+		rule.Key = ratelimit.ByIP
+	case key == "principal":
+		rule.Key = ratelimit.ByPrincipal
+	case strings.HasPrefix(key, "apikey:") && len(key) > len("apikey:"):
+		rule.Key = ratelimit.ByAPIKey(strings.TrimPrefix(key, "apikey:"))
+	default:
+		return ratelimit.Rule{}, fmt.Errorf("unknown key %q", r.Key)
+	}
+	return rule, nil
+}
+
+// etagMode returns the server.ETagMode named mode.
+func etagMode(mode string) (server.ETagMode, error) {
+	switch mode {
+	case "", "strong":
+		return server.ETagStrong, nil
+	case "weak":
+		return server.ETagWeak, nil
+	case "none":
+		return server.ETagNone, nil
+	default:
+		return 0, fmt.Errorf("unknown mode %q", mode)
+	}
+}
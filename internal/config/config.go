@@ -0,0 +1,201 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/server"
+)
+
+// Config is the configuration of a service built on this template. Services with settings of their own
+// embed it in their config struct, whose fields are loaded along with it.
+type Config struct {
+	Server   Server          `json:"server"`
+	Log      Log             `json:"log"`
+	CORS     CORS            `json:"cors"`
+	I18n     I18n            `json:"i18n"`
+	Features map[string]bool `json:"features" desc:"feature flags by name, checked with features.Enabled"`
+}
+
+// Server configures the HTTP server.
+type Server struct {
+	Listen       string      `json:"listen" desc:"address the API listens on"`
+	Listeners    []string    `json:"listeners" desc:"listeners replacing listen, like tcp://:8080, h2c://:8081 or unix:///run/service.sock?mode=0660"`
+	MaxBodyBytes int64       `json:"maxBodyBytes" desc:"default request body limit in bytes, negative to disable it"`
+	ETag         string      `json:"etag" desc:"how entity tags are computed: strong, weak or none"`
+	Timeouts     Timeouts    `json:"timeouts"`
+	TLS          TLS         `json:"tls"`
+	RateLimit    RateLimit   `json:"rateLimit"`
+	Concurrency  Concurrency `json:"concurrency"`
+	Admin        Admin       `json:"admin"`
+}
+
+// Timeouts configures the server timeouts. Zero disables a timeout.
+type Timeouts struct {
+	ReadHeader time.Duration `json:"readHeader" desc:"time allowed to read the request headers"`
+	Read       time.Duration `json:"read" desc:"time allowed to read the whole request"`
+	Write      time.Duration `json:"write" desc:"time allowed to write the response"`
+	Idle       time.Duration `json:"idle" desc:"time keep-alive connections wait for the next request"`
+	Handler    time.Duration `json:"handler" desc:"default time handlers can take"`
+	Drain      time.Duration `json:"drain" desc:"time the server keeps serving with readiness failing before shutting down"`
+	Shutdown   time.Duration `json:"shutdown" desc:"time in-flight requests are given to finish on shutdown"`
+}
+
+// TLS configures TLS termination. It's enabled when both the certificate and key files are set.
+type TLS struct {
+	CertFile       string        `json:"certFile" desc:"PEM certificate chain of the server"`
+	KeyFile        string        `json:"keyFile" desc:"PEM private key of the server"`
+	MinVersion     string        `json:"minVersion" desc:"lowest TLS version accepted: 1.2 or 1.3"`
+	CipherSuites   []string      `json:"cipherSuites" desc:"TLS 1.2 cipher suites allowed, by their Go name"`
+	ClientCAFiles  []string      `json:"clientCAFiles" desc:"PEM CAs client certificates are verified against"`
+	ClientAuth     string        `json:"clientAuth" desc:"client certificate policy: none, request, require-any, verify-if-given or require"`
+	ReloadInterval time.Duration `json:"reloadInterval" desc:"how often the certificate files are checked for changes"`
+}
+
+// RateLimit configures the server-wide rate limit. It's disabled when the limit is zero.
+type RateLimit struct {
+	Limit     int           `json:"limit" desc:"requests allowed per window and client"`
+	Window    time.Duration `json:"window" desc:"period the limit applies to"`
+	Algorithm string        `json:"algorithm" desc:"token-bucket or sliding-window"`
+	Key       string        `json:"key" desc:"how clients are told apart: ip, principal or apikey:<header>"`
+}
+
+// Concurrency configures the server-wide concurrency limit. It's disabled when the limit is zero.
+type Concurrency struct {
+	Limit        int           `json:"limit" desc:"requests handled at once"`
+	MaxQueue     int           `json:"maxQueue" desc:"requests which can wait for a slot"`
+	QueueTimeout time.Duration `json:"queueTimeout" desc:"time requests can wait for a slot"`
+}
+
+// Admin configures the admin server. It only runs when its listener is set.
+type Admin struct {
+	Listener       string   `json:"listener" desc:"listener of the admin server, like tcp://127.0.0.1:9090 or unix:///run/admin.sock"`
+	AllowedClients []string `json:"allowedClients" desc:"client certificate names allowed on the admin server"`
+}
+
+// Log configures the logger.
+type Log struct {
+	Level  string `json:"level" desc:"lowest level logged: debug, info, warn or error"`
+	Format string `json:"format" desc:"format of the entries: text or json"`
+	File   string `json:"file" desc:"file where entries are logged too"`
+}
+
+// CORS configures cross-origin requests.
+type CORS struct {
+	AllowOrigins     []string      `json:"allowOrigins" desc:"origins allowed to make cross-origin requests"`
+	AllowedMethods   []string      `json:"allowedMethods" desc:"methods cross-origin requests can use"`
+	AllowedHeaders   []string      `json:"allowedHeaders" desc:"headers cross-origin requests can send"`
+	ExposedHeaders   []string      `json:"exposedHeaders" desc:"response headers exposed to cross-origin clients"`
+	AllowCredentials bool          `json:"allowCredentials" desc:"whether cross-origin requests can send credentials"`
+	MaxAge           time.Duration `json:"maxAge" desc:"how long preflight responses can be cached"`
+}
+
+// I18n configures the translations of the messages sent to clients.
+type I18n struct {
+	LocalesDir  string   `json:"localesDir" desc:"directory holding the <lang>.json translation files"`
+	Languages   []string `json:"languages" desc:"languages loaded"`
+	DefaultLang string   `json:"defaultLang" desc:"language used when the requested one isn't available"`
+}
+
+// Default returns the config with every setting at its default.
+func Default() Config {
+	timeouts := server.DefaultTimeouts()
+	cors := server.DefaultCORS()
+	return Config{
+		Server: Server{
+			Listen:       ":8080",
+			MaxBodyBytes: server.DefaultMaxBodyBytes,
+			ETag:         "strong",
+			Timeouts: Timeouts{
+				ReadHeader: timeouts.ReadHeader,
+				Read:       timeouts.Read,
+				Write:      timeouts.Write,
+				Idle:       timeouts.Idle,
+				Handler:    timeouts.Handler,
+				Drain:      timeouts.Drain,
+				Shutdown:   timeouts.Shutdown,
+			},
+			TLS:       TLS{MinVersion: "1.2", ClientAuth: "none"},
+			RateLimit: RateLimit{Algorithm: "token-bucket", Key: "ip"},
+		},
+		Log: Log{Level: "info", Format: "text"},
+		CORS: CORS{
+			AllowedMethods:   cors.AllowedMethods,
+			AllowedHeaders:   cors.AllowedHeaders,
+			AllowCredentials: cors.AllowCredentials,
+		},
+		I18n: I18n{LocalesDir: "locales", Languages: []string{"en", "es"}, DefaultLang: "en"},
+	}
+}
+
+// Validate checks the config is consistent, reporting every problem found.
+func (c Config) Validate() error {
+	var problems []error
+	check := func(ok bool, format string, a ...any) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, a...))
+		}
+	}
+
+	s := c.Server
+	check(s.Listen != "" || len(s.Listeners) > 0, "server.listen: either it or server.listeners must be set")
+	for _, listener := range s.Listeners {
+		_, err := server.ParseListenerSpec(listener)
+		check(err == nil, "server.listeners: %v", err)
+	}
+	_, err := etagMode(s.ETag)
+	check(err == nil, "server.etag: %v", err)
+	for name, timeout := range map[string]time.Duration{
+		"readHeader": s.Timeouts.ReadHeader, "read": s.Timeouts.Read, "write": s.Timeouts.Write,
+		"idle": s.Timeouts.Idle, "drain": s.Timeouts.Drain, "shutdown": s.Timeouts.Shutdown,
+	} {
+		check(timeout >= 0, "server.timeouts.%s: must not be negative", name)
+	}
+	check((s.TLS.CertFile == "") == (s.TLS.KeyFile == ""), "server.tls: certFile and keyFile must be set together")
+	_, err = s.TLS.config()
+	check(err == nil, "server.tls: %v", err)
+	if s.RateLimit.Limit != 0 {
+		check(s.RateLimit.Limit > 0 && s.RateLimit.Window > 0, "server.rateLimit: limit and window must be positive")
+	}
+	_, err = s.RateLimit.rule()
+	check(err == nil, "server.rateLimit: %v", err)
+	check(s.Concurrency.Limit >= 0 && s.Concurrency.MaxQueue >= 0, "server.concurrency: limit and maxQueue must not be negative")
+	if s.Admin.Listener != "" {
+		spec, err := server.ParseListenerSpec(s.Admin.Listener)
+		check(err == nil, "server.admin.listener: %v", err)
+		mutualTLS := s.TLS.CertFile != "" && len(s.TLS.ClientCAFiles) > 0
+		check(err != nil || spec.Kind == server.ListenUnix || (spec.Kind == server.ListenTCP && mutualTLS),
+			"server.admin.listener: the admin server must listen on a Unix socket or on TCP with mutual TLS")
+	}
+
+	_, err = c.Log.options()
+	check(err == nil, "log: %v", err)
+	check(c.I18n.LocalesDir != "" && len(c.I18n.Languages) > 0, "i18n: localesDir and languages must be set")
+	check(contains(c.I18n.Languages, c.I18n.DefaultLang), "i18n.defaultLang: %q isn't among the languages", c.I18n.DefaultLang)
+	return errors.Join(problems...)
+}
+
+// tlsVersions maps the TLS versions accepted in configs to their crypto/tls values.
+var tlsVersions = map[string]uint16{"1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13}
+
+// clientAuthTypes maps the client certificate policies accepted in configs to their crypto/tls values.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":            tls.NoClientCert,
+	"request":         tls.RequestClientCert,
+	"require-any":     tls.RequireAnyClientCert,
+	"verify-if-given": tls.VerifyClientCertIfGiven,
+	"require":         tls.RequireAndVerifyClientCert,
+}
+
+// contains reports whether values holds value, ignoring case.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
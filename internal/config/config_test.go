@@ -0,0 +1,247 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/features"
+	"github.com/lucastomic/msBaseProj/internal/logging"
+)
+
+// serviceConfig is the config of a service embedding the template's one.
+type serviceConfig struct {
+	Config
+	Database struct {
+		URL      string `json:"url"`
+		Password string `json:"password"`
+	} `json:"database"`
+}
+
+// writeConfigFile writes a config file named name with the given content into a temporary directory.
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// TestLoadPrecedence checks flags override variables, which override files, which override the defaults.
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeConfigFile(t, "config.yaml", `
+server:
+  listen: ":9000"
+  timeouts:
+    read: 10s
+    write: 20s
+log:
+  level: debug
+database:
+  url: postgres://db
+`)
+	tomlFile := writeConfigFile(t, "config.toml", `
+[server.timeouts]
+write = "25s"
+`)
+	env := map[string]string{
+		"APP_SERVER_TIMEOUTS_WRITE":   "30s",
+		"APP_LOG_LEVEL":               "warn",
+		"APP_CORS_ALLOW_ORIGINS":      "https://a.example, https://b.example",
+		"APP_DATABASE_PASSWORD_FILE":  writeConfigFile(t, "password", "hunter2\n"),
+		"APP_SERVER_MAX_BODY_BYTES":   "2048",
+		"APP_SERVER_TLS_MIN_VERSION":  "1.3",
+		"APP_SERVER_RATE_LIMIT_LIMIT": "0",
+	}
+	loader := Loader{
+		EnvPrefix: "APP",
+		Files:     []string{yamlFile},
+		Args:      []string{"-config", tomlFile, "-log.level=error", "-cors.allowCredentials=false"},
+		LookupEnv: lookup(env),
+	}
+
+	cfg := serviceConfig{Config: Default()}
+	if err := loader.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	for name, test := range map[string]struct{ got, expected any }{
+		"file over default":     {cfg.Server.Listen, ":9000"},
+		"file":                  {cfg.Server.Timeouts.Read, 10 * time.Second},
+		"variable over files":   {cfg.Server.Timeouts.Write, 30 * time.Second},
+		"flag over variable":    {cfg.Log.Level, "error"},
+		"flag over default":     {cfg.CORS.AllowCredentials, false},
+		"default":               {cfg.Server.Timeouts.Idle, Default().Server.Timeouts.Idle},
+		"list variable":         {strings.Join(cfg.CORS.AllowOrigins, " "), "https://a.example https://b.example"},
+		"secret file":           {cfg.Database.Password, "hunter2"},
+		"service file setting":  {cfg.Database.URL, "postgres://db"},
+		"int variable":          {cfg.Server.MaxBodyBytes, int64(2048)},
+		"nested string setting": {cfg.Server.TLS.MinVersion, "1.3"},
+	} {
+		if test.got != test.expected {
+			t.Errorf("%s: expected %v, got %v", name, test.expected, test.got)
+		}
+	}
+}
+
+// TestLoadErrors checks mistakes are reported along with their source.
+func TestLoadErrors(t *testing.T) {
+	for name, test := range map[string]struct {
+		loader   Loader
+		expected string
+	}{
+		"unknown key": {
+			Loader{Files: []string{writeConfigFile(t, "config.json", `{"server": {"lsten": ":80"}}`)}},
+			"unknown setting server.lsten",
+		},
+		"bare duration": {
+			Loader{Files: []string{writeConfigFile(t, "config.yaml", "server:\n  timeouts:\n    read: 30\n")}},
+			"server.timeouts.read: durations must be written as strings",
+		},
+		"bad variable": {
+			Loader{EnvPrefix: "APP", LookupEnv: lookup(map[string]string{"APP_SERVER_TIMEOUTS_READ": "soon"})},
+			"environment variable APP_SERVER_TIMEOUTS_READ",
+		},
+		"variable and file": {
+			Loader{EnvPrefix: "APP", LookupEnv: lookup(map[string]string{"APP_LOG_LEVEL": "info", "APP_LOG_LEVEL_FILE": "/level"})},
+			"both APP_LOG_LEVEL and APP_LOG_LEVEL_FILE are set",
+		},
+		"invalid config": {
+			Loader{Args: []string{"-server.etag=sometimes", "-log.format=xml"}},
+			"server.etag: unknown mode \"sometimes\"\nlog: unknown format \"xml\"",
+		},
+	} {
+		cfg := Default()
+		if test.loader.LookupEnv == nil {
+			test.loader.LookupEnv = lookup(nil)
+		}
+		err := test.loader.Load(&cfg)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, test.expected, err)
+		}
+	}
+}
+
+// TestServerOptions checks the default config builds a server.
+func TestServerOptions(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Listeners = []string{"h2c://:8081", "unix:///run/service.sock?mode=0660"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.NewServer(nil, nil, nil, nil); err != nil {
+		t.Errorf("Expected the server to be built, got %v", err)
+	}
+}
+
+// recordingLogger records the messages logged and the level set.
+type recordingLogger struct {
+	logging.Logger
+	messages []string
+	level    string
+}
+
+func (l *recordingLogger) Info(ctx context.Context, format string, a ...any) {
+	l.messages = append(l.messages, fmt.Sprintf(format, a...))
+}
+
+func (l *recordingLogger) Error(ctx context.Context, format string, a ...any) {
+	l.messages = append(l.messages, fmt.Sprintf(format, a...))
+}
+
+func (l *recordingLogger) SetLevel(level string) error {
+	l.level = level
+	return nil
+}
+
+// TestReloader checks a valid config has its reloadable settings applied and its changes logged,
+// and an invalid one is rejected keeping the config in effect.
+func TestReloader(t *testing.T) {
+	locales := t.TempDir()
+	for _, lang := range []string{"en", "es"} {
+		if err := os.WriteFile(filepath.Join(locales, lang+".json"), []byte(`{"timeout": "`+lang+`"}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := writeConfigFile(t, "config.yaml", "i18n:\n  localesDir: "+locales+"\n")
+	loader := Loader{Files: []string{file}, LookupEnv: lookup(nil)}
+	newConfig := func() any {
+		cfg := serviceConfig{Config: Default()}
+		return &cfg
+	}
+	current := newConfig()
+	if err := loader.Load(current); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := current.(*serviceConfig).NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &recordingLogger{}
+	reloader := NewReloader(loader, newConfig, current, &srv, logger)
+
+	if err := os.WriteFile(file, []byte(`
+server:
+  listen: ":9000"
+  rateLimit:
+    limit: 10
+    window: 1m
+log:
+  level: debug
+cors:
+  allowOrigins: [https://app.example]
+features:
+  search: true
+i18n:
+  localesDir: `+locales+`
+database:
+  password: hunter2
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	settings := srv.Reloadable()
+	if len(settings.AllowOrigins) != 1 || settings.RateLimit.Limit != 10 {
+		t.Errorf("Expected the origins and rate limit to be reloaded, got %+v", settings)
+	}
+	if logger.level != "debug" || !features.Enabled("search") {
+		t.Errorf("Expected the log level and feature flags to be reloaded, got %q and %v", logger.level, features.Flags())
+	}
+	logged := strings.Join(logger.messages, "\n")
+	for _, expected := range []string{
+		`server.listen changed from ":8080" to ":9000", which takes effect after a restart`,
+		`server.rateLimit.limit changed from "0" to "10"`,
+		`database.password changed from unset to "****"`,
+	} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("Expected the changes logged to contain %q, got\n%s", expected, logged)
+		}
+	}
+	if strings.Contains(logged, "hunter2") {
+		t.Errorf("Expected the secrets to be masked, got\n%s", logged)
+	}
+
+	if err := os.WriteFile(file, []byte("cors:\n  allowOrigins: [https://evil.example]\nlog:\n  format: xml\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Errorf("Expected the invalid config to be rejected")
+	}
+	if got := srv.Reloadable().AllowOrigins; len(got) != 1 || got[0] != "https://app.example" {
+		t.Errorf("Expected the config in effect to be kept, got %v", got)
+	}
+}
+
+// lookup returns a LookupEnv function looking variables up in env.
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
@@ -0,0 +1,532 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Loader loads a config struct from, in increasing order of precedence:
+//  1. the values the struct holds when given, its defaults.
+//  2. the config files, in order. Their format is told by their extension: .yaml, .yml, .toml or .json.
+//  3. the environment variables, named after the setting's path in upper snake case under the prefix,
+//     e.g. APP_SERVER_TIMEOUTS_READ_HEADER for server.timeouts.readHeader. A variable suffixed _FILE names
+//     a file holding the value instead, so secrets can be mounted as files.
+//  4. the command-line flags, named after the setting's path, e.g. -server.timeouts.readHeader=5s.
+//
+// Settings are named after their field's json tag. Lists are written comma-separated in variables and flags,
+// and durations like "30s" everywhere. Files can also be given with the <prefix>_CONFIG variable and
+// the -config flag, both of which can list several.
+type Loader struct {
+	EnvPrefix string                            // EnvPrefix prefixes the environment variables, e.g. "APP".
+	Files     []string                          // Files are the config files always loaded.
+	Args      []string                          // Args are the command-line arguments, without the program name.
+	LookupEnv func(key string) (string, bool)   // LookupEnv looks environment variables up. os.LookupEnv when nil.
+	ReadFile  func(name string) ([]byte, error) // ReadFile reads config and secret files. os.ReadFile when nil.
+}
+
+// setting is a setting of a config struct which can be set from a variable or a flag.
+type setting struct {
+	path  []string      // path are the names leading to the setting.
+	value reflect.Value // value is the settable field.
+	desc  string        // desc describes the setting, from its desc tag.
+}
+
+// name returns the setting's name, as used in flags.
+func (s setting) name() string {
+	return strings.Join(s.path, ".")
+}
+
+// Load fills dst, a pointer to a struct holding the defaults, and validates it if it has a Validate() error
+// method. Errors name the source and setting which caused them.
+func (l Loader) Load(dst any) error {
+	root := reflect.ValueOf(dst)
+	if root.Kind() != reflect.Pointer || root.Elem().Kind() != reflect.Struct {
+		return errors.New("config: the destination must be a pointer to a struct")
+	}
+	if l.LookupEnv == nil {
+		l.LookupEnv = os.LookupEnv
+	}
+	if l.ReadFile == nil {
+		l.ReadFile = os.ReadFile
+	}
+	settings := collectSettings(root.Elem(), nil)
+
+	files, flagValues, err := l.sources(settings)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := l.loadFile(root.Elem(), file); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range settings {
+		name := l.envName(s.path...)
+		value, ok, err := l.lookup(name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := setString(s.value, value); err != nil {
+			return fmt.Errorf("config: environment variable %s: %w", name, err)
+		}
+	}
+
+	for _, flagValue := range flagValues {
+		if err := setString(flagValue.setting.value, flagValue.value); err != nil {
+			return fmt.Errorf("config: flag -%s: %w", flagValue.setting.name(), err)
+		}
+	}
+
+	if validator, ok := dst.(interface{ Validate() error }); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("config: invalid config:\n%w", err)
+		}
+	}
+	return nil
+}
+
+// ConfigFiles returns the config files Load reads into dst, in order: the loader's files, then the ones
+// given with the <prefix>_CONFIG variable and the -config flag. It lets callers watch them for changes.
+func (l Loader) ConfigFiles(dst any) ([]string, error) {
+	root := reflect.ValueOf(dst)
+	if root.Kind() != reflect.Pointer || root.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("config: the destination must be a pointer to a struct")
+	}
+	if l.LookupEnv == nil {
+		l.LookupEnv = os.LookupEnv
+	}
+	if l.ReadFile == nil {
+		l.ReadFile = os.ReadFile
+	}
+	files, _, err := l.sources(collectSettings(reflect.New(root.Elem().Type()).Elem(), nil))
+	return files, err
+}
+
+// sources returns the config files to load, in order, and the values given to the settings with flags.
+func (l Loader) sources(settings []setting) ([]string, []flagValue, error) {
+	flagFiles, flagValues, err := l.parseFlags(settings)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := append([]string{}, l.Files...)
+	if envFiles, ok, err := l.lookup(l.envName("config")); err != nil {
+		return nil, nil, err
+	} else if ok {
+		files = append(files, splitList(envFiles)...)
+	}
+	return append(files, flagFiles...), flagValues, nil
+}
+
+// envName returns the name of the environment variable of the setting with the given path.
+func (l Loader) envName(path ...string) string {
+	segments := make([]string, 0, len(path)+1)
+	if l.EnvPrefix != "" {
+		segments = append(segments, strings.ToUpper(l.EnvPrefix))
+	}
+	for _, segment := range path {
+		segments = append(segments, upperSnake(segment))
+	}
+	return strings.Join(segments, "_")
+}
+
+// lookup returns the value of the environment variable name, reading it from the file named by name_FILE
+// if that one is set instead. Setting both is an error, as it's unclear which one should win.
+func (l Loader) lookup(name string) (string, bool, error) {
+	value, ok := l.LookupEnv(name)
+	file, fileOK := l.LookupEnv(name + "_FILE")
+	if !fileOK {
+		return value, ok, nil
+	}
+	if ok {
+		return "", false, fmt.Errorf("config: both %s and %s_FILE are set", name, name)
+	}
+	content, err := l.ReadFile(file)
+	if err != nil {
+		return "", false, fmt.Errorf("config: environment variable %s_FILE: %w", name, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+// flagValue is a value given to a setting on the command line.
+type flagValue struct {
+	setting setting
+	value   string
+}
+
+// parseFlags parses the command-line arguments, returning the config files given with -config and the values
+// given to the settings, in order. Values are applied later so they take precedence over files and variables.
+func (l Loader) parseFlags(settings []setting) ([]string, []flagValue, error) {
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	var files []string
+	var values []flagValue
+	flags.Func("config", "config file to load, can be repeated or comma-separated", func(value string) error {
+		files = append(files, splitList(value)...)
+		return nil
+	})
+	for _, s := range settings {
+		flags.Var(&settingFlag{setting: s, values: &values}, s.name(), s.desc)
+	}
+	if err := flags.Parse(l.Args); err != nil {
+		return nil, nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, nil, fmt.Errorf("config: unexpected arguments %v", flags.Args())
+	}
+	return files, values, nil
+}
+
+// settingFlag is the flag.Value recording the values given to a setting.
+type settingFlag struct {
+	setting setting
+	values  *[]flagValue
+}
+
+func (f *settingFlag) String() string {
+	if f == nil || !f.setting.value.IsValid() {
+		return ""
+	}
+	return formatValue(f.setting.value)
+}
+
+func (f *settingFlag) Set(value string) error {
+	// Values are checked straight away so mistakes are reported along with the flag's usage.
+	if err := setString(reflect.New(f.setting.value.Type()).Elem(), value); err != nil {
+		return err
+	}
+	*f.values = append(*f.values, flagValue{f.setting, value})
+	return nil
+}
+
+func (f *settingFlag) IsBoolFlag() bool {
+	return f.setting.value.Kind() == reflect.Bool
+}
+
+// loadFile decodes the config file into v.
+func (l Loader) loadFile(v reflect.Value, file string) error {
+	data, err := l.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	var raw map[string]any
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	default:
+		return fmt.Errorf("config: %s: unknown format %q", file, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", file, err)
+	}
+	if err := assign(v, raw, nil); err != nil {
+		return fmt.Errorf("config: %s: %w", file, err)
+	}
+	return nil
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// collectSettings returns the settings of the struct v which can be set from a string, with their path
+// under prefix. Embedded structs are flattened, as their fields are promoted.
+func collectSettings(v reflect.Value, prefix []string) []setting {
+	var settings []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+		value := v.Field(i)
+		path := append(append([]string{}, prefix...), name)
+		switch {
+		case field.Anonymous && value.Kind() == reflect.Struct:
+			settings = append(settings, collectSettings(value, prefix)...)
+		case isScalar(value.Type()) || (value.Kind() == reflect.Slice && isScalar(value.Type().Elem())):
+			settings = append(settings, setting{path: path, value: value, desc: field.Tag.Get("desc")})
+		case value.Kind() == reflect.Struct:
+			settings = append(settings, collectSettings(value, path)...)
+		}
+	}
+	return settings
+}
+
+// fieldName returns the name of the struct field in configs: its json tag or, without one, its name
+// starting in lowercase. Unexported fields and fields tagged "-" have no name.
+func fieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name[:1]) + field.Name[1:]
+	}
+	return name, true
+}
+
+// isScalar reports whether values of type t are set from a single string.
+func isScalar(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setString parses value into v. Slices are parsed from comma-separated lists.
+func setString(v reflect.Value, value string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := splitList(value)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setString(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// assign sets v from raw, a value decoded from a config file. Keys are matched to fields ignoring case,
+// and unknown keys are rejected so typos don't go unnoticed.
+func assign(v reflect.Value, raw any, path []string) error {
+	if raw == nil {
+		return nil
+	}
+	where := strings.Join(path, ".")
+	if text, ok := raw.(string); ok && isScalar(v.Type()) {
+		if err := setString(v, text); err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+		return nil
+	}
+	if v.Type() == durationType {
+		return fmt.Errorf("%s: durations must be written as strings like \"30s\"", where)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		entries, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a table, got %T", where, raw)
+		}
+		for key, value := range entries {
+			field, ok := lookupField(v, key)
+			if !ok {
+				return fmt.Errorf("unknown setting %s", strings.Join(append(path, key), "."))
+			}
+			if err := assign(field, value, append(path, key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		entries, ok := raw.(map[string]any)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: expected a table, got %T", where, raw)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for key, value := range entries {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := assign(elem, value, append(path, key)); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Slice:
+		items, ok := raw.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a list, got %T", where, raw)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := assign(slice.Index(i), item, append(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := assign(elem.Elem(), raw, path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if err := assignScalar(v, raw); err != nil {
+		return fmt.Errorf("%s: %w", where, err)
+	}
+	return nil
+}
+
+// assignScalar sets v from a boolean or a number decoded from a config file, which may have been decoded
+// as any numeric type depending on the format.
+func assignScalar(v reflect.Value, raw any) error {
+	if b, ok := raw.(bool); ok && v.Kind() == reflect.Bool {
+		v.SetBool(b)
+		return nil
+	}
+	var f float64
+	switch n := raw.(type) {
+	case int:
+		f = float64(n)
+	case int64:
+		f = float64(n)
+	case uint64:
+		f = float64(n)
+	case float64:
+		f = n
+	default:
+		return fmt.Errorf("expected a %s, got %T", v.Type(), raw)
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f != math.Trunc(f) || v.OverflowInt(int64(f)) {
+			return fmt.Errorf("%v doesn't fit in a %s", raw, v.Type())
+		}
+		v.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f < 0 || f != math.Trunc(f) || v.OverflowUint(uint64(f)) {
+			return fmt.Errorf("%v doesn't fit in a %s", raw, v.Type())
+		}
+		v.SetUint(uint64(f))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("expected a %s, got %T", v.Type(), raw)
+	}
+	return nil
+}
+
+// lookupField returns the field of the struct v named key, ignoring case and looking into embedded structs.
+func lookupField(v reflect.Value, key string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Anonymous && v.Field(i).Kind() == reflect.Struct {
+			if found, ok := lookupField(v.Field(i), key); ok {
+				return found, true
+			}
+			continue
+		}
+		if name, ok := fieldName(field); ok && strings.EqualFold(name, key) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// formatValue formats v as it's written in variables and flags.
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatValue(v.Index(i))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// splitList splits a comma-separated list, trimming its items and dropping the empty ones.
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// upperSnake converts a lowerCamel name into UPPER_SNAKE case, e.g. "readHeader" into "READ_HEADER".
+func upperSnake(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/logging"
+	"github.com/lucastomic/msBaseProj/internal/server"
+)
+
+// DefaultWatchInterval is how often the config files are checked for changes by default.
+const DefaultWatchInterval = 2 * time.Second
+
+// reloadableSettings are the prefixes of the settings applied to the running service on reload.
+// Changes to the rest of the template's settings take effect on the next restart.
+var reloadableSettings = []string{"cors.", "log.level", "server.rateLimit.", "features.", "i18n."}
+
+// templateSettings are the prefixes of the template's settings. The service's own settings are left
+// to Reloader.OnReload.
+var templateSettings = []string{"server.", "log.", "cors.", "i18n.", "features."}
+
+// Reloader reloads the config of a running service when its files change or the process receives SIGHUP.
+// A reloaded config is validated before anything is applied, so an invalid one is rejected and logged while
+// the service keeps running with the config it had. A valid one has its reloadable settings applied to the
+// running service: the allowed origins and CORS settings, the log level, the server-wide rate limit,
+// the feature flags and the translations.
+type Reloader struct {
+	loader    Loader
+	newConfig func() any
+	server    *server.Server
+	logger    logging.Logger
+
+	// OnReload, if set, is called with every config applied, so the service can apply its own settings.
+	OnReload func(config any)
+	// Interval is how often the config files are checked for changes. DefaultWatchInterval when zero.
+	Interval time.Duration
+
+	mu       sync.Mutex           // mu serializes the reloads.
+	current  any                  // current is the config in effect.
+	modTimes map[string]time.Time // modTimes are the modification times of the config files when last checked.
+}
+
+// NewReloader creates a Reloader loading the config with loader into the values returned by newConfig,
+// which must be pointers to structs holding the defaults, like the one current points to: the config in
+// effect. Configs are either a Config or a struct embedding one. The reloadable settings are applied to srv
+// and, if it implements logging.LevelSetter, to logger.
+func NewReloader(loader Loader, newConfig func() any, current any, srv *server.Server, logger logging.Logger) *Reloader {
+	return &Reloader{
+		loader:    loader,
+		newConfig: newConfig,
+		server:    srv,
+		logger:    logger,
+		current:   current,
+	}
+}
+
+// Current returns the config in effect.
+func (r *Reloader) Current() any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Watch reloads the config whenever the process receives SIGHUP or one of the config files changes,
+// until ctx is canceled. Files are checked for changes every Interval.
+func (r *Reloader) Watch(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	r.filesChanged()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			r.logger.Info(context.Background(), "Reloading config on SIGHUP")
+			r.Reload()
+		case <-ticker.C:
+			if r.filesChanged() {
+				r.logger.Info(context.Background(), "Reloading config as its files changed")
+				r.Reload()
+			}
+		}
+	}
+}
+
+// Reload loads and validates the config and, if it's valid, applies its reloadable settings and logs what
+// changed. Otherwise the error is logged and returned, and the config in effect is kept.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := r.newConfig()
+	if err := r.loader.Load(next); err != nil {
+		r.logger.Error(context.Background(), "Rejected the reloaded config: %v", err)
+		return err
+	}
+	if err := r.apply(next); err != nil {
+		r.logger.Error(context.Background(), "Rejected the reloaded config: %v", err)
+		return err
+	}
+
+	changes := diff(r.current, next)
+	if len(changes) == 0 {
+		r.logger.Info(context.Background(), "Config reloaded without changes")
+	}
+	for _, change := range changes {
+		r.logger.Info(context.Background(), "Config reloaded: %s", change)
+	}
+	r.current = next
+	if r.OnReload != nil {
+		r.OnReload(next)
+	}
+	return nil
+}
+
+// apply applies the reloadable settings of config. The ones which can fail are prepared first,
+// so nothing is applied unless everything can be.
+func (r *Reloader) apply(config any) error {
+	base, ok := baseConfig(config)
+	if !ok {
+		return fmt.Errorf("config: %T neither is nor embeds a Config", config)
+	}
+	reloadable, err := base.reloadable()
+	if err != nil {
+		return err
+	}
+	opts, err := base.Log.options()
+	if err != nil {
+		return fmt.Errorf("log: %w", err)
+	}
+	if err := base.LoadTranslations(); err != nil {
+		return fmt.Errorf("i18n: %w", err)
+	}
+
+	reloadable.Config = config
+	r.server.Reload(reloadable)
+	if setter, ok := r.logger.(logging.LevelSetter); ok && opts.Level != "" {
+		if err := setter.SetLevel(opts.Level); err != nil {
+			r.logger.Error(context.Background(), "Failed to set the log level: %v", err)
+		}
+	}
+	base.SetFeatures()
+	return nil
+}
+
+// filesChanged reports whether any config file was modified, created or removed since it was last called.
+func (r *Reloader) filesChanged() bool {
+	files, err := r.loader.ConfigFiles(r.newConfig())
+	if err != nil {
+		return false
+	}
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+	changed := r.modTimes != nil && !maps.Equal(modTimes, r.modTimes)
+	r.modTimes = modTimes
+	return changed
+}
+
+// baseConfig returns the Config config points to or embeds.
+func baseConfig(config any) (Config, bool) {
+	v := reflect.ValueOf(config)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return Config{}, false
+		}
+		v = v.Elem()
+	}
+	if base, ok := v.Interface().(Config); ok {
+		return base, true
+	}
+	if v.Kind() != reflect.Struct {
+		return Config{}, false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if field := v.Type().Field(i); field.Anonymous && field.Type == reflect.TypeOf(Config{}) {
+			return v.Field(i).Interface().(Config), true
+		}
+	}
+	return Config{}, false
+}
+
+// diff describes the settings which differ between the old and the next config, with the values of secrets
+// masked. Changes to the template's settings which can't be reloaded are flagged as requiring a restart.
+func diff(old, next any) []string {
+	oldValues, newValues := flatten(old), flatten(next)
+	oldMasked, newMasked := flattenTree(server.MaskSecrets(old)), flattenTree(server.MaskSecrets(next))
+	paths := make([]string, 0, len(newValues))
+	for path := range oldValues {
+		paths = append(paths, path)
+	}
+	for path := range newValues {
+		if _, ok := oldValues[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	var changes []string
+	for _, path := range paths {
+		if reflect.DeepEqual(oldValues[path], newValues[path]) {
+			continue
+		}
+		change := fmt.Sprintf("%s changed from %s to %s", path, describe(oldMasked, path), describe(newMasked, path))
+		if hasPrefix(path, templateSettings) && !hasPrefix(path, reloadableSettings) {
+			change += ", which takes effect after a restart"
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// flatten returns the settings of config by their path, as they're encoded in JSON.
+func flatten(config any) map[string]any {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil
+	}
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil
+	}
+	return flattenTree(tree)
+}
+
+// flattenTree returns the leaves of a tree of maps by their path. Lists are leaves.
+func flattenTree(tree any) map[string]any {
+	leaves := map[string]any{}
+	var walk func(node any, path string)
+	walk = func(node any, path string) {
+		entries, ok := node.(map[string]any)
+		if !ok {
+			leaves[path] = node
+			return
+		}
+		for key, value := range entries {
+			if path != "" {
+				key = path + "." + key
+			}
+			walk(value, key)
+		}
+	}
+	walk(tree, "")
+	return leaves
+}
+
+// describe formats the value of the setting at path for the logs.
+func describe(values map[string]any, path string) string {
+	value, ok := values[path]
+	if !ok || value == nil || value == "" {
+		return "unset"
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(value))
+}
+
+// hasPrefix reports whether path starts with any of the prefixes.
+func hasPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
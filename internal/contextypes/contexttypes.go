@@ -10,3 +10,15 @@ type ContextAuthKey struct{}
 
 // ContextLangKey is a type used as a context key for the languague requested
 type ContextLangKey struct{}
+
+// ContextCodecsKey is a type used as a context key for the codec registry the server negotiates with
+type ContextCodecsKey struct{}
+
+// ContextCacheKey is a type used as a context key for the store of cached responses
+type ContextCacheKey struct{}
+
+// ContextSelectionKey is a type used as a context key for the fields and relations selected by the client
+type ContextSelectionKey struct{}
+
+// ContextTimeoutKey is a type used as a context key for lifting the handler timeout of a request
+type ContextTimeoutKey struct{}
@@ -0,0 +1,25 @@
+package contextypes
+
+import (
+	"context"
+	"fmt"
+)
+
+// PrincipalID returns a stable identifier of the principal stored in ctx by the authentication middleware:
+// the principal itself if it's a string, or the result of its ID() string or String() string method.
+// Anonymous requests get an empty identifier, and the boolean is false for any other principal, as
+// formatting arbitrary values could give pointers, which change on every request, or expose secrets.
+func PrincipalID(ctx context.Context) (string, bool) {
+	switch p := ctx.Value(ContextAuthKey{}).(type) {
+	case nil:
+		return "", true
+	case string:
+		return p, true
+	case interface{ ID() string }:
+		return p.ID(), true
+	case fmt.Stringer:
+		return p.String(), true
+	default:
+		return "", false
+	}
+}
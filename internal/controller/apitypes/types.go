@@ -1,7 +1,14 @@
 package apitypes
 
 import (
+	"io"
+	"iter"
 	"net/http"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/concurrency"
+	"github.com/lucastomic/msBaseProj/internal/ratelimit"
+	"github.com/lucastomic/msBaseProj/internal/websocket"
 )
 
 // APIFunc is a type that represents a function signature for API handlers.
@@ -19,6 +26,78 @@ type Response struct {
 	Status  int               // HTTP status code to be returned with the response.
 	Content any               // The payload of the response, allowing for flexible data types.
 	Headers map[string]string // HTTP headers to be returned witht he response.
+
+	// ETag is the entity tag of the returned resource, like a version number. When empty, the server
+	// computes one from the encoded content for successful GET and HEAD responses.
+	ETag string
+	// LastModified is the time the returned resource was last modified. When set, it's sent in the
+	// Last-Modified header and used to evaluate If-Modified-Since.
+	LastModified time.Time
+	// Stream, when set, writes the body of the response in place of Content, e.g. server-sent events.
+	// The server sends the Headers and the Status, 200 when zero, and then calls it.
+	Stream StreamFunc
+}
+
+// StreamFunc writes the body of a streamed response to w as it's produced, flushing it when needed.
+// It must return once the request's context is done, which happens when the client goes away.
+// The error it returns is logged, as the status code has already been sent.
+type StreamFunc func(w http.ResponseWriter, r *http.Request) error
+
+// File is a file sent as the body of a response, like a stored upload. When it's the Content of a Response,
+// the server sends it as it is, with its Content-Length, honouring Range and If-Range requests with 206
+// responses, multipart/byteranges ones for several ranges, and the conditional headers against the Response's
+// ETag and LastModified. The Response's Status is ignored, as it follows from the request.
+// Content is closed once sent if it's an io.Closer. The file is sent without the handler timeout and copied
+// straight to the connection, e.g. with sendfile when the content is an *os.File, if it isn't compressed.
+// For example:
+//
+//	object, err := c.storage.Open(r.Context(), key)
+//	if err != nil {
+//		return c.ParseError(r.Context(), r, w, err)
+//	}
+//	return apitypes.Response{Content: apitypes.File{Content: object, Name: "report.pdf"}, LastModified: object.ModTime}
+type File struct {
+	Content io.ReadSeeker // Content is the content of the file.
+	// Name is the name of the file, suggested to the client in the Content-Disposition header.
+	// The Content-Type is detected from its extension when ContentType is empty, or from the content otherwise.
+	Name string
+	// ContentType is the media type of the file.
+	ContentType string
+	// Inline makes the client display the file, like an image, instead of downloading it.
+	Inline bool
+}
+
+// Items is a collection produced one item at a time, like the rows of a database cursor. When it's the
+// Content of a Response, the server streams it without holding the whole collection in memory: as NDJSON
+// if the client accepts application/x-ndjson over application/json, or as a JSON array otherwise.
+// An error yielded before the first item is answered with a 500. Once items were sent, the error ends the
+// stream: the JSON array is left unclosed, NDJSON gets a last {"error": "..."} line, and both carry it in
+// the X-Stream-Error trailer. Iteration stops when the client goes away, so producers watching the request's
+// context can stop too. The handler timeout doesn't apply to the streaming, which takes as long as the client
+// needs, but routes returning long collections should have no cache policy, as it holds the response until it's complete.
+type Items iter.Seq2[any, error]
+
+// SeqItems adapts an iterator of items and errors, like the ones returned by repositories, to Items.
+func SeqItems[T any](seq iter.Seq2[T, error]) Items {
+	return func(yield func(any, error) bool) {
+		for item, err := range seq {
+			if !yield(item, err) {
+				return
+			}
+		}
+	}
+}
+
+// ChanItems adapts a channel of items to Items, which ends once the channel is closed. The producer
+// should stop sending when the request's context is done, as nobody receives items after.
+func ChanItems[T any](items <-chan T) Items {
+	return func(yield func(any, error) bool) {
+		for item := range items {
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
 }
 
 // Route is a struct with the necessary information for defaining and endpoint. Its path,
@@ -28,4 +107,47 @@ type Route struct {
 	Method      string  // The HTTP method that will manage, like POST, PUT, GET, etc.
 	Handler     APIFunc // The function that will handle the route
 	RequireAuth bool    // Defines if the endpoints requires authentication
+
+	// MaxBodyBytes is the maximum size of the request body in bytes. Zero uses the server default
+	// and a negative value disables the limit.
+	MaxBodyBytes int64
+	// ContentTypes are the media types the request body can be sent with, e.g. application/json.
+	// Wildcard subtypes like multipart/* are allowed. Empty uses the server default.
+	ContentTypes []string
+	// Cache defines how the responses of the route are cached. Only GET routes can be cached.
+	Cache CachePolicy
+	// RateLimit is the rate limit applied to the route on top of the server-wide one. A negative
+	// Limit exempts the route from any rate limit.
+	RateLimit ratelimit.Rule
+	// Concurrency is the limit of concurrent requests of the route, on top of the server-wide one.
+	Concurrency concurrency.Config
+	// Priority is the priority of the route's requests when waiting for a concurrency slot.
+	// Higher priority requests are served first and, under pressure, shed last.
+	Priority int
+	// Timeout bounds the time the route's handler can take. Zero uses the server's default and a negative
+	// value disables it. Streams, Items and Files are sent once the handler returned, without timeout.
+	Timeout time.Duration
+	// WebSocket, when set, makes the route a WebSocket endpoint: its GET requests are upgraded and the
+	// connections handed to the endpoint's handler instead of Handler. The handshake goes through the
+	// server-wide middlewares, authentication and rate limits, but not through the concurrency limits,
+	// body limits, cache or timeout, which don't apply to long-lived connections.
+	WebSocket *websocket.Endpoint
+	// Fields are the paths of the response fields clients can select with the fields query param, nested
+	// ones separated by dots and named after their JSON names, like owner.name. Selecting a path allows
+	// selecting the ones nested in it. Empty allows selecting any field.
+	Fields []string
+	// Expand are the related resources clients can ask to be included with the expand query param, like
+	// owner. Handlers check whether they were asked for with fields.Expanded. Empty allows none.
+	Expand []string
+}
+
+// CachePolicy defines how the server caches the responses of a route in memory.
+// Responses are cached per method, path, the selected query params, Accept, Accept-Language and
+// authenticated principal, so clients never receive a representation meant for somebody else. Principals are
+// told apart by their ID() string or String() string method, or by themselves if they're strings; the responses
+// of requests with a principal which has none of them aren't cached.
+type CachePolicy struct {
+	TTL                  time.Duration // TTL is how long a response stays fresh. Zero disables caching.
+	StaleWhileRevalidate time.Duration // StaleWhileRevalidate is how long an expired response is still served while it's refreshed in background.
+	QueryParams          []string      // QueryParams are the query params which change the response. Any other is ignored.
 }
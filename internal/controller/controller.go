@@ -4,12 +4,20 @@ import (
 	"context"
 	"errors"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
+	"github.com/lucastomic/msBaseProj/internal/codec"
 	"github.com/lucastomic/msBaseProj/internal/controller/apitypes"
 	"github.com/lucastomic/msBaseProj/internal/errs"
+	"github.com/lucastomic/msBaseProj/internal/etag"
+	"github.com/lucastomic/msBaseProj/internal/fields"
 	"github.com/lucastomic/msBaseProj/internal/logging"
+	"github.com/lucastomic/msBaseProj/internal/pagination"
+	"github.com/lucastomic/msBaseProj/internal/params"
+	"github.com/lucastomic/msBaseProj/internal/patch"
 	"github.com/lucastomic/msBaseProj/internal/translator"
+	"github.com/lucastomic/msBaseProj/internal/upload"
 )
 
 // Controller defines the interface for an HTTP controller.
@@ -43,7 +51,6 @@ func (c *CommonController) ParseError(
 	return apitypes.Response{
 		Status:  httpErr.Code,
 		Content: map[string]any{"error": httpErr.Error()},
-		Headers: map[string]string{"Content-Type": "application/json"},
 	}
 }
 
@@ -51,16 +58,155 @@ func (c *CommonController) ParseError(
 // and the concret url /boat/12/book ReadIDFromPath(r) would return 12.
 // This metod was thought only to be used to retrieve uint ids.
 // In case of no id var, it will throw an error. Also if the id isn't an uint.
+// The id must fit in 32 bits; errors are the translated ones of params.Path.
 func (b CommonController) ReadIDFromPath(r *http.Request) (uint, error) {
-	idString := r.PathValue("id")
-	if idString == "" {
-		return 0, errors.New("no id provided at path")
+	id, err := params.Path[uint32](r, "id")
+	return uint(id), err
+}
+
+// PathInt reads the integer path value name. Like the rest of the path and query helpers, it returns an
+// errs.I18nError wrapping errs.ErrInvalidInput and naming the parameter if it's missing or invalid, meant to be
+// passed straight to ParseError. The generic functions of the params package cover any other type.
+func (b CommonController) PathInt(r *http.Request, name string) (int, error) {
+	return params.Path[int](r, name)
+}
+
+// PathUint reads the unsigned integer path value name.
+func (b CommonController) PathUint(r *http.Request, name string) (uint, error) {
+	return params.Path[uint](r, name)
+}
+
+// PathUUID reads the UUID path value name, in its canonical form.
+func (b CommonController) PathUUID(r *http.Request, name string) (params.UUID, error) {
+	return params.Path[params.UUID](r, name)
+}
+
+// QueryInt reads the integer query param name, or def if it's missing.
+func (b CommonController) QueryInt(r *http.Request, name string, def int) (int, error) {
+	return params.Query(r, name, def)
+}
+
+// QueryUint reads the unsigned integer query param name, or def if it's missing.
+func (b CommonController) QueryUint(r *http.Request, name string, def uint) (uint, error) {
+	return params.Query(r, name, def)
+}
+
+// QueryFloat reads the floating point query param name, or def if it's missing.
+func (b CommonController) QueryFloat(r *http.Request, name string, def float64) (float64, error) {
+	return params.Query(r, name, def)
+}
+
+// QueryBool reads the boolean query param name, or def if it's missing.
+func (b CommonController) QueryBool(r *http.Request, name string, def bool) (bool, error) {
+	return params.Query(r, name, def)
+}
+
+// QueryUUID reads the UUID query param name, or def if it's missing.
+func (b CommonController) QueryUUID(r *http.Request, name string, def params.UUID) (params.UUID, error) {
+	return params.Query(r, name, def)
+}
+
+// QueryTime reads the query param name as an RFC 3339 timestamp or a date, or def if it's missing.
+func (b CommonController) QueryTime(r *http.Request, name string, def time.Time) (time.Time, error) {
+	return params.Query(r, name, def)
+}
+
+// QueryEnum reads the query param name, which must be one of allowed, or def if it's missing.
+func (b CommonController) QueryEnum(r *http.Request, name string, def string, allowed ...string) (string, error) {
+	return params.QueryEnum(r, name, def, allowed...)
+}
+
+// QueryStrings reads the values of the query param name, repeated or separated by commas.
+func (b CommonController) QueryStrings(r *http.Request, name string) ([]string, error) {
+	return params.QuerySlice[string](r, name)
+}
+
+// QueryInts reads the integer values of the query param name, repeated or separated by commas.
+func (b CommonController) QueryInts(r *http.Request, name string) ([]int, error) {
+	return params.QuerySlice[int](r, name)
+}
+
+// BindParams fills the struct pointed to by dst from the path values and query params named by its
+// `path` and `query` tags. See params.Bind.
+func (b CommonController) BindParams(r *http.Request, dst any) error {
+	return params.Bind(r, dst)
+}
+
+// DecodeJSON strictly decodes the JSON request body into dst. Unknown fields, trailing data and
+// documents nested too deep are rejected, and bodies cut off by the server's body limit are reported
+// as errs.ErrPayloadTooLarge. The returned error is meant to be passed straight to ParseError.
+// The Content-Type is ignored, so it suits routes which only take JSON, like the ones whose ContentTypes
+// already restrict it; routes taking every format the server speaks use DecodeBody instead.
+func (b CommonController) DecodeJSON(r *http.Request, dst any) error {
+	return codec.DecodeJSON(r.Body, dst, codec.DefaultMaxDepth)
+}
+
+// DecodeBody decodes the request body into dst with the codec registered for its Content-Type,
+// so the same handler accepts every format the server can respond with. Requests sent with a
+// Content-Type no codec handles fail with errs.ErrUnsupportedMediaType. JSON bodies are decoded as
+// strictly as with DecodeJSON, which is meant for the routes only taking JSON.
+// The returned error is meant to be passed straight to ParseError.
+func (b CommonController) DecodeBody(r *http.Request, dst any) error {
+	c, ok := codec.RegistryFromCtx(r.Context()).ForContentType(r.Header.Get("Content-Type"))
+	if !ok {
+		return errs.NewI18NError("no decoder for content type: %w", errs.ErrUnsupportedMediaType, "unsupportedmediatype")
+	}
+	return c.Decode(r.Body, dst)
+}
+
+// ReceiveUpload streams the files of a multipart/form-data request to storage, enforcing the limits
+// and allowed content types of opts, and returns the form's values and the files stored.
+// The returned error is meant to be passed straight to ParseError.
+func (b CommonController) ReceiveUpload(r *http.Request, storage upload.Storage, opts upload.Options) (upload.Form, error) {
+	return upload.Receive(r, storage, opts)
+}
+
+// Paginate reads the page of a collection asked for by the request: its limit, offset or cursor, sort and
+// filters, validated against the paginator's schema. The page is answered with pagination.Response.
+// The returned error is meant to be passed straight to ParseError.
+func (b CommonController) Paginate(r *http.Request, paginator *pagination.Paginator) (pagination.Query, error) {
+	return paginator.Parse(r)
+}
+
+// Expanded reports whether the client asked, through the expand query param, to include the given related
+// resource in the response, so handlers only load the relations which are going to be sent. Only the relations
+// listed in the route's Expand can be asked for.
+func (b CommonController) Expanded(r *http.Request, relation string) bool {
+	return fields.Expanded(r, relation)
+}
+
+// ApplyPatch applies the JSON Patch or JSON Merge Patch sent in the request body to the resource pointed
+// to by resource, rejecting changes to the read-only fields of opts and validating the result. The route must
+// accept patch.MediaTypes as its ContentTypes. The returned error is meant to be passed straight to ParseError.
+func (b CommonController) ApplyPatch(r *http.Request, resource any, opts patch.Options) error {
+	return patch.Apply(r, resource, opts)
+}
+
+// CheckPreconditions evaluates the If-Match and If-Unmodified-Since headers of a request which modifies
+// a resource against its current entity tag and modification time, enabling optimistic concurrency:
+// a client sends back the ETag it read and the update only succeeds if nobody changed the resource since.
+// Either of currentETag and lastModified can be left empty when the resource doesn't track it. It's meant
+// for existing resources, so an If-Match of "*" always succeeds.
+// Returns an errs.I18nError wrapping errs.ErrPreconditionFailed, mapped to a 412 by ParseError, if a precondition fails.
+func (b CommonController) CheckPreconditions(r *http.Request, currentETag string, lastModified time.Time) error {
+	failed := errs.NewI18NError("precondition failed: %w", errs.ErrPreconditionFailed, "preconditionfailed")
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		// "*" matches any current representation, whether it's tagged or not.
+		if strings.TrimSpace(ifMatch) == "*" {
+			return nil
+		}
+		if currentETag == "" || !etag.MatchStrong(ifMatch, etag.Normalize(currentETag)) {
+			return failed
+		}
+		return nil
 	}
-	id, err := strconv.ParseUint(idString, 10, 32)
-	if err != nil {
-		return 0, errors.New("id is not an unsigned integer")
+	if ifUnmodifiedSince := r.Header.Get("If-Unmodified-Since"); ifUnmodifiedSince != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ifUnmodifiedSince)
+		if err == nil && lastModified.Truncate(time.Second).After(since) {
+			return failed
+		}
 	}
-	return uint(id), nil
+	return nil
 }
 
 // mapDomainErrorToHTTP converts domain-specific errors to errs.HTTPError instances.
@@ -70,7 +216,7 @@ func mapDomainErrorToHTTP(ctx context.Context, err error) errs.HTTPError {
 	i18n := &errs.I18nError{}
 	var message string
 	if errors.As(err, i18n) {
-		message = translator.TranslateGivenCtx(ctx, i18n.Code)
+		message = i18n.Translate(ctx)
 		err = i18n.Unwrap()
 	} else {
 		message = err.Error()
@@ -87,6 +233,20 @@ func mapDomainErrorToHTTP(ctx context.Context, err error) errs.HTTPError {
 		return *errs.NewHTTPError(http.StatusUnauthorized, message)
 	case errors.Is(err, errs.ErrConflict):
 		return *errs.NewHTTPError(http.StatusConflict, message)
+	case errors.Is(err, errs.ErrPayloadTooLarge):
+		return *errs.NewHTTPError(http.StatusRequestEntityTooLarge, message)
+	case errors.Is(err, errs.ErrUnsupportedMediaType):
+		return *errs.NewHTTPError(http.StatusUnsupportedMediaType, message)
+	case errors.Is(err, errs.ErrNotAcceptable):
+		return *errs.NewHTTPError(http.StatusNotAcceptable, message)
+	case errors.Is(err, errs.ErrPreconditionFailed):
+		return *errs.NewHTTPError(http.StatusPreconditionFailed, message)
+	case errors.Is(err, errs.ErrTooManyRequests):
+		return *errs.NewHTTPError(http.StatusTooManyRequests, message)
+	case errors.Is(err, errs.ErrServiceUnavailable):
+		return *errs.NewHTTPError(http.StatusServiceUnavailable, message)
+	case errors.Is(err, errs.ErrTimeout):
+		return *errs.NewHTTPError(http.StatusGatewayTimeout, message)
 	default:
 		return *errs.NewHTTPError(http.StatusInternalServerError, translator.TranslateGivenCtx(ctx, "internalerror"))
 	}
@@ -5,9 +5,16 @@ import (
 )
 
 var (
-	ErrinternalError = errors.New("unexpected internal error")
-	ErrInvalidInput  = errors.New("invalidinput")
-	ErrNotFound      = errors.New("resourcenotfound")
-	ErrNotAuthorized = errors.New("unauthorized")
-	ErrConflict      = errors.New("there is a conflict with the current status")
+	ErrinternalError        = errors.New("unexpected internal error")
+	ErrInvalidInput         = errors.New("invalidinput")
+	ErrNotFound             = errors.New("resourcenotfound")
+	ErrNotAuthorized        = errors.New("unauthorized")
+	ErrConflict             = errors.New("there is a conflict with the current status")
+	ErrPayloadTooLarge      = errors.New("payloadtoolarge")
+	ErrUnsupportedMediaType = errors.New("unsupportedmediatype")
+	ErrNotAcceptable        = errors.New("notacceptable")
+	ErrPreconditionFailed   = errors.New("preconditionfailed")
+	ErrTooManyRequests      = errors.New("toomanyrequests")
+	ErrServiceUnavailable   = errors.New("serviceunavailable")
+	ErrTimeout              = errors.New("timeout")
 )
@@ -1,15 +1,44 @@
 package errs
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lucastomic/msBaseProj/internal/translator"
+)
 
 type I18nError struct {
 	Err  error
 	Code string
+	// Params are the values of the {name} placeholders of the translated message, like the name of an
+	// invalid parameter.
+	Params map[string]string
 }
 
 func NewI18NError(format string, err error, code string) I18nError {
 	err = fmt.Errorf(format, err)
-	return I18nError{err, code}
+	return I18nError{Err: err, Code: code}
+}
+
+// WithParam returns a copy of the error filling the {name} placeholder of its message with value.
+func (e I18nError) WithParam(name, value string) I18nError {
+	params := make(map[string]string, len(e.Params)+1)
+	for key, v := range e.Params {
+		params[key] = v
+	}
+	params[name] = value
+	e.Params = params
+	return e
+}
+
+// Translate returns the message of the error in the language stored in ctx, with its placeholders filled.
+func (e I18nError) Translate(ctx context.Context) string {
+	message := translator.TranslateGivenCtx(ctx, e.Code)
+	for name, value := range e.Params {
+		message = strings.ReplaceAll(message, "{"+name+"}", value)
+	}
+	return message
 }
 
 func (e I18nError) Error() string {
@@ -19,3 +48,44 @@ func (e I18nError) Error() string {
 func (e I18nError) Unwrap() error {
 	return e.Err
 }
+
+// Codes are the translation keys of the messages the template sends to clients. Every locale file must
+// translate them, which `i18n check` verifies.
+var Codes = []string{
+	"bodytoodeep",
+	"emptybody",
+	"filetoolarge",
+	"filetypenotallowed",
+	"internalerror",
+	"invalidcursor",
+	"invalidenum",
+	"invalidexpand",
+	"invalidfieldtype",
+	"invalidfilter",
+	"invalidinput",
+	"invalidparam",
+	"invalidpatchedresource",
+	"invalidselection",
+	"invalidsort",
+	"malformedbody",
+	"malformedmultipart",
+	"malformedpatch",
+	"missingparam",
+	"notacceptable",
+	"patchconflict",
+	"patchtestfailed",
+	"payloadtoolarge",
+	"preconditionfailed",
+	"readonlyfield",
+	"resourcenotfound",
+	"serviceunavailable",
+	"timeout",
+	"toomanyfiles",
+	"toomanyrequests",
+	"trailingdata",
+	"unauthorized",
+	"unknownfield",
+	"unsupportedencoding",
+	"unsupportedmediatype",
+	"uploadtoolarge",
+}
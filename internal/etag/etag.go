@@ -0,0 +1,110 @@
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// encodingSuffixes are appended to the ETag of a compressed representation by the compression
+// middleware, so it differs from the one of the uncompressed representation.
+var encodingSuffixes = []string{"-br", "-zstd", "-gzip", "-deflate"}
+
+// Compute returns an entity tag identifying the given encoded body. The tag is weak if weak is true.
+func Compute(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	return format(sum[:], weak)
+}
+
+// ComputeReader returns the tag Compute returns for the content read from r, without holding it in memory.
+func ComputeReader(r io.Reader, weak bool) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return format(hash.Sum(nil), weak), nil
+}
+
+// format returns the entity tag of the given SHA-256 sum.
+func format(sum []byte, weak bool) string {
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// Normalize turns an entity tag given by a handler into its header form, quoting it if needed.
+// For example, both v3 and "v3" become "v3", while W/"v3" is kept as is.
+func Normalize(tag string) string {
+	if strings.HasPrefix(tag, `"`) || strings.HasPrefix(tag, `W/"`) {
+		return tag
+	}
+	return `"` + tag + `"`
+}
+
+// WithEncoding returns the tag of the representation of tag compressed with the given encoding.
+func WithEncoding(tag string, encoding string) string {
+	if !strings.HasSuffix(tag, `"`) {
+		return tag
+	}
+	return strings.TrimSuffix(tag, `"`) + "-" + encoding + `"`
+}
+
+// MatchStrong reports whether tag matches any of the tags listed in header, an If-Match header,
+// using the strong comparison: both tags must be strong and equal.
+// A "*" header matches any tag.
+func MatchStrong(header string, tag string) bool {
+	return match(header, tag, true)
+}
+
+// MatchWeak reports whether tag matches any of the tags listed in header, an If-None-Match header,
+// using the weak comparison: tags are equal ignoring their weakness. As the server computes tags before
+// compressing, a listed tag also matches when it's the one the compression middleware derived from tag.
+// A "*" header matches any tag.
+func MatchWeak(header string, tag string) bool {
+	return match(header, tag, false)
+}
+
+// match compares tag with every tag listed in header. Encoding suffixes are only accepted by the weak
+// comparison, and only on the listed tags, so a tag which ends like one is never mistaken for another.
+func match(header string, tag string, strong bool) bool {
+	if tag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	weak, opaque := split(tag)
+	if strong && weak {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidateWeak, candidateOpaque := split(strings.TrimSpace(candidate))
+		if strong && candidateWeak {
+			continue
+		}
+		if candidateOpaque == opaque || (!strong && compressedFrom(candidateOpaque, opaque)) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressedFrom reports whether the opaque part of candidate is the one of tag with the suffix of
+// an encoding appended by WithEncoding.
+func compressedFrom(candidate string, opaque string) bool {
+	for _, suffix := range encodingSuffixes {
+		if candidate == opaque+suffix {
+			return true
+		}
+	}
+	return false
+}
+
+// split separates the weakness indicator of tag from its opaque part.
+func split(tag string) (bool, string) {
+	weak := strings.HasPrefix(tag, "W/")
+	return weak, strings.Trim(strings.TrimPrefix(tag, "W/"), `"`)
+}
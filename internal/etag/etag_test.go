@@ -0,0 +1,56 @@
+package etag
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMatch checks the strong and weak comparisons, including tags renamed by the compression middleware.
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		header string
+		tag    string
+		strong bool
+		weak   bool
+	}{
+		{`"a"`, `"a"`, true, true},
+		{`"b", "a"`, `"a"`, true, true},
+		{`W/"a"`, `"a"`, false, true},
+		{`"a"`, `W/"a"`, false, true},
+		{`"a-gzip"`, `"a"`, false, true},
+		{`W/"a-br"`, `W/"a"`, false, true},
+		{`"a"`, `"a-gzip"`, false, false},
+		{`"a-gzip"`, `"a-gzip"`, true, true},
+		{`"a-gzip-gzip"`, `"a"`, false, false},
+		{`"b"`, `"a"`, false, false},
+		{`*`, `"a"`, true, true},
+		{`*`, ``, false, false},
+	}
+	for _, c := range cases {
+		if MatchStrong(c.header, c.tag) != c.strong {
+			t.Errorf("Expected MatchStrong(%s, %s) to be %v", c.header, c.tag, c.strong)
+		}
+		if MatchWeak(c.header, c.tag) != c.weak {
+			t.Errorf("Expected MatchWeak(%s, %s) to be %v", c.header, c.tag, c.weak)
+		}
+	}
+}
+
+// TestNormalize checks unquoted tags provided by handlers are quoted.
+func TestNormalize(t *testing.T) {
+	for tag, expected := range map[string]string{`v3`: `"v3"`, `"v3"`: `"v3"`, `W/"v3"`: `W/"v3"`} {
+		if Normalize(tag) != expected {
+			t.Errorf("Expected %s, got %s", expected, Normalize(tag))
+		}
+	}
+}
+
+// TestComputeReader checks the tag of a streamed content is the one of the same body in memory.
+func TestComputeReader(t *testing.T) {
+	for _, weak := range []bool{false, true} {
+		tag, err := ComputeReader(strings.NewReader("body"), weak)
+		if err != nil || tag != Compute([]byte("body"), weak) {
+			t.Errorf("Expected %s, got %s (%v)", Compute([]byte("body"), weak), tag, err)
+		}
+	}
+}
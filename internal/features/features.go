@@ -0,0 +1,33 @@
+package features
+
+import (
+	"maps"
+	"sync/atomic"
+)
+
+// flags holds the feature flags in effect. The map is never modified once stored, so it can be read
+// without locking while it's being replaced.
+var flags atomic.Pointer[map[string]bool]
+
+// Set replaces the feature flags in effect, e.g. with the ones of a reloaded config.
+// Flags missing from the given ones are disabled.
+func Set(newFlags map[string]bool) {
+	newFlags = maps.Clone(newFlags)
+	flags.Store(&newFlags)
+}
+
+// Enabled reports whether the feature flag name is enabled. Unknown flags are disabled,
+// so a feature stays off until it's explicitly turned on.
+func Enabled(name string) bool {
+	current := flags.Load()
+	return current != nil && (*current)[name]
+}
+
+// Flags returns the feature flags in effect.
+func Flags() map[string]bool {
+	current := flags.Load()
+	if current == nil {
+		return map[string]bool{}
+	}
+	return maps.Clone(*current)
+}
@@ -0,0 +1,18 @@
+package features
+
+import "testing"
+
+// TestSet checks flags are replaced as a whole and unknown ones are disabled.
+func TestSet(t *testing.T) {
+	Set(map[string]bool{"search": true, "export": true})
+	Set(map[string]bool{"search": true, "beta": false})
+
+	for name, expected := range map[string]bool{"search": true, "export": false, "beta": false, "unknown": false} {
+		if got := Enabled(name); got != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, got)
+		}
+	}
+	if len(Flags()) != 2 {
+		t.Errorf("Expected 2 flags, got %v", Flags())
+	}
+}
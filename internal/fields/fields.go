@@ -0,0 +1,206 @@
+package fields
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/lucastomic/msBaseProj/internal/contextypes"
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// Query params read by Parse.
+const (
+	FieldsParam = "fields"
+	ExpandParam = "expand"
+)
+
+// Selection is the part of a resource a client asked for: the fields of its representation it wants
+// to receive and the related resources it wants to be included.
+type Selection struct {
+	// Fields are the paths of the selected fields, with nested ones separated by dots, like owner.name.
+	// They refer to the JSON names of the fields. Empty selects every field.
+	Fields []string
+	// Expand are the relations the client wants to be included in the response, like owner or owner.team.
+	Expand []string
+}
+
+// Expands reports whether the client asked to include the given relation, either directly or through
+// one of its nested relations, as expanding owner.team requires expanding owner.
+func (s Selection) Expands(relation string) bool {
+	for _, expanded := range s.Expand {
+		if expanded == relation || strings.HasPrefix(expanded, relation+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrapper is implemented by contents which wrap the resources a selection applies to, like the envelopes
+// of paginated responses. The selection is applied to the value held under the returned JSON name,
+// keeping the rest of the content untouched.
+type Wrapper interface {
+	// FieldsRoot returns the JSON name of the field holding the selectable resources.
+	FieldsRoot() string
+}
+
+// Parse reads the selection of r from the comma-separated fields and expand query params, which can be
+// repeated. allowed are the paths which can be selected, empty allowing any; selecting a path also
+// allows selecting the ones nested in it. expandable are the relations which can be expanded, empty
+// allowing none. Paths out of those lists are rejected with a translated errs.ErrInvalidInput.
+func Parse(r *http.Request, allowed []string, expandable []string) (Selection, error) {
+	query := r.URL.Query()
+	var sel Selection
+	for _, path := range splitPaths(query[FieldsParam]) {
+		if !validPath(path) || !allowedPath(allowed, path) {
+			err := errs.NewI18NError("field can't be selected: %w", fmt.Errorf("%q: %w", path, errs.ErrInvalidInput), "invalidselection")
+			return Selection{}, err.WithParam("field", path)
+		}
+		sel.Fields = append(sel.Fields, path)
+	}
+	for _, relation := range splitPaths(query[ExpandParam]) {
+		if !slices.Contains(expandable, relation) {
+			err := errs.NewI18NError("relation can't be expanded: %w", fmt.Errorf("%q: %w", relation, errs.ErrInvalidInput), "invalidexpand")
+			return Selection{}, err.WithParam("relation", relation)
+		}
+		sel.Expand = append(sel.Expand, relation)
+	}
+	return sel, nil
+}
+
+// WithSelection returns a copy of ctx carrying the given selection.
+func WithSelection(ctx context.Context, sel Selection) context.Context {
+	return context.WithValue(ctx, contextypes.ContextSelectionKey{}, sel)
+}
+
+// FromCtx returns the selection stored in ctx by WithSelection, or an empty one selecting every field
+// and expanding nothing if there is none.
+func FromCtx(ctx context.Context) Selection {
+	sel, _ := ctx.Value(contextypes.ContextSelectionKey{}).(Selection)
+	return sel
+}
+
+// Expanded reports whether the client of r asked to include the given relation, so handlers only
+// load the related resources which are going to be sent.
+func Expanded(r *http.Request, relation string) bool {
+	return FromCtx(r.Context()).Expands(relation)
+}
+
+// Project returns the part of content selected by sel, as the generic value of its JSON encoding
+// reduced to the selected fields. Objects keep the selected fields, arrays apply the selection to each
+// of their items and the selection of a Wrapper applies to its root. Expanded relations are kept even
+// when they aren't selected. content is returned as is when sel selects every field.
+// Numbers are kept as json.Number, so the projection is meant to be encoded as JSON.
+func Project(content any, sel Selection) (any, error) {
+	if len(sel.Fields) == 0 {
+		return content, nil
+	}
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	tree := tree{}
+	for _, path := range append(slices.Clone(sel.Fields), sel.Expand...) {
+		tree.add(path)
+	}
+	if wrapper, ok := content.(Wrapper); ok {
+		if object, ok := value.(map[string]any); ok {
+			if root, ok := object[wrapper.FieldsRoot()]; ok {
+				object[wrapper.FieldsRoot()] = tree.project(root)
+			}
+			return object, nil
+		}
+	}
+	return tree.project(value), nil
+}
+
+// tree holds the selected paths by their segments. A leaf selects the whole value under it.
+type tree map[string]tree
+
+// add adds the given dotted path to the tree. Paths nested in an already selected one are redundant.
+func (t tree) add(path string) {
+	node := t
+	for _, segment := range strings.Split(path, ".") {
+		child, ok := node[segment]
+		if ok && len(child) == 0 {
+			return
+		}
+		if !ok {
+			child = tree{}
+			node[segment] = child
+		}
+		node = child
+	}
+	clear(node)
+}
+
+// project reduces value to the fields selected by the tree.
+func (t tree) project(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		projected := make(map[string]any, len(t))
+		for name, child := range t {
+			field, ok := v[name]
+			if !ok {
+				continue
+			}
+			if len(child) == 0 {
+				projected[name] = field
+			} else {
+				projected[name] = child.project(field)
+			}
+		}
+		return projected
+	case []any:
+		projected := make([]any, len(v))
+		for i, item := range v {
+			projected[i] = t.project(item)
+		}
+		return projected
+	default:
+		return value
+	}
+}
+
+// splitPaths splits the comma-separated values of a query param, skipping empty and repeated ones.
+func splitPaths(values []string) []string {
+	var paths []string
+	for _, value := range values {
+		for _, path := range strings.Split(value, ",") {
+			path = strings.TrimSpace(path)
+			if path != "" && !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// validPath reports whether every segment of a dotted path is non-empty.
+func validPath(path string) bool {
+	return !slices.Contains(strings.Split(path, "."), "")
+}
+
+// allowedPath reports whether path is one of the allowed ones or nested in one of them.
+func allowedPath(allowed []string, path string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if path == a || strings.HasPrefix(path, a+".") {
+			return true
+		}
+	}
+	return false
+}
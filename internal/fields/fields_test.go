@@ -0,0 +1,95 @@
+package fields
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+type owner struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type boat struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Owner  *owner `json:"owner,omitempty"`
+	Length int64  `json:"length"`
+}
+
+type page struct {
+	Data  []boat `json:"data"`
+	Limit int    `json:"limit"`
+}
+
+func (page) FieldsRoot() string {
+	return "data"
+}
+
+// TestParse checks the selection is read from repeated and comma-separated params and restricted to the allow-lists.
+func TestParse(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/boats?fields=id,owner.name&fields=name,id&expand=owner", nil)
+	sel, err := Parse(req, []string{"id", "name", "owner"}, []string{"owner", "owner.team"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !slices.Equal(sel.Fields, []string{"id", "owner.name", "name"}) || !slices.Equal(sel.Expand, []string{"owner"}) {
+		t.Errorf("Unexpected selection %+v", sel)
+	}
+	if !sel.Expands("owner") || sel.Expands("owner.team") || !(Selection{Expand: []string{"owner.team"}}).Expands("owner") {
+		t.Errorf("Unexpected expansions of %+v", sel)
+	}
+
+	for url, code := range map[string]string{
+		"/boats?fields=length":       "invalidselection",
+		"/boats?fields=owner..name":  "invalidselection",
+		"/boats?expand=crew":         "invalidexpand",
+		"/boats?fields=id&expand=id": "invalidexpand",
+		"/boats?fields=%25d":         "invalidselection",
+		"/boats?expand=%25s%25w":     "invalidexpand",
+	} {
+		_, err := Parse(httptest.NewRequest(http.MethodGet, url, nil), []string{"id", "name", "owner"}, []string{"owner"})
+		i18n := errs.I18nError{}
+		if !errors.As(err, &i18n) || i18n.Code != code || !errors.Is(err, errs.ErrInvalidInput) {
+			t.Errorf("%s: expected a %s error, got %v", url, code, err)
+		}
+	}
+	if _, err := Parse(httptest.NewRequest(http.MethodGet, "/boats?fields=owner.email", nil), nil, nil); err != nil {
+		t.Errorf("Expected any field to be selectable without allow-list, got %v", err)
+	}
+}
+
+// TestProject checks the content is reduced to the selected fields, nested ones and expanded relations included,
+// applying to every item of arrays and to the root of wrappers.
+func TestProject(t *testing.T) {
+	b := boat{ID: 1, Name: "Nautilus", Owner: &owner{Name: "Nemo", Email: "nemo@sea"}, Length: 1 << 60}
+	for name, test := range map[string]struct {
+		content  any
+		sel      Selection
+		expected string
+	}{
+		"whole":     {b, Selection{}, `{"id":1,"name":"Nautilus","owner":{"name":"Nemo","email":"nemo@sea"},"length":1152921504606846976}`},
+		"fields":    {b, Selection{Fields: []string{"id", "length"}}, `{"id":1,"length":1152921504606846976}`},
+		"nested":    {b, Selection{Fields: []string{"name", "owner.name"}}, `{"name":"Nautilus","owner":{"name":"Nemo"}}`},
+		"redundant": {b, Selection{Fields: []string{"owner.name", "owner"}}, `{"owner":{"email":"nemo@sea","name":"Nemo"}}`},
+		"expanded":  {b, Selection{Fields: []string{"id"}, Expand: []string{"owner"}}, `{"id":1,"owner":{"email":"nemo@sea","name":"Nemo"}}`},
+		"missing":   {boat{ID: 2}, Selection{Fields: []string{"id", "owner.name"}}, `{"id":2}`},
+		"array":     {[]boat{b, {ID: 2}}, Selection{Fields: []string{"id"}}, `[{"id":1},{"id":2}]`},
+		"wrapper":   {page{Data: []boat{b}, Limit: 20}, Selection{Fields: []string{"name"}}, `{"data":[{"name":"Nautilus"}],"limit":20}`},
+	} {
+		projected, err := Project(test.content, test.sel)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+		encoded, _ := json.Marshal(projected)
+		if string(encoded) != test.expected {
+			t.Errorf("%s: expected %s, got %s", name, test.expected, encoded)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCheckTimeout bounds the checks which don't declare their own timeout.
+const DefaultCheckTimeout = 5 * time.Second
+
+// ErrShuttingDown is the reason readiness fails once the server starts shutting down.
+var ErrShuttingDown = errors.New("shutting down")
+
+// Status is the outcome of a check or of a whole report.
+type Status string
+
+const (
+	StatusPass Status = "pass" // StatusPass means everything is healthy.
+	StatusWarn Status = "warn" // StatusWarn means a non-critical check failed. The service is still ready.
+	StatusFail Status = "fail" // StatusFail means a critical check failed or the service is shutting down.
+)
+
+// Check is a named dependency check, like pinging a database or a downstream service.
+type Check struct {
+	Name     string                          // Name identifies the check in reports.
+	Func     func(ctx context.Context) error // Func runs the check, returning why it failed if it did.
+	Timeout  time.Duration                   // Timeout bounds Func. DefaultCheckTimeout when zero.
+	Critical bool                            // Critical makes readiness fail when the check fails. Otherwise, it only warns.
+	CacheTTL time.Duration                   // CacheTTL is how long a result is reused, sparing dependencies from every probe. Zero disables caching.
+}
+
+// Result is the outcome of running a check.
+type Result struct {
+	Status    Status        `json:"status"`
+	Critical  bool          `json:"critical"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"durationNs"`
+	CheckedAt time.Time     `json:"checkedAt"`
+}
+
+// Report is the detailed outcome of a liveness or readiness probe.
+type Report struct {
+	Status Status            `json:"status"`
+	Reason string            `json:"reason,omitempty"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// HealthChecker is the registry of the checks which decide whether the service is ready.
+// Liveness only tells the process is up and serving, so it never runs the checks: a failing dependency
+// shouldn't get the service restarted.
+type HealthChecker struct {
+	mu           sync.RWMutex
+	checks       []*registeredCheck
+	shuttingDown atomic.Bool
+}
+
+// registeredCheck is a check along with its cached result.
+type registeredCheck struct {
+	Check
+	mu     sync.Mutex // mu serializes the runs, so concurrent probes share a single one.
+	result Result
+}
+
+// NewHealthChecker creates a HealthChecker without checks.
+func NewHealthChecker() *HealthChecker {
+	return &HealthChecker{}
+}
+
+// Register adds a check, replacing the one with the same name if there is one.
+func (h *HealthChecker) Register(check Check) error {
+	if check.Name == "" || check.Func == nil {
+		return errors.New("health checks need a name and a function")
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultCheckTimeout
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, registered := range h.checks {
+		if registered.Name == check.Name {
+			h.checks[i] = &registeredCheck{Check: check}
+			return nil
+		}
+	}
+	h.checks = append(h.checks, &registeredCheck{Check: check})
+	return nil
+}
+
+// SetShuttingDown makes readiness fail from now on, so orchestrators stop routing traffic to the service
+// while in-flight requests finish.
+func (h *HealthChecker) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Live returns the liveness report.
+func (h *HealthChecker) Live() Report {
+	return Report{Status: StatusPass}
+}
+
+// Ready runs the checks concurrently, reusing the cached results which are still fresh, and returns
+// the readiness report. It fails if the service is shutting down or any critical check failed, and warns
+// if any other check failed.
+func (h *HealthChecker) Ready(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := append([]*registeredCheck(nil), h.checks...)
+	h.mu.RUnlock()
+
+	report := Report{Status: StatusPass, Checks: make(map[string]Result, len(checks))}
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	failed := []string{}
+	for i, check := range checks {
+		result := results[i]
+		report.Checks[check.Name] = result
+		if result.Status == StatusPass {
+			continue
+		}
+		if check.Critical {
+			report.Status = StatusFail
+			failed = append(failed, check.Name)
+		} else if report.Status == StatusPass {
+			report.Status = StatusWarn
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		report.Reason = fmt.Sprintf("critical checks failed: %v", failed)
+	}
+	if h.shuttingDown.Load() {
+		report.Status, report.Reason = StatusFail, ErrShuttingDown.Error()
+	}
+	return report
+}
+
+// run returns the cached result if it's still fresh, or runs the check bounded by its timeout otherwise.
+// A check which panics counts as failed. Failures caused by the caller's context being canceled, like
+// a probe whose client went away, aren't cached, as they say nothing about the check.
+func (c *registeredCheck) run(caller context.Context) (result Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.result.CheckedAt.IsZero() && time.Since(c.result.CheckedAt) < c.CacheTTL {
+		return c.result
+	}
+
+	ctx, cancel := context.WithTimeout(caller, c.Timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- c.Func(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+
+	result = Result{Status: StatusPass, Critical: c.Critical, Duration: time.Since(start), CheckedAt: start}
+	if err != nil {
+		result.Status, result.Error = StatusFail, err.Error()
+		if caller.Err() != nil {
+			return result
+		}
+	}
+	c.result = result
+	return result
+}
+
+// LivenessHandler returns the http.Handler answering liveness probes with a 200 and the report.
+func (h *HealthChecker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, h.Live())
+	})
+}
+
+// ReadinessHandler returns the http.Handler answering readiness probes with the report, with a 503
+// when the service isn't ready and a 200 otherwise.
+func (h *HealthChecker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, h.Ready(r.Context()))
+	})
+}
+
+// writeReport writes the report as JSON, with the status code matching its status.
+// Probe responses are never cached, as they must reflect the current state.
+func writeReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if report.Status == StatusFail {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestReady checks failing critical checks make readiness fail while other ones only warn.
+func TestReady(t *testing.T) {
+	checker := NewHealthChecker()
+	ok := func(context.Context) error { return nil }
+	broken := func(context.Context) error { return errors.New("connection refused") }
+	checker.Register(Check{Name: "db", Func: ok, Critical: true})
+	checker.Register(Check{Name: "search", Func: broken})
+
+	report := checker.Ready(context.Background())
+	if report.Status != StatusWarn || report.Checks["search"].Error != "connection refused" {
+		t.Errorf("Expected a failing non-critical check to warn, got %+v", report)
+	}
+
+	checker.Register(Check{Name: "db", Func: broken, Critical: true})
+	if report := checker.Ready(context.Background()); report.Status != StatusFail {
+		t.Errorf("Expected a failing critical check to fail readiness, got %+v", report)
+	}
+}
+
+// TestCheckTimeoutAndCache checks slow checks fail once their timeout expires and that results are
+// reused while they're fresh.
+func TestCheckTimeoutAndCache(t *testing.T) {
+	checker := NewHealthChecker()
+	var runs atomic.Int32
+	checker.Register(Check{
+		Name:     "downstream",
+		Critical: true,
+		Timeout:  10 * time.Millisecond,
+		CacheTTL: time.Minute,
+		Func: func(ctx context.Context) error {
+			runs.Add(1)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	report := checker.Ready(context.Background())
+	if report.Status != StatusFail || !strings.Contains(report.Checks["downstream"].Error, "timed out") {
+		t.Errorf("Expected the check to time out, got %+v", report)
+	}
+	checker.Ready(context.Background())
+	if runs.Load() != 1 {
+		t.Errorf("Expected the cached result to be reused, got %d runs", runs.Load())
+	}
+}
+
+// TestCanceledProbeNotCached checks a check failing because the caller went away isn't cached, so the
+// next probe runs it again.
+func TestCanceledProbeNotCached(t *testing.T) {
+	checker := NewHealthChecker()
+	checker.Register(Check{
+		Name:     "db",
+		Critical: true,
+		CacheTTL: time.Minute,
+		Func:     func(ctx context.Context) error { return ctx.Err() },
+	})
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := checker.Ready(canceled); report.Status != StatusFail {
+		t.Errorf("Expected the canceled probe to fail, got %+v", report)
+	}
+	if report := checker.Ready(context.Background()); report.Status != StatusPass {
+		t.Errorf("Expected the check to run again and pass, got %+v", report)
+	}
+}
+
+// TestShuttingDown checks readiness fails once the service is shutting down, while liveness keeps passing.
+func TestShuttingDown(t *testing.T) {
+	checker := NewHealthChecker()
+	checker.SetShuttingDown()
+
+	for _, test := range []struct {
+		handler  http.Handler
+		expected int
+	}{
+		{checker.LivenessHandler(), http.StatusOK},
+		{checker.ReadinessHandler(), http.StatusServiceUnavailable},
+	} {
+		w := httptest.NewRecorder()
+		test.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != test.expected {
+			t.Errorf("Expected status code %v, got %v with %s", test.expected, w.Code, w.Body.String())
+		}
+	}
+}
@@ -24,3 +24,10 @@ type Logger interface {
 	// making it suitable for reporting issues with detailed context.
 	Error(ctx context.Context, format string, a ...any)
 }
+
+// LevelSetter is implemented by the loggers whose level can be changed while the application runs,
+// e.g. to debug a running service without restarting it.
+type LevelSetter interface {
+	// SetLevel sets the lowest level logged: debug, info, warn or error.
+	SetLevel(level string) error
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/lucastomic/msBaseProj/internal/contextypes"
@@ -25,6 +26,64 @@ func NewLogrusLogger() Logger {
 	}
 }
 
+// Options configures a LogrusLogger.
+type Options struct {
+	Level  string // Level is the lowest level logged: debug, info, warn or error. Info when empty.
+	Format string // Format is the format of the entries: text or json. Text when empty.
+	File   string // File is a file where entries are logged too, created if needed. Only the terminal when empty.
+}
+
+// NewConfiguredLogrusLogger initializes a new LogrusLogger logging to the terminal and, if set, to the options' file,
+// with the given level and format.
+func NewConfiguredLogrusLogger(opts Options) (Logger, error) {
+	level := logrus.InfoLevel
+	if opts.Level != "" {
+		parsed, err := logrus.ParseLevel(opts.Level)
+		if err != nil {
+			return nil, err
+		}
+		level = parsed
+	}
+	var formatter logrus.Formatter
+	switch opts.Format {
+	case "", "text":
+		formatter = &logrus.TextFormatter{}
+	case "json":
+		formatter = &logrus.JSONFormatter{}
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	ttyLogger := logrus.New()
+	loggers := []*logrus.Logger{ttyLogger}
+	if opts.File != "" {
+		file, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		fileLogger := logrus.New()
+		fileLogger.SetOutput(file)
+		loggers = append(loggers, fileLogger)
+	}
+	for _, logger := range loggers {
+		logger.SetLevel(level)
+		logger.SetFormatter(formatter)
+	}
+	return &LogrusLogger{loggers: loggers}, nil
+}
+
+// SetLevel sets the lowest level logged by all configured loggers. It's safe to call while they're logging.
+func (l *LogrusLogger) SetLevel(level string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	for _, logger := range l.loggers {
+		logger.SetLevel(parsed)
+	}
+	return nil
+}
+
 // Request logs information about an HTTP request to all configured loggers.
 // It formats the log message with details including the timestamp, URI, method, user agent, status code, and duration.
 // The RequestID from the context, if present, is included as a field in the log entry.
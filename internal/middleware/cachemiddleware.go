@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/cache"
+	"github.com/lucastomic/msBaseProj/internal/contextypes"
+	"github.com/lucastomic/msBaseProj/internal/controller/apitypes"
+	"github.com/lucastomic/msBaseProj/internal/etag"
+)
+
+// cacheMiddleware serves the responses of a GET route from a cache.Store while they're fresh.
+// Expired responses are still served during the stale-while-revalidate window while a single request
+// refreshes them in background.
+type cacheMiddleware struct {
+	store       cache.Store          // store is where the responses are kept.
+	policy      apitypes.CachePolicy // policy defines the TTLs and the query params the key is made of.
+	queryParams []string             // queryParams are the policy's query params, sorted.
+	refreshing  *sync.Map            // refreshing holds the keys being revalidated in background.
+}
+
+// NewCacheMiddleware creates a middleware caching the responses of a route in store following policy.
+// Requests sent with "Cache-Control: no-cache" skip the lookup, refreshing the cached response, and requests
+// sent with "Cache-Control: no-store" bypass the cache completely. Responses are stored only if they're
+// a 200 without cookies whose Cache-Control doesn't forbid it, and their max-age overrides the policy's TTL.
+func NewCacheMiddleware(store cache.Store, policy apitypes.CachePolicy) Middleware {
+	queryParams := append([]string{}, policy.QueryParams...)
+	sort.Strings(queryParams)
+	return cacheMiddleware{store, policy, queryParams, &sync.Map{}}
+}
+
+// Execute wraps the next http.HandlerFunc in the middleware chain.
+// Every served response carries an X-Cache header telling whether it was a HIT, a STALE hit or a MISS,
+// and cached ones an Age header. Conditional headers are evaluated against the cached response, so clients
+// holding an up to date copy get a 304.
+func (c cacheMiddleware) Execute(
+	next http.HandlerFunc,
+	errorHandler errorHandler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || c.policy.TTL <= 0 {
+			next(w, r)
+			return
+		}
+		directives := parseCacheControl(r.Header.Get("Cache-Control"))
+		if _, ok := directives["no-store"]; ok {
+			next(w, r)
+			return
+		}
+
+		key, ok := c.key(r)
+		if !ok {
+			next(w, r)
+			return
+		}
+		if _, ok := directives["no-cache"]; !ok {
+			if entry, ok := c.store.Get(key); ok {
+				if entry.Fresh(time.Now()) {
+					serveCached(w, r, entry, "HIT")
+					return
+				}
+				c.revalidate(r, key, next)
+				serveCached(w, r, entry, "STALE")
+				return
+			}
+		}
+
+		generation := c.store.Generation()
+		entry, ok := c.fetch(r, next)
+		if ok {
+			c.store.SetIfGeneration(key, entry, generation)
+		}
+		serveCached(w, r, entry, "MISS")
+	}
+}
+
+// key builds the cache key of the request: the method and path followed by the selected query params,
+// the Accept and Accept-Language headers and the authenticated principal's identifier.
+// HEAD requests share the key of their GET counterpart. The boolean is false when the request has a principal
+// which can't be identified, whose responses aren't cached so nobody is sent a response meant for somebody else.
+func (c cacheMiddleware) key(r *http.Request) (string, bool) {
+	principal, ok := contextypes.PrincipalID(r.Context())
+	if !ok {
+		return "", false
+	}
+	var b strings.Builder
+	b.WriteString(http.MethodGet + " " + r.URL.Path)
+	query := r.URL.Query()
+	sep := "?"
+	for _, param := range c.queryParams {
+		for _, value := range query[param] {
+			b.WriteString(sep + url.QueryEscape(param) + "=" + url.QueryEscape(value))
+			sep = "&"
+		}
+	}
+	fmt.Fprintf(&b, "|%s|%s|%s", r.Header.Get("Accept"), r.Header.Get("Accept-Language"), strconv.Quote(principal))
+	return b.String(), true
+}
+
+// fetch runs the handler without the request's conditional headers, so the full response is generated,
+// and returns it as an entry. The boolean reports whether the response can be stored.
+func (c cacheMiddleware) fetch(r *http.Request, next http.HandlerFunc) (cache.Entry, bool) {
+	req := r.Clone(r.Context())
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	rec := &cacheRecorder{header: http.Header{}, status: http.StatusOK}
+	start := time.Now()
+	next(rec, req)
+
+	entry := cache.Entry{Status: rec.status, Header: rec.header, Body: rec.body.Bytes(), StoredAt: start}
+	directives := parseCacheControl(rec.header.Get("Cache-Control"))
+	_, noStore := directives["no-store"]
+	_, private := directives["private"]
+	_, noCache := directives["no-cache"]
+	if rec.status != http.StatusOK || noStore || private || noCache || rec.header.Get("Set-Cookie") != "" {
+		return entry, false
+	}
+	ttl := c.policy.TTL
+	if maxAge, ok := directives["s-maxage"]; ok {
+		ttl = parseSeconds(maxAge, ttl)
+	} else if maxAge, ok := directives["max-age"]; ok {
+		ttl = parseSeconds(maxAge, ttl)
+	}
+	entry.Expires = start.Add(ttl)
+	entry.StaleUntil = entry.Expires.Add(c.policy.StaleWhileRevalidate)
+	return entry, ttl > 0
+}
+
+// revalidate refreshes the entry stored under key in background, unless it's already being refreshed.
+// The refresh outlives the request, so it runs with a context which isn't canceled with it. The refreshed
+// entry isn't stored if the cache was invalidated meanwhile, as it could hold the data from before.
+func (c cacheMiddleware) revalidate(r *http.Request, key string, next http.HandlerFunc) {
+	if _, loaded := c.refreshing.LoadOrStore(key, true); loaded {
+		return
+	}
+	generation := c.store.Generation()
+	req := r.Clone(context.WithoutCancel(r.Context()))
+	go func() {
+		defer c.refreshing.Delete(key)
+		if entry, ok := c.fetch(req, next); ok {
+			c.store.SetIfGeneration(key, entry, generation)
+		}
+	}()
+}
+
+// serveCached writes the entry to w, answering with a 304 if the request's conditional headers match it.
+// The state is sent in the X-Cache header, along with the entry's Age unless it was just generated.
+func serveCached(w http.ResponseWriter, r *http.Request, entry cache.Entry, state string) {
+	for key, values := range entry.Header {
+		if key == "Vary" {
+			w.Header()[key] = append(w.Header()[key], values...)
+		} else {
+			w.Header()[key] = append([]string(nil), values...)
+		}
+	}
+	w.Header().Set("X-Cache", state)
+	if state != "MISS" {
+		w.Header().Set("Age", strconv.Itoa(int(time.Since(entry.StoredAt).Seconds())))
+	}
+	if entry.Status == http.StatusOK && notModified(r, entry.Header) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(entry.Status)
+	_, _ = w.Write(entry.Body)
+}
+
+// notModified reports whether the request's conditional headers match a response with the given headers.
+func notModified(r *http.Request, header http.Header) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etag.MatchWeak(ifNoneMatch, header.Get("ETag"))
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	return err == nil && !lastModified.After(ifModifiedSince)
+}
+
+// parseCacheControl parses a Cache-Control header into its directives and their values, if any.
+func parseCacheControl(header string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
+
+// parseSeconds parses a delta-seconds directive value, returning fallback if it's invalid.
+func parseSeconds(value string, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// cacheRecorder is an http.ResponseWriter which keeps the response in memory so it can be cached.
+type cacheRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// Header returns the headers of the recorded response.
+func (c *cacheRecorder) Header() http.Header {
+	return c.header
+}
+
+// WriteHeader records the status code of the response. Only the first call has effect.
+func (c *cacheRecorder) WriteHeader(code int) {
+	if c.wroteHeader {
+		return
+	}
+	c.status = code
+	c.wroteHeader = true
+}
+
+// Write appends p to the recorded body.
+func (c *cacheRecorder) Write(p []byte) (int, error) {
+	c.wroteHeader = true
+	return c.body.Write(p)
+}
@@ -0,0 +1,359 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+	"github.com/lucastomic/msBaseProj/internal/etag"
+)
+
+// DefaultCompressionMinSize is the minimum response size in bytes compressed by the compression middleware
+// when no other size is given. Smaller responses don't make up for the compression overhead.
+const DefaultCompressionMinSize = 1024
+
+// compressibleTypes are the media types worth compressing. Binary formats which are already compact,
+// like images or MessagePack, are left out.
+var compressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/*+xml",
+	"application/yaml",
+	"application/x-yaml",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+// compressor is the common interface of the writers of every supported encoding.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressionMiddleware compresses responses with the encoding negotiated from the Accept-Encoding header
+// and transparently decompresses gzip encoded request bodies.
+type compressionMiddleware struct {
+	minSize   int                   // minSize is the minimum size of the responses to compress.
+	encodings []string              // encodings are the supported encodings in order of preference.
+	pools     map[string]*sync.Pool // pools reuse the compressors of every encoding between requests.
+}
+
+// NewCompressionMiddleware creates a middleware compressing responses of at least minSize bytes
+// (DefaultCompressionMinSize when minSize is zero) with one of the given encodings, in order of preference.
+// Supported encodings are "br", "zstd", "gzip" and "deflate"; without arguments all of them are used.
+func NewCompressionMiddleware(minSize int, encodings ...string) Middleware {
+	if minSize == 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	if len(encodings) == 0 {
+		encodings = []string{"br", "zstd", "gzip", "deflate"}
+	}
+	pools := make(map[string]*sync.Pool, len(encodings))
+	for _, encoding := range encodings {
+		pools[encoding] = &sync.Pool{New: newCompressor(encoding)}
+	}
+	return compressionMiddleware{minSize, encodings, pools}
+}
+
+// newCompressor returns a constructor for the compressors of the given encoding.
+// It panics if the encoding isn't supported, as that is a programming error.
+func newCompressor(encoding string) func() any {
+	switch encoding {
+	case "br":
+		return func() any { return brotli.NewWriterLevel(nil, brotli.DefaultCompression) }
+	case "zstd":
+		return func() any {
+			w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+			return w
+		}
+	case "gzip":
+		return func() any { return gzip.NewWriter(nil) }
+	case "deflate":
+		return func() any {
+			w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+			return w
+		}
+	default:
+		panic("unsupported compression encoding " + encoding)
+	}
+}
+
+// Execute wraps the next http.HandlerFunc in the middleware chain.
+// Gzip encoded request bodies are replaced by their decompressed stream, and any other request encoding
+// is rejected with a 415. The response writer is wrapped so the response is compressed once it's known
+// to be large enough and of a compressible type. HEAD requests and connection upgrades are left untouched.
+func (c compressionMiddleware) Execute(
+	next http.HandlerFunc,
+	errorHandler errorHandler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch strings.ToLower(r.Header.Get("Content-Encoding")) {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			body, err := gzip.NewReader(r.Body)
+			if err != nil {
+				errorHandler(r, w, errs.NewI18NError("invalid gzip body: %w", errs.ErrInvalidInput, "malformedbody"), http.StatusBadRequest)
+				return
+			}
+			defer body.Close()
+			r.Body = body
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+		default:
+			err := errs.NewI18NError("content encoding not supported: %w", errs.ErrUnsupportedMediaType, "unsupportedencoding")
+			errorHandler(r, w, err, http.StatusUnsupportedMediaType)
+			return
+		}
+
+		if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := NegotiateEncoding(r.Header.Get("Accept-Encoding"), c.encodings)
+		if encoding == "" {
+			next(w, r)
+			return
+		}
+		cw := &compressResponseWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			pool:           c.pools[encoding],
+			minSize:        c.minSize,
+			status:         http.StatusOK,
+		}
+		defer cw.close()
+		next(cw, r)
+	}
+}
+
+// compressResponseWriter buffers the beginning of the response until it can decide whether to compress it:
+// when minSize bytes have been written, the response is flushed or the handler returns.
+// From then on writes go either through the compressor or straight to the wrapped writer.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string     // encoding is the negotiated Content-Encoding.
+	pool        *sync.Pool // pool is where the compressor is taken from and returned to.
+	minSize     int        // minSize is the minimum size of the responses to compress.
+	status      int        // status is the status code set by the handler.
+	wroteHeader bool       // wroteHeader reports whether the handler set the status code.
+	decided     bool       // decided reports whether the headers were sent and buf flushed.
+	compressor  compressor // compressor is non-nil when the response is being compressed.
+	buf         []byte     // buf holds the writes made before deciding.
+}
+
+// WriteHeader records the status code. It's sent when the writer decides whether to compress,
+// as the Content-Encoding header must be set before. Informational codes, like 103 Early Hints,
+// aren't final, so they are sent straight away.
+func (cw *compressResponseWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.wroteHeader || cw.decided {
+		return
+	}
+	cw.status = code
+	cw.wroteHeader = true
+}
+
+// Write buffers p until minSize bytes are reached and writes it through the compressor, if any, afterwards.
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.compressor != nil {
+		return cw.compressor.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush sends everything written so far to the client. A response flushed before reaching minSize is
+// considered a stream and compressed if its type allows it, since its final size is unknown.
+func (cw *compressResponseWriter) Flush() {
+	if !cw.decided {
+		if err := cw.decide(true); err != nil {
+			return
+		}
+	}
+	if cw.compressor != nil {
+		if err := cw.compressor.Flush(); err != nil {
+			return
+		}
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// ReadFrom copies r to the response. Once the beginning decided the response isn't compressed, the rest
+// goes through the wrapped writer's io.ReaderFrom if it has one, so zero-copy transfers like sendfile still apply.
+func (cw *compressResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	if !cw.decided {
+		copied, err := io.CopyN(writerOnly{cw}, r, int64(max(cw.minSize, 1)))
+		n += copied
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	if rf, ok := cw.ResponseWriter.(io.ReaderFrom); ok && cw.decided && cw.compressor == nil {
+		copied, err := rf.ReadFrom(r)
+		return n + copied, err
+	}
+	copied, err := io.Copy(writerOnly{cw}, r)
+	return n + copied, err
+}
+
+// writerOnly hides the io.ReaderFrom of a writer, so io.Copy doesn't call it back.
+type writerOnly struct {
+	io.Writer
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, so http.ResponseController can reach its features.
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide sends the headers, compressing the response if large is true and the response is compressible,
+// and writes the buffered bytes.
+func (cw *compressResponseWriter) decide(large bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if large && cw.compressible() {
+		cw.compressor = cw.pool.Get().(compressor)
+		cw.compressor.Reset(cw.ResponseWriter)
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if tag := h.Get("ETag"); tag != "" {
+			h.Set("ETag", etag.WithEncoding(tag, cw.encoding))
+		}
+	}
+	if cw.wroteHeader || len(cw.buf) > 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	if cw.compressor != nil {
+		_, err := cw.compressor.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// compressible reports whether the response can be compressed: it must have a body, not be encoded
+// already, not be a partial response and be of a compressible type.
+func (cw *compressResponseWriter) compressible() bool {
+	h := cw.Header()
+	if cw.status < http.StatusOK || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified ||
+		cw.status == http.StatusPartialContent {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, pattern := range compressibleTypes {
+		if matchCompressibleType(pattern, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// close finishes the response once the handler returns, deciding whether to compress if it wasn't done
+// yet and returning the compressor to its pool.
+func (cw *compressResponseWriter) close() {
+	if !cw.decided {
+		_ = cw.decide(false)
+	}
+	if cw.compressor != nil {
+		_ = cw.compressor.Close()
+		cw.compressor.Reset(nil)
+		cw.pool.Put(cw.compressor)
+		cw.compressor = nil
+	}
+}
+
+// matchCompressibleType reports whether mediaType matches a compressibleTypes pattern, which may use
+// a wildcard subtype ("text/*") or a wildcard before a structured syntax suffix ("application/*+json").
+func matchCompressibleType(pattern string, mediaType string) bool {
+	typ, subtype, _ := strings.Cut(pattern, "/")
+	mtType, mtSubtype, _ := strings.Cut(mediaType, "/")
+	if typ != mtType {
+		return false
+	}
+	if subtype == "*" || subtype == mtSubtype {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(subtype, "*"); ok {
+		return strings.HasSuffix(mtSubtype, suffix)
+	}
+	return false
+}
+
+// NegotiateEncoding selects the encoding among the supported ones which best satisfies the given
+// Accept-Encoding header, following its q-values and the order of supported on ties.
+// Returns an empty string if the response should be sent uncompressed.
+func NegotiateEncoding(acceptEncoding string, supported []string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		weights[strings.ToLower(name)] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range supported {
+		q, ok := weights[encoding]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
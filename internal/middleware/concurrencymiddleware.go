@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/concurrency"
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// concurrencyMiddleware bounds the number of requests handled at once with a concurrency.Limiter,
+// shedding the requests which can't get a slot with a fast 503 instead of letting them pile up.
+type concurrencyMiddleware struct {
+	limiter    *concurrency.Limiter // limiter holds the slots.
+	priority   int                  // priority is the priority of the requests in the limiter's wait queue.
+	retryAfter time.Duration        // retryAfter is sent to the shed clients as the time to wait before retrying.
+}
+
+// NewConcurrencyMiddleware creates a middleware taking a slot of limiter for every request, waiting in its
+// queue with the given priority when none is free. Shed clients are told to retry after retryAfter.
+func NewConcurrencyMiddleware(limiter *concurrency.Limiter, priority int, retryAfter time.Duration) Middleware {
+	return concurrencyMiddleware{limiter, priority, retryAfter}
+}
+
+// Execute wraps the next http.HandlerFunc in the middleware chain.
+// Requests which can't get a slot are reported through the errorHandler as translatable
+// errs.ErrServiceUnavailable errors with a Retry-After header. Responses with a 503 or 504 status count
+// as dropped, making an adaptive limit shrink.
+func (c concurrencyMiddleware) Execute(
+	next http.HandlerFunc,
+	errorHandler errorHandler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := c.limiter.Acquire(r.Context(), c.priority)
+		if err != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(c.retryAfter))))
+			err := errs.NewI18NError("request shed: %w", errs.ErrServiceUnavailable, "serviceunavailable")
+			errorHandler(r, w, err, http.StatusServiceUnavailable)
+			return
+		}
+		lrw := newLoggingResponseWriter(w)
+		defer func() {
+			token.Release(lrw.statusCode == http.StatusServiceUnavailable || lrw.statusCode == http.StatusGatewayTimeout)
+		}()
+		next(lrw, r)
+	}
+}
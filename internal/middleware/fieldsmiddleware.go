@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/lucastomic/msBaseProj/internal/fields"
+)
+
+// fieldsMiddleware reads the fields and related resources selected by the client through the fields and
+// expand query params, so the server trims the response to the selected fields and handlers know which
+// relations to include. Selections out of the route's allow-lists are rejected with a 400 through the errorHandler.
+type fieldsMiddleware struct {
+	allowed    []string // allowed are the paths of the fields which can be selected. Empty allows any.
+	expandable []string // expandable are the relations which can be expanded. Empty allows none.
+}
+
+// NewFieldsMiddleware creates a middleware which reads the selection of the requests, only allowing the
+// given fields and relations to be selected. See fields.Parse.
+func NewFieldsMiddleware(allowed []string, expandable []string) Middleware {
+	return fieldsMiddleware{allowed, expandable}
+}
+
+// Execute wraps the next http.HandlerFunc in the middleware chain.
+// The selection is stored in the request's context, where fields.FromCtx finds it.
+func (f fieldsMiddleware) Execute(
+	next http.HandlerFunc,
+	errorHandler errorHandler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sel, err := fields.Parse(r, f.allowed, f.expandable)
+		if err != nil {
+			errorHandler(r, w, err, http.StatusBadRequest)
+			return
+		}
+		next(w, r.WithContext(fields.WithSelection(r.Context(), sel)))
+	}
+}
@@ -1,9 +1,31 @@
 package middleware
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/cache"
+	"github.com/lucastomic/msBaseProj/internal/concurrency"
+	"github.com/lucastomic/msBaseProj/internal/contextypes"
+	"github.com/lucastomic/msBaseProj/internal/controller/apitypes"
+	"github.com/lucastomic/msBaseProj/internal/fields"
+	"github.com/lucastomic/msBaseProj/internal/logging"
+	"github.com/lucastomic/msBaseProj/internal/ratelimit"
+	"github.com/lucastomic/msBaseProj/internal/tlsconfig"
 )
 
 // TestRequestIDMiddlewareWithHeader tests the requestIDMiddleware ensuring it passes the request through
@@ -70,3 +92,522 @@ func TestRequestIDMiddlewareWithoutHeader(t *testing.T) {
 		t.Errorf("Error handler was not called when X-Request-ID is missing")
 	}
 }
+
+// TestBodyLimitMiddlewareTooLarge tests the bodyLimitMiddleware rejects a request whose declared
+// Content-Length exceeds the limit with a 413.
+func TestBodyLimitMiddlewareTooLarge(t *testing.T) {
+	middleware := NewBodyLimitMiddleware(4, "application/json")
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Next handler should not be called when the body is too large")
+	})
+	statusCode := 0
+	errorHandler := func(r *http.Request, w http.ResponseWriter, err error, code int) {
+		statusCode = code
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	middleware.Execute(next, errorHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if statusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status code 413, got %v", statusCode)
+	}
+}
+
+// TestBodyLimitMiddlewareContentType tests the bodyLimitMiddleware rejects bodies with an unaccepted
+// Content-Type with a 415, while accepting parameters and wildcard subtypes.
+func TestBodyLimitMiddlewareContentType(t *testing.T) {
+	middleware := NewBodyLimitMiddleware(1024, "application/json", "multipart/*")
+	contentTypes := map[string]int{
+		"application/json; charset=utf-8":   0,
+		"multipart/form-data; boundary=abc": 0,
+		"text/plain":                        http.StatusUnsupportedMediaType,
+		"":                                  http.StatusUnsupportedMediaType,
+	}
+	for contentType, expected := range contentTypes {
+		statusCode := 0
+		errorHandler := func(r *http.Request, w http.ResponseWriter, err error, code int) {
+			statusCode = code
+		}
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", contentType)
+		middleware.Execute(next, errorHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+		if statusCode != expected {
+			t.Errorf("Expected status code %v for %q, got %v", expected, contentType, statusCode)
+		}
+	}
+}
+
+// TestCompressionMiddleware tests responses are compressed with the negotiated encoding only when they
+// reach the minimum size and weren't encoded already.
+func TestCompressionMiddleware(t *testing.T) {
+	middleware := NewCompressionMiddleware(16)
+	errorHandler := func(r *http.Request, w http.ResponseWriter, err error, statusCode int) {
+		t.Errorf("errorHandler should not be called, got %v", err)
+	}
+	cases := []struct {
+		body     string
+		encoded  bool
+		expected string
+	}{
+		{strings.Repeat("a", 64), false, "gzip"},
+		{"short", false, ""},
+		{strings.Repeat("a", 64), true, "br"},
+	}
+	for _, c := range cases {
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if c.encoded {
+				w.Header().Set("Content-Encoding", "br")
+			}
+			w.Write([]byte(c.body))
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate;q=0.5")
+		w := httptest.NewRecorder()
+		middleware.Execute(next, errorHandler).ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != c.expected {
+			t.Errorf("Expected Content-Encoding %q, got %q", c.expected, w.Header().Get("Content-Encoding"))
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Expected Vary to be Accept-Encoding, got %q", w.Header().Get("Vary"))
+		}
+		body := w.Body.String()
+		if c.expected == "gzip" {
+			reader, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			decompressed, _ := io.ReadAll(reader)
+			body = string(decompressed)
+		}
+		if body != c.body {
+			t.Errorf("Expected body %q, got %q", c.body, body)
+		}
+	}
+}
+
+// TestCompressionMiddlewareEarlyHints tests informational responses are sent before the final one, whose
+// status isn't replaced by them.
+func TestCompressionMiddlewareEarlyHints(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</app.css>; rel=preload")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(strings.Repeat("a", 64)))
+	})
+	ts := httptest.NewServer(NewCompressionMiddleware(16).Execute(next, nil))
+	defer ts.Close()
+
+	var informational []int
+	trace := &httptrace.ClientTrace{Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+		informational = append(informational, code)
+		return nil
+	}}
+	req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, ts.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(informational) != 1 || informational[0] != http.StatusEarlyHints {
+		t.Errorf("Expected a 103 before the response, got %v", informational)
+	}
+	if res.StatusCode != http.StatusCreated || res.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected a compressed 201, got %d with %q", res.StatusCode, res.Header.Get("Content-Encoding"))
+	}
+}
+
+// TestCompressionMiddlewareRequest tests gzip encoded request bodies are decompressed before reaching the handler.
+func TestCompressionMiddlewareRequest(t *testing.T) {
+	middleware := NewCompressionMiddleware(0)
+	var received string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	})
+	errorHandler := func(r *http.Request, w http.ResponseWriter, err error, statusCode int) {
+		t.Errorf("errorHandler should not be called, got %v", err)
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`{"name":"boat"}`))
+	gz.Close()
+	req := httptest.NewRequest(http.MethodPost, "/", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	middleware.Execute(next, errorHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != `{"name":"boat"}` {
+		t.Errorf("Expected decompressed body, got %q", received)
+	}
+}
+
+// readerFromRecorder is a recorder which counts the bytes copied through its io.ReaderFrom.
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom int64
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	n, err := io.Copy(r.ResponseRecorder, src)
+	r.readFrom += n
+	return n, err
+}
+
+// TestResponseWritersReadFrom tests the wrapped writers hand the bulk of uncompressed copies to the client's
+// io.ReaderFrom, keeping zero-copy transfers, while compressed responses still go through the compressor.
+func TestResponseWritersReadFrom(t *testing.T) {
+	content := strings.Repeat("x", 4096)
+	for _, contentType := range []string{"image/png", "text/plain"} {
+		w := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			// Like http.ServeContent, which copies files through a LimitReader.
+			io.Copy(w, io.LimitReader(strings.NewReader(content), int64(len(content))))
+		})
+		handler := ChainMiddleware(next, nil, NewLoggingMiddleware(logging.NewLogrusLogger()), NewCompressionMiddleware(1024))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		handler.ServeHTTP(w, req)
+
+		compressed := w.Header().Get("Content-Encoding") == "gzip"
+		if contentType == "image/png" && (compressed || w.readFrom != int64(len(content)-1024) || w.Body.String() != content) {
+			t.Errorf("Expected the rest of the image to be copied through ReadFrom, got %d bytes", w.readFrom)
+		}
+		if contentType == "text/plain" && (!compressed || w.readFrom != 0) {
+			t.Errorf("Expected the text to be compressed, got %q and %d bytes through ReadFrom", w.Header().Get("Content-Encoding"), w.readFrom)
+		}
+	}
+}
+
+// TestCacheMiddleware tests responses are served from the cache while fresh, per query param,
+// and that conditional requests against a cached response get a 304.
+func TestCacheMiddleware(t *testing.T) {
+	store := cache.NewMemoryStore(0, 0)
+	middleware := NewCacheMiddleware(store, apitypes.CachePolicy{TTL: time.Minute, QueryParams: []string{"page"}})
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("boats"))
+	})
+	handler := middleware.Execute(next, nil)
+
+	expected := []struct {
+		url   string
+		state string
+		calls int
+	}{
+		{"/boats?page=1", "MISS", 1},
+		{"/boats?page=1&ignored=x", "HIT", 1},
+		{"/boats?page=2", "MISS", 2},
+	}
+	for _, e := range expected {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, e.url, nil))
+		if w.Header().Get("X-Cache") != e.state || calls != e.calls || w.Body.String() != "boats" {
+			t.Errorf("Expected %s after %d calls for %s, got %s after %d calls", e.state, e.calls, e.url,
+				w.Header().Get("X-Cache"), calls)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/boats?page=1", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status code 304, got %v", w.Code)
+	}
+
+	if removed := cache.Invalidate(cache.WithStore(context.Background(), store), "GET /boats"); removed != 2 {
+		t.Errorf("Expected 2 entries invalidated, got %v", removed)
+	}
+}
+
+// cacheUser is a principal identified by its ID method.
+type cacheUser struct{ id string }
+
+func (u *cacheUser) ID() string { return u.id }
+
+// TestCacheMiddlewarePrincipals tests responses are cached per principal identifier, even when the principals
+// are new pointers on every request, and not cached for principals which can't be identified.
+func TestCacheMiddlewarePrincipals(t *testing.T) {
+	middleware := NewCacheMiddleware(cache.NewMemoryStore(0, 0), apitypes.CachePolicy{TTL: time.Minute})
+	calls := 0
+	handler := middleware.Execute(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte("boats"))
+	}, nil)
+	serve := func(principal any) string {
+		req := httptest.NewRequest(http.MethodGet, "/boats", nil)
+		req = req.WithContext(context.WithValue(req.Context(), contextypes.ContextAuthKey{}, principal))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Header().Get("X-Cache")
+	}
+
+	for i, e := range []struct {
+		principal any
+		state     string
+		calls     int
+	}{
+		{&cacheUser{"ana"}, "MISS", 1},
+		{&cacheUser{"ana"}, "HIT", 1},
+		{&cacheUser{"bob"}, "MISS", 2},
+		{struct{ token string }{"secret"}, "", 3},
+		{struct{ token string }{"secret"}, "", 4},
+	} {
+		if state := serve(e.principal); state != e.state || calls != e.calls {
+			t.Errorf("Request %d: expected %q after %d calls, got %q after %d calls", i, e.state, e.calls, state, calls)
+		}
+	}
+}
+
+// TestCacheMiddlewareInvalidatedWhileFetching tests a response generated while the cache was invalidated isn't
+// stored, as it may hold the data from before the invalidation.
+func TestCacheMiddlewareInvalidatedWhileFetching(t *testing.T) {
+	store := cache.NewMemoryStore(0, 0)
+	middleware := NewCacheMiddleware(store, apitypes.CachePolicy{TTL: time.Minute})
+	handler := middleware.Execute(func(w http.ResponseWriter, r *http.Request) {
+		cache.Invalidate(cache.WithStore(r.Context(), store), "GET /boats")
+		w.Write([]byte("boats"))
+	}, nil)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boats", nil))
+	if store.Len() != 0 {
+		t.Errorf("Expected the response not to be stored, got %d entries", store.Len())
+	}
+}
+
+// TestRateLimitMiddleware tests requests over the limit are rejected with a 429 and the rate limit headers.
+func TestRateLimitMiddleware(t *testing.T) {
+	rule := ratelimit.Rule{Limit: 1, Window: time.Minute}
+	middleware := NewRateLimitMiddleware(ratelimit.NewMemoryStore(), rule, "global")
+	statusCode := 0
+	errorHandler := func(r *http.Request, w http.ResponseWriter, err error, code int) {
+		statusCode = code
+	}
+	handler := middleware.Execute(func(w http.ResponseWriter, r *http.Request) {}, errorHandler)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if statusCode != 0 || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected the first request to be allowed with no requests remaining")
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if statusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected status code 429, got %v", statusCode)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+	}
+}
+
+// TestConcurrencyMiddleware tests requests which can't get a slot are shed with a 503 and a Retry-After header.
+func TestConcurrencyMiddleware(t *testing.T) {
+	limiter := concurrency.NewLimiter(concurrency.Config{Limit: 1})
+	middleware := NewConcurrencyMiddleware(limiter, 0, 2*time.Second)
+	statusCode := 0
+	errorHandler := func(r *http.Request, w http.ResponseWriter, err error, code int) {
+		statusCode = code
+	}
+	token, _ := limiter.Acquire(context.Background(), 0)
+
+	w := httptest.NewRecorder()
+	middleware.Execute(func(w http.ResponseWriter, r *http.Request) {}, errorHandler).
+		ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if statusCode != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected a 503 with Retry-After 2, got %v with %q", statusCode, w.Header().Get("Retry-After"))
+	}
+
+	token.Release(false)
+	statusCode = 0
+	middleware.Execute(func(w http.ResponseWriter, r *http.Request) {}, errorHandler).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if statusCode != 0 || limiter.InFlight() != 0 {
+		t.Errorf("Expected the request to go through and release its slot")
+	}
+}
+
+// TestTimeoutMiddleware tests that a slow handler gets its context canceled and a 504 is sent,
+// while the writes it makes afterwards never reach the client.
+func TestTimeoutMiddleware(t *testing.T) {
+	middleware := NewTimeoutMiddleware(10 * time.Millisecond)
+	statusCode := 0
+	timedOut := make(chan struct{})
+	errorHandler := func(r *http.Request, w http.ResponseWriter, err error, code int) {
+		statusCode = code
+		w.WriteHeader(code)
+		close(timedOut)
+	}
+	writeErr := make(chan error, 1)
+	next := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		<-timedOut
+		w.Header().Set("X-Late", "true")
+		_, err := w.Write([]byte("late"))
+		writeErr <- err
+	}
+
+	w := httptest.NewRecorder()
+	middleware.Execute(next, errorHandler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if statusCode != http.StatusGatewayTimeout {
+		t.Errorf("Expected a 504, got %v", statusCode)
+	}
+	if err := <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("Expected late writes to fail with http.ErrHandlerTimeout, got %v", err)
+	}
+	if w.Body.Len() != 0 || w.Header().Get("X-Late") != "" {
+		t.Errorf("Expected late writes not to reach the client, got %q", w.Body.String())
+	}
+}
+
+// TestTimeoutMiddlewareDetach tests that handlers lifting the timeout with DetachTimeout write their response
+// however long it takes, with a context without deadline, and that the timeout can't be lifted once expired.
+func TestTimeoutMiddlewareDetach(t *testing.T) {
+	middleware := NewTimeoutMiddleware(10 * time.Millisecond)
+	next := func(w http.ResponseWriter, r *http.Request) {
+		r, ok := DetachTimeout(r)
+		if _, hasDeadline := r.Context().Deadline(); !ok || hasDeadline {
+			t.Errorf("Expected the timeout to be lifted, got %v and a deadline %v", ok, hasDeadline)
+		}
+		time.Sleep(30 * time.Millisecond)
+		if r.Context().Err() != nil {
+			t.Errorf("Expected the detached context not to be done, got %v", r.Context().Err())
+		}
+		w.Write([]byte("streamed"))
+	}
+	errorHandler := func(r *http.Request, w http.ResponseWriter, err error, code int) {
+		t.Errorf("Expected no timeout, got %v", code)
+	}
+	w := httptest.NewRecorder()
+	middleware.Execute(next, errorHandler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "streamed" {
+		t.Errorf("Expected the streamed response, got %v %q", w.Code, w.Body.String())
+	}
+
+	detached := make(chan bool, 1)
+	late := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		_, ok := DetachTimeout(r)
+		detached <- ok
+	}
+	errorHandler = func(r *http.Request, w http.ResponseWriter, err error, code int) {
+		w.WriteHeader(code)
+	}
+	middleware.Execute(late, errorHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if <-detached {
+		t.Errorf("Expected the timeout not to be lifted once expired")
+	}
+}
+
+// TestTimeoutMiddlewareFastHandler tests that handlers finishing in time are served untouched.
+func TestTimeoutMiddlewareFastHandler(t *testing.T) {
+	middleware := NewTimeoutMiddleware(time.Second)
+	errorHandler := func(r *http.Request, w http.ResponseWriter, err error, code int) {
+		t.Errorf("errorHandler should not be called, got %v", err)
+	}
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	}
+
+	w := httptest.NewRecorder()
+	middleware.Execute(next, errorHandler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "done" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected the handler's response, got %v %q", w.Code, w.Body.String())
+	}
+}
+
+// TestTimeoutMiddlewareTrailers checks that the trailers set by the handler after the status code reach the client.
+func TestTimeoutMiddlewareTrailers(t *testing.T) {
+	middleware := NewTimeoutMiddleware(time.Second)
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("done"))
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set(http.TrailerPrefix+"X-Rows", "1")
+		w.Header().Set("X-Late", "true")
+	}
+
+	w := httptest.NewRecorder()
+	middleware.Execute(next, nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	trailers := w.Result().Trailer
+	if trailers.Get("X-Checksum") != "abc" || trailers.Get("X-Rows") != "1" || w.Header().Get("X-Late") != "" {
+		t.Errorf("Expected only the trailers to be copied, got %v %v", trailers, w.Header())
+	}
+}
+
+// TestMTLSAuthMiddleware tests that only requests with an allowed verified client certificate go through,
+// with its identity stored as the principal.
+func TestMTLSAuthMiddleware(t *testing.T) {
+	middleware := NewMTLSAuthMiddleware("billing.internal")
+	withCert := func(names ...string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}, DNSNames: names, SerialNumber: big.NewInt(1)}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return req
+	}
+
+	for name, test := range map[string]struct {
+		req      *http.Request
+		expected int
+	}{
+		"allowed":        {withCert("billing.internal"), 0},
+		"not allowed":    {withCert("orders.internal"), http.StatusUnauthorized},
+		"no certificate": {httptest.NewRequest(http.MethodGet, "/", nil), http.StatusUnauthorized},
+	} {
+		statusCode := 0
+		var principal any
+		errorHandler := func(r *http.Request, w http.ResponseWriter, err error, code int) {
+			statusCode = code
+		}
+		next := func(w http.ResponseWriter, r *http.Request) {
+			principal = r.Context().Value(contextypes.ContextAuthKey{})
+		}
+		middleware.Execute(next, errorHandler).ServeHTTP(httptest.NewRecorder(), test.req)
+		if statusCode != test.expected {
+			t.Errorf("%s: expected status code %v, got %v", name, test.expected, statusCode)
+		}
+		if identity, ok := principal.(tlsconfig.Identity); test.expected == 0 && (!ok || identity.String() != "billing.internal") {
+			t.Errorf("%s: expected the identity to be the principal, got %v", name, principal)
+		}
+	}
+}
+
+// TestFieldsMiddleware tests that the selection is stored in the context and selections out of the
+// allow-lists are rejected with a 400.
+func TestFieldsMiddleware(t *testing.T) {
+	middleware := NewFieldsMiddleware([]string{"id", "owner"}, []string{"owner"})
+	for url, expected := range map[string]int{
+		"/boats?fields=id,owner.name&expand=owner": 0,
+		"/boats":               0,
+		"/boats?fields=length": http.StatusBadRequest,
+		"/boats?expand=crew":   http.StatusBadRequest,
+	} {
+		statusCode := 0
+		var sel fields.Selection
+		errorHandler := func(r *http.Request, w http.ResponseWriter, err error, code int) {
+			statusCode = code
+		}
+		next := func(w http.ResponseWriter, r *http.Request) {
+			sel = fields.FromCtx(r.Context())
+		}
+		middleware.Execute(next, errorHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+		if statusCode != expected {
+			t.Errorf("%s: expected status code %v, got %v", url, expected, statusCode)
+		}
+		if url == "/boats?fields=id,owner.name&expand=owner" && (len(sel.Fields) != 2 || !sel.Expands("owner")) {
+			t.Errorf("%s: unexpected selection %+v", url, sel)
+		}
+	}
+}
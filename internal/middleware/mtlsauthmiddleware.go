@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lucastomic/msBaseProj/internal/contextypes"
+	"github.com/lucastomic/msBaseProj/internal/errs"
+	"github.com/lucastomic/msBaseProj/internal/tlsconfig"
+)
+
+// mtlsAuthMiddleware authenticates requests by the client certificate verified during the TLS handshake.
+// It's meant to be the server's authMiddleware when mutual TLS is enabled.
+type mtlsAuthMiddleware struct {
+	allowed map[string]bool // allowed holds the names accepted. Any verified certificate is accepted when empty.
+}
+
+// NewMTLSAuthMiddleware creates a middleware which only lets through requests with a verified client
+// certificate. If names are given, the certificate's common name or one of its subject alternative names
+// must be among them, e.g. "spiffe://example.org/billing" or "billing.internal".
+func NewMTLSAuthMiddleware(names ...string) Middleware {
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		allowed[name] = true
+	}
+	return mtlsAuthMiddleware{allowed}
+}
+
+// Execute wraps the next http.HandlerFunc in the middleware chain.
+// Requests without a verified certificate, or whose certificate isn't allowed, are rejected through the
+// errorHandler with a 401. Otherwise, the certificate's tlsconfig.Identity is stored in the request's
+// context as the authenticated principal and the next handler is called.
+func (m mtlsAuthMiddleware) Execute(
+	next http.HandlerFunc,
+	errorHandler errorHandler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := tlsconfig.ClientIdentity(r)
+		if !ok || !m.allows(identity) {
+			err := errs.NewI18NError("client certificate not accepted: %w", errs.ErrNotAuthorized, "unauthorized")
+			errorHandler(r, w, err, http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(r.Context(), contextypes.ContextAuthKey{}, identity)
+		*r = *r.WithContext(ctx)
+		next(w, r)
+	}
+}
+
+// allows reports whether the identity is accepted.
+func (m mtlsAuthMiddleware) allows(identity tlsconfig.Identity) bool {
+	if len(m.allowed) == 0 {
+		return true
+	}
+	for _, name := range identity.Names() {
+		if m.allowed[name] {
+			return true
+		}
+	}
+	return false
+}
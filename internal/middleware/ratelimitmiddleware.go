@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+	"github.com/lucastomic/msBaseProj/internal/ratelimit"
+)
+
+// rateLimitMiddleware rejects the requests of clients which exceed a rate limit rule with a 429.
+type rateLimitMiddleware struct {
+	store ratelimit.Store       // store keeps the state of every client.
+	rule  func() ratelimit.Rule // rule returns the limit enforced, looked up on every request.
+	scope string                // scope prefixes the client keys, so several rules can share a store.
+}
+
+// NewRateLimitMiddleware creates a middleware enforcing rule with the state kept in store.
+// Clients are counted separately in every scope, e.g. "global" for a server-wide limit or the route's
+// pattern for a route limit.
+func NewRateLimitMiddleware(store ratelimit.Store, rule ratelimit.Rule, scope string) Middleware {
+	return rateLimitMiddleware{store, func() ratelimit.Rule { return rule }, scope}
+}
+
+// NewReloadableRateLimitMiddleware creates a middleware enforcing the rule returned by rule when each
+// request arrives, so the limit can be changed while the server runs. Requests go through untouched
+// while the rule isn't enabled.
+func NewReloadableRateLimitMiddleware(store ratelimit.Store, rule func() ratelimit.Rule, scope string) Middleware {
+	return rateLimitMiddleware{store, rule, scope}
+}
+
+// Execute wraps the next http.HandlerFunc in the middleware chain.
+// Every response carries the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+// headers, and rejected requests a Retry-After one too. Rejections are reported through the errorHandler
+// as translatable errs.ErrTooManyRequests errors. If the store fails the request is let through,
+// as an unavailable store shouldn't take the service down with it.
+func (m rateLimitMiddleware) Execute(
+	next http.HandlerFunc,
+	errorHandler errorHandler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule := m.rule()
+		if !rule.Enabled() {
+			next(w, r)
+			return
+		}
+		if rule.Key == nil {
+			rule.Key = ratelimit.ByIP
+		}
+		result, err := m.store.Allow(r.Context(), m.scope+"|"+rule.Key(r), rule)
+		if err != nil {
+			next(w, r)
+			return
+		}
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Limit, ceilSeconds(rule.Window)))
+		if !result.Allowed {
+			h.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+			err := errs.NewI18NError("rate limit exceeded: %w", errs.ErrTooManyRequests, "toomanyrequests")
+			errorHandler(r, w, err, http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds, as rate limit headers are expressed in delta-seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"bufio"
+	"io"
+	"net"
 	"net/http"
 )
 
@@ -27,3 +30,34 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.statusCode = code                // Capture the status code for logging or other purposes.
 	lrw.ResponseWriter.WriteHeader(code) // Delegate to the original ResponseWriter.
 }
+
+// Flush sends any buffered data to the client if the underlying http.ResponseWriter supports it,
+// so wrapping the writer doesn't break streaming responses.
+func (lrw *loggingResponseWriter) Flush() {
+	_ = http.NewResponseController(lrw.ResponseWriter).Flush()
+}
+
+// Hijack lets the handler take over the connection, e.g. to upgrade it to a WebSocket, recording the
+// 101 status code the handler sends on its own.
+func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(lrw.ResponseWriter).Hijack()
+	if err == nil {
+		lrw.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// ReadFrom copies r to the response with the underlying http.ResponseWriter's io.ReaderFrom if it has one,
+// so wrapping the writer doesn't prevent zero-copy transfers like sendfile.
+func (lrw *loggingResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := lrw.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(lrw.ResponseWriter, r)
+}
+
+// Unwrap returns the underlying http.ResponseWriter, allowing http.ResponseController
+// to reach the features the wrapper doesn't expose.
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/contextypes"
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// timeoutMiddleware bounds the time a handler can take. Once the timeout expires the request's context is
+// canceled and, if the handler didn't start writing its response, a 504 is sent through the errorHandler.
+// Handlers should honour the context's cancellation, as the middleware can't stop them.
+type timeoutMiddleware struct {
+	timeout time.Duration
+}
+
+// NewTimeoutMiddleware creates a middleware canceling requests whose handler runs longer than timeout.
+func NewTimeoutMiddleware(timeout time.Duration) Middleware {
+	return timeoutMiddleware{timeout}
+}
+
+// Execute wraps the next http.HandlerFunc in the middleware chain.
+// The handler runs in its own goroutine with a context carrying the deadline, writing through a
+// timeoutWriter which forwards everything to the client until the deadline passes. From then on writes
+// fail with http.ErrHandlerTimeout, so the handler can't touch the response once the middleware returned.
+// A panicking handler is re-panicked in the request's goroutine, where net/http recovers it.
+// Handlers lift the timeout with DetachTimeout, after which the middleware waits for them however long they take.
+func (t timeoutMiddleware) Execute(
+	next http.HandlerFunc,
+	errorHandler errorHandler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), t.timeout)
+		defer cancel()
+		tw := &timeoutWriter{w: w, header: http.Header{}}
+		// The handler gets its own copy of the request, as it can outlive the middleware.
+		handlerReq := r.WithContext(context.WithValue(ctx, contextypes.ContextTimeoutKey{}, timeoutDetacher{tw, r.Context()}))
+
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- fmt.Sprintf("%v\n%s", p, debug.Stack())
+				}
+			}()
+			next(tw, handlerReq)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			tw.copyTrailers()
+		case p := <-panicked:
+			panic(p)
+		case <-ctx.Done():
+			tw.mu.Lock()
+			if tw.detached {
+				tw.mu.Unlock()
+				select {
+				case <-done:
+					tw.copyTrailers()
+				case p := <-panicked:
+					panic(p)
+				}
+				return
+			}
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			// A client which went away doesn't need to be told anything.
+			if !tw.wroteHeader && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err := errs.NewI18NError("handler timed out: %w", errs.ErrTimeout, "timeout")
+				errorHandler(r, w, err, http.StatusGatewayTimeout)
+			}
+		}
+	}
+}
+
+// DetachTimeout lifts the handler timeout of r, for responses which take longer than it to be sent,
+// like server-sent events, streamed collections or files. It returns r with a context without the deadline,
+// which is still canceled when the client goes away. The boolean is false if the timeout already expired,
+// in which case a 504 was sent and the response must be dropped. Requests without timeout are returned as is.
+func DetachTimeout(r *http.Request) (*http.Request, bool) {
+	detacher, ok := r.Context().Value(contextypes.ContextTimeoutKey{}).(timeoutDetacher)
+	if !ok {
+		return r, true
+	}
+	detacher.tw.mu.Lock()
+	defer detacher.tw.mu.Unlock()
+	if detacher.tw.timedOut {
+		return r, false
+	}
+	detacher.tw.detached = true
+	// The values stored along the handler chain are kept, but the cancellation is the client's alone.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	context.AfterFunc(detacher.client, cancel)
+	return r.WithContext(ctx), true
+}
+
+// timeoutDetacher is stored in the context of the requests run by the timeout middleware so DetachTimeout
+// can reach their writer.
+type timeoutDetacher struct {
+	tw     *timeoutWriter
+	client context.Context // client is the context of the request before the timeout, canceled when the client goes away.
+}
+
+// timeoutWriter is the http.ResponseWriter given to handlers run by the timeout middleware.
+// Headers are kept apart from the client's until the response is written, and every access to
+// the client's writer is serialized with the timeout.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	w           http.ResponseWriter // w is the client's writer.
+	header      http.Header         // header holds the headers set by the handler.
+	wroteHeader bool                // wroteHeader reports whether the status code was sent to the client.
+	timedOut    bool                // timedOut reports whether the timeout expired.
+	detached    bool                // detached reports whether the timeout was lifted by DetachTimeout.
+}
+
+// Header returns the headers of the response, which are sent to the client with the status code.
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// WriteHeader sends the headers and the status code to the client, unless the timeout expired.
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeader(code)
+}
+
+// Write sends p to the client, failing with http.ErrHandlerTimeout if the timeout expired.
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(p)
+}
+
+// ReadFrom copies r to the client, failing with http.ErrHandlerTimeout if the timeout expired. It lets
+// http.ServeContent reach the io.ReaderFrom of the client's writer, which sends files without copying them.
+func (tw *timeoutWriter) ReadFrom(r io.Reader) (int64, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	if rf, ok := tw.w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(tw.w, r)
+}
+
+// Unwrap returns the client's writer, so http.ResponseController reaches the features the timeoutWriter
+// doesn't implement itself.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// Flush sends any buffered data to the client, unless the timeout expired.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	_ = http.NewResponseController(tw.w).Flush()
+}
+
+// SetWriteDeadline sets the write deadline of the client's connection, unless the timeout expired.
+// It lets streaming handlers extend the server's write timeout through http.ResponseController.
+func (tw *timeoutWriter) SetWriteDeadline(deadline time.Time) error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return http.ErrHandlerTimeout
+	}
+	return http.NewResponseController(tw.w).SetWriteDeadline(deadline)
+}
+
+// writeHeader copies the handler's headers and sends the status code the first time it's called.
+// The caller must hold the lock.
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.wroteHeader || tw.timedOut {
+		return
+	}
+	tw.wroteHeader = true
+	dst := tw.w.Header()
+	for key, values := range tw.header {
+		dst[key] = append([]string(nil), values...)
+	}
+	tw.w.WriteHeader(code)
+}
+
+// copyTrailers copies the trailers set by the handler once it returned, as the headers set after the
+// status code don't reach the client otherwise: the ones announced in the Trailer header, and the ones
+// prefixed with http.TrailerPrefix.
+func (tw *timeoutWriter) copyTrailers() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.wroteHeader {
+		return
+	}
+	dst := tw.w.Header()
+	for _, name := range tw.header.Values("Trailer") {
+		for _, key := range strings.Split(name, ",") {
+			key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+			if values, ok := tw.header[key]; ok {
+				dst[key] = append([]string(nil), values...)
+			}
+		}
+	}
+	for key, values := range tw.header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			dst[key] = append([]string(nil), values...)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package pagination
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// cursor is the content of a cursor: where the page starts, and the query it belongs to, as it
+// means nothing with another sort or other filters.
+type cursor struct {
+	After map[string]any `json:"a"`
+	Query string         `json:"q"`
+}
+
+// encodeCursor returns the cursor pointing after the item whose sort values are after, signed so
+// clients can't forge it.
+func (p *Paginator) encodeCursor(q Query, after map[string]any) string {
+	payload, _ := json.Marshal(cursor{After: after, Query: querySignature(q)})
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// decodeCursor verifies the cursor and returns the sort values it points after, typed as their fields.
+func (p *Paginator) decodeCursor(raw string, q Query) (map[string]any, error) {
+	after, err := p.verifyCursor(raw, q)
+	if err != nil {
+		return nil, errs.NewI18NError("invalid cursor: %w", errors.Join(errs.ErrInvalidInput, err), "invalidcursor")
+	}
+	return after, nil
+}
+
+// verifyCursor checks the cursor's signature and query, and parses its values.
+func (p *Paginator) verifyCursor(raw string, q Query) (map[string]any, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(raw, ".")
+	if !ok {
+		return nil, errors.New("malformed")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, errors.New("malformed")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return nil, errors.New("malformed")
+	}
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("bad signature")
+	}
+	var c cursor
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c); err != nil {
+		return nil, errors.New("malformed")
+	}
+	if c.Query != querySignature(q) {
+		return nil, errors.New("the sort or the filters changed")
+	}
+
+	after := make(map[string]any, len(q.Sort))
+	for _, sort := range q.Sort {
+		value, ok := c.After[sort.Field]
+		if !ok {
+			return nil, fmt.Errorf("missing %s", sort.Field)
+		}
+		typed, err := typedValue(p.fields[sort.Field].Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", sort.Field, err)
+		}
+		after[sort.Field] = typed
+	}
+	return after, nil
+}
+
+// typedValue converts a value decoded from JSON, with numbers as json.Number, into the Go type of fieldType. Null values are kept as nil.
+func typedValue(fieldType FieldType, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case json.Number:
+		if fieldType == Int {
+			return v.Int64()
+		}
+		if fieldType == Float {
+			return v.Float64()
+		}
+	case string:
+		if fieldType == Time {
+			return time.Parse(time.RFC3339Nano, v)
+		}
+		if fieldType == String {
+			return v, nil
+		}
+	case bool:
+		if fieldType == Bool {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %T", value)
+}
+
+// querySignature identifies the sort and filters of a query.
+func querySignature(q Query) string {
+	filters := make([]string, len(q.Filters))
+	for i, filter := range q.Filters {
+		filters[i] = filter.String()
+	}
+	return formatSort(q.Sort) + "|" + strings.Join(filters, ";")
+}
@@ -0,0 +1,119 @@
+package pagination
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// Operator compares a field with the values of a filter.
+type Operator string
+
+const (
+	Eq   Operator = "eq"   // Eq matches items whose field equals the value.
+	Ne   Operator = "ne"   // Ne matches items whose field differs from the value.
+	Lt   Operator = "lt"   // Lt matches items whose field is lower than the value.
+	Gt   Operator = "gt"   // Gt matches items whose field is greater than the value.
+	In   Operator = "in"   // In matches items whose field equals one of the values, separated by |.
+	Like Operator = "like" // Like matches items whose String field matches the pattern, where * stands for any text.
+)
+
+// Filter is a condition the items of a page must meet. Its values are parsed into the type of the field:
+// string, int64, float64, bool or time.Time.
+type Filter struct {
+	Field  string
+	Op     Operator
+	Values []any // Values holds a single value, except for In.
+
+	raw string
+}
+
+// Value returns the value of the filter, or the first one for In.
+func (f Filter) Value() any {
+	return f.Values[0]
+}
+
+// String returns the filter in the syntax of the filter param.
+func (f Filter) String() string {
+	return f.raw
+}
+
+// parseFilters parses the filter params, each one holding a clause like field:op:value, e.g.
+// ?filter=length:gt:10&filter=status:in:open|closed&filter=name:like:sea*. Every clause must be met.
+func (p *Paginator) parseFilters(raws []string) ([]Filter, error) {
+	var filters []Filter
+	for _, clause := range raws {
+		if clause = strings.TrimSpace(clause); clause == "" {
+			continue
+		}
+		filter, err := p.parseFilter(clause)
+		if err != nil {
+			err := errs.NewI18NError("invalid filter: %w", fmt.Errorf("%q: %w", clause, errs.ErrInvalidInput), "invalidfilter")
+			return nil, err.WithParam("filter", clause)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// parseFilter parses a single clause.
+func (p *Paginator) parseFilter(clause string) (Filter, error) {
+	parts := strings.SplitN(clause, ":", 3)
+	if len(parts) != 3 {
+		return Filter{}, fmt.Errorf("expected field:operator:value")
+	}
+	field, ok := p.fields[parts[0]]
+	if !ok || !field.Filterable {
+		return Filter{}, fmt.Errorf("can't filter by %s", parts[0])
+	}
+	filter := Filter{Field: field.Name, Op: Operator(parts[1]), raw: clause}
+	switch filter.Op {
+	case Eq, Ne:
+	case Lt, Gt:
+		if field.Type == Bool {
+			return Filter{}, fmt.Errorf("booleans can't be compared")
+		}
+	case Like:
+		if field.Type != String {
+			return Filter{}, fmt.Errorf("only strings can be matched")
+		}
+	case In:
+	default:
+		return Filter{}, fmt.Errorf("unknown operator %s", parts[1])
+	}
+
+	raws := []string{parts[2]}
+	if filter.Op == In {
+		raws = strings.Split(parts[2], "|")
+	}
+	for _, raw := range raws {
+		value, err := parseValue(field.Type, raw)
+		if err != nil {
+			return Filter{}, err
+		}
+		filter.Values = append(filter.Values, value)
+	}
+	return filter, nil
+}
+
+// parseValue parses raw into the Go type of fieldType.
+func parseValue(fieldType FieldType, raw string) (any, error) {
+	switch fieldType {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Float:
+		return strconv.ParseFloat(raw, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Time:
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.DateOnly, raw)
+	default:
+		return raw, nil
+	}
+}
@@ -0,0 +1,227 @@
+package pagination
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+const (
+	// DefaultLimit is the number of items of a page when the client doesn't ask for one.
+	DefaultLimit = 20
+	// DefaultMaxLimit is the largest number of items of a page. Larger limits are lowered to it.
+	DefaultMaxLimit = 100
+)
+
+// Query params read by Paginator.Parse.
+const (
+	LimitParam  = "limit"
+	OffsetParam = "offset"
+	CursorParam = "cursor"
+	SortParam   = "sort"
+	FilterParam = "filter"
+)
+
+// Mode is how clients move through the pages of a collection.
+type Mode int
+
+const (
+	// OffsetMode pages with the limit and offset params, allowing clients to jump to any page.
+	OffsetMode Mode = iota
+	// CursorMode pages with opaque cursors pointing after the last item of the previous page, which stay
+	// correct while items are added or removed and don't get slower on later pages.
+	CursorMode
+)
+
+// FieldType is the type of a field, which its filter values are parsed into.
+type FieldType int
+
+const (
+	String FieldType = iota // String values are kept as they are.
+	Int                     // Int values are parsed as int64.
+	Float                   // Float values are parsed as float64.
+	Bool                    // Bool values are parsed as strconv.ParseBool does.
+	Time                    // Time values are parsed as RFC 3339 timestamps or dates, like 2024-05-01.
+)
+
+// Field is a field of the items of a collection clients can sort or filter by.
+type Field struct {
+	// Name is the name of the field in the API, which must match its JSON name, as cursors are built
+	// from the encoded items.
+	Name       string
+	Type       FieldType
+	Sortable   bool // Sortable allows clients to sort by the field.
+	Filterable bool // Filterable allows clients to filter by the field.
+}
+
+// Schema declares how a collection can be paged, sorted and filtered.
+type Schema struct {
+	Mode   Mode
+	Fields []Field
+	// Key is the name of a unique field, like the ID, which is always sorted by last so the order is total.
+	// It's required in CursorMode.
+	Key string
+	// DefaultSort is the sort applied when the client asks for none, in the syntax of the sort param.
+	DefaultSort string
+	// DefaultLimit is the size of the pages when the client asks for none. DefaultLimit when zero.
+	DefaultLimit int
+	// MaxLimit is the size of the largest page. DefaultMaxLimit when zero.
+	MaxLimit int
+}
+
+// Sort is a field the items are sorted by.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Query is the page of a collection asked for by a request. Repositories should return Limit+1 items,
+// see Fetch, so Response knows whether there's a next page.
+type Query struct {
+	Mode    Mode
+	Limit   int
+	Offset  int      // Offset is the number of items skipped, in OffsetMode.
+	Sort    []Sort   // Sort is the order of the items, always ending with the schema's Key if it has one.
+	Filters []Filter // Filters are the conditions every item must meet.
+	// After holds the values of the Sort fields of the last item of the previous page, in CursorMode.
+	// The page starts right after it. It's nil on the first page.
+	After map[string]any
+
+	paginator *Paginator
+}
+
+// Fetch returns the number of items the repository should return: one more than the limit, telling
+// whether there's a next page.
+func (q Query) Fetch() int {
+	return q.Limit + 1
+}
+
+// Paginator parses the pagination, sort and filter params of the requests to a collection, and builds
+// the responses with its pages. It's safe for concurrent use.
+type Paginator struct {
+	schema Schema
+	fields map[string]Field
+	secret []byte
+}
+
+// New creates a Paginator for the collection described by schema, signing its cursors with secret.
+// When secret is empty a random one is used, so cursors don't survive restarts nor work across replicas.
+// It panics if the schema is invalid, as that is a programming error.
+func New(schema Schema, secret []byte) *Paginator {
+	if schema.DefaultLimit <= 0 {
+		schema.DefaultLimit = DefaultLimit
+	}
+	if schema.MaxLimit <= 0 {
+		schema.MaxLimit = DefaultMaxLimit
+	}
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+	p := &Paginator{schema: schema, fields: map[string]Field{}, secret: secret}
+	for _, field := range schema.Fields {
+		p.fields[field.Name] = field
+	}
+	if _, ok := p.fields[schema.Key]; schema.Key != "" && !ok {
+		panic(fmt.Sprintf("pagination: key %q isn't a field of the schema", schema.Key))
+	}
+	if schema.Mode == CursorMode && schema.Key == "" {
+		panic("pagination: cursor pagination requires a key")
+	}
+	if _, err := p.parseSort(schema.DefaultSort); err != nil {
+		panic(fmt.Sprintf("pagination: invalid default sort %q: %v", schema.DefaultSort, err))
+	}
+	return p
+}
+
+// Parse reads the page asked for by the request: its limit, its offset or cursor, its sort and its
+// filters, validated against the schema. Invalid params fail with an errs.I18nError wrapping
+// errs.ErrInvalidInput, meant to be passed straight to the controller's ParseError.
+func (p *Paginator) Parse(r *http.Request) (Query, error) {
+	values := r.URL.Query()
+	q := Query{Mode: p.schema.Mode, Limit: p.schema.DefaultLimit, paginator: p}
+	if raw := values.Get(LimitParam); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return Query{}, invalidParam(LimitParam)
+		}
+		q.Limit = min(limit, p.schema.MaxLimit)
+	}
+
+	sort := values.Get(SortParam)
+	if sort == "" {
+		sort = p.schema.DefaultSort
+	}
+	var err error
+	if q.Sort, err = p.parseSort(sort); err != nil {
+		return Query{}, err
+	}
+	if q.Filters, err = p.parseFilters(values[FilterParam]); err != nil {
+		return Query{}, err
+	}
+
+	switch p.schema.Mode {
+	case OffsetMode:
+		if raw := values.Get(OffsetParam); raw != "" {
+			if q.Offset, err = strconv.Atoi(raw); err != nil || q.Offset < 0 {
+				return Query{}, invalidParam(OffsetParam)
+			}
+		}
+	case CursorMode:
+		if raw := values.Get(CursorParam); raw != "" {
+			if q.After, err = p.decodeCursor(raw, q); err != nil {
+				return Query{}, err
+			}
+		}
+	}
+	return q, nil
+}
+
+// parseSort parses a comma-separated list of fields, descending when prefixed with a dash, like
+// "-length,name". The schema's key is added as the last field if it isn't there.
+func (p *Paginator) parseSort(raw string) ([]Sort, error) {
+	var sorts []Sort
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		sort := Sort{Field: strings.TrimPrefix(name, "-"), Desc: strings.HasPrefix(name, "-")}
+		field, ok := p.fields[sort.Field]
+		if !ok || !(field.Sortable || field.Name == p.schema.Key) {
+			err := errs.NewI18NError("invalid sort field: %w", fmt.Errorf("%q: %w", sort.Field, errs.ErrInvalidInput), "invalidsort")
+			return nil, err.WithParam("field", sort.Field)
+		}
+		if slices.ContainsFunc(sorts, func(s Sort) bool { return s.Field == sort.Field }) {
+			continue
+		}
+		sorts = append(sorts, sort)
+	}
+	if key := p.schema.Key; key != "" && !slices.ContainsFunc(sorts, func(s Sort) bool { return s.Field == key }) {
+		desc := len(sorts) > 0 && sorts[len(sorts)-1].Desc
+		sorts = append(sorts, Sort{Field: key, Desc: desc})
+	}
+	return sorts, nil
+}
+
+// formatSort returns sorts in the syntax of the sort param.
+func formatSort(sorts []Sort) string {
+	names := make([]string, len(sorts))
+	for i, sort := range sorts {
+		names[i] = sort.Field
+		if sort.Desc {
+			names[i] = "-" + sort.Field
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// invalidParam returns the error of a pagination param with an invalid value.
+func invalidParam(name string) error {
+	err := errs.NewI18NError("invalid parameter: %w", fmt.Errorf("%s: %w", name, errs.ErrInvalidInput), "invalidparam")
+	return err.WithParam("param", name)
+}
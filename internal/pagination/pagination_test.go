@@ -0,0 +1,154 @@
+package pagination
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// boat is an item of the collection used by the tests.
+type boat struct {
+	ID     int64     `json:"id"`
+	Name   string    `json:"name"`
+	Length float64   `json:"length"`
+	Built  time.Time `json:"built"`
+}
+
+// boatSchema describes the boats collection.
+func boatSchema(mode Mode) Schema {
+	return Schema{
+		Mode: mode,
+		Key:  "id",
+		Fields: []Field{
+			{Name: "id", Type: Int},
+			{Name: "name", Type: String, Sortable: true, Filterable: true},
+			{Name: "length", Type: Float, Sortable: true, Filterable: true},
+			{Name: "built", Type: Time, Sortable: true},
+			{Name: "active", Type: Bool, Filterable: true},
+		},
+		DefaultSort: "name",
+		MaxLimit:    50,
+	}
+}
+
+// expectCode checks err is an I18nError with the given code wrapping errs.ErrInvalidInput.
+func expectCode(t *testing.T, err error, code string) {
+	t.Helper()
+	i18n := &errs.I18nError{}
+	if !errors.As(err, i18n) || i18n.Code != code || !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected the error %s, got %v", code, err)
+	}
+}
+
+// TestParse checks the limit, offset, sort and filters are read and validated against the schema.
+func TestParse(t *testing.T) {
+	p := New(boatSchema(OffsetMode), []byte("secret"))
+	q, err := p.Parse(httptest.NewRequest(http.MethodGet, "/boats?limit=500&offset=40&sort=-length,name&filter=length:gt:10&filter=name:like:sea*&filter=active:in:true|false", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Limit != 50 || q.Offset != 40 {
+		t.Errorf("Expected the limit to be capped and the offset read, got %d %d", q.Limit, q.Offset)
+	}
+	expectedSort := []Sort{{"length", true}, {"name", false}, {"id", false}}
+	if !reflect.DeepEqual(q.Sort, expectedSort) {
+		t.Errorf("Expected the sort %v, got %v", expectedSort, q.Sort)
+	}
+	if len(q.Filters) != 3 || q.Filters[0].Value() != 10.0 || q.Filters[1].Op != Like || !reflect.DeepEqual(q.Filters[2].Values, []any{true, false}) {
+		t.Errorf("Unexpected filters %+v", q.Filters)
+	}
+	if q, _ := p.Parse(httptest.NewRequest(http.MethodGet, "/boats", nil)); q.Limit != DefaultLimit || formatSort(q.Sort) != "name,id" {
+		t.Errorf("Expected the defaults, got %d %s", q.Limit, formatSort(q.Sort))
+	}
+
+	for url, code := range map[string]string{
+		"/boats?limit=0":                    "invalidparam",
+		"/boats?offset=-1":                  "invalidparam",
+		"/boats?sort=built,active":          "invalidsort",
+		"/boats?filter=built:eq:2024-01-01": "invalidfilter",
+		"/boats?filter=length:like:1*":      "invalidfilter",
+		"/boats?filter=active:gt:true":      "invalidfilter",
+		"/boats?filter=length:gt:long":      "invalidfilter",
+		"/boats?filter=length:between:1":    "invalidfilter",
+		"/boats?filter=length":              "invalidfilter",
+		"/boats?sort=%25d":                  "invalidsort",
+		"/boats?filter=%25s%25w":            "invalidfilter",
+	} {
+		_, err := p.Parse(httptest.NewRequest(http.MethodGet, url, nil))
+		expectCode(t, err, code)
+	}
+}
+
+// TestOffsetResponse checks pages are wrapped in an envelope with links to the neighbouring pages.
+func TestOffsetResponse(t *testing.T) {
+	p := New(boatSchema(OffsetMode), nil)
+	r := httptest.NewRequest(http.MethodGet, "/api/boats?limit=2&offset=2&sort=name", nil)
+	q, err := p.Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := Response(r, q, []boat{{ID: 3}, {ID: 4}, {ID: 5}}, 7)
+	envelope := res.Content.(Envelope[boat])
+	if len(envelope.Data) != 2 || *envelope.Total != 7 || *envelope.Offset != 2 || res.Headers["X-Total-Count"] != "7" {
+		t.Errorf("Unexpected envelope %+v", envelope)
+	}
+	expected := `</api/boats?limit=2&offset=0&sort=name>; rel="first", </api/boats?limit=2&offset=0&sort=name>; rel="prev", ` +
+		`</api/boats?limit=2&offset=4&sort=name>; rel="next", </api/boats?limit=2&offset=6&sort=name>; rel="last"`
+	if res.Headers["Link"] != expected {
+		t.Errorf("Expected the links %s, got %s", expected, res.Headers["Link"])
+	}
+}
+
+// TestCursorPagination checks cursors lead to the next page, and are rejected when tampered with
+// or reused with another query.
+func TestCursorPagination(t *testing.T) {
+	p := New(boatSchema(CursorMode), []byte("secret"))
+	built := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := httptest.NewRequest(http.MethodGet, "/api/boats?limit=1&sort=-built&filter=length:gt:5", nil)
+	q, err := p.Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.After != nil {
+		t.Errorf("Expected the first page to start at the beginning, got %v", q.After)
+	}
+	res := Response(r, q, []boat{{ID: 1 << 60, Built: built}, {ID: 2}}, -1)
+	envelope := res.Content.(Envelope[boat])
+	if envelope.NextCursor == "" || envelope.Total != nil || res.Headers["X-Total-Count"] != "" {
+		t.Fatalf("Expected a next cursor without total, got %+v", envelope)
+	}
+	if !strings.Contains(res.Headers["Link"], `rel="next"`) {
+		t.Errorf("Expected a next link, got %s", res.Headers["Link"])
+	}
+
+	next := httptest.NewRequest(http.MethodGet, "/api/boats?limit=1&sort=-built&filter=length:gt:5&cursor="+envelope.NextCursor, nil)
+	q, err = p.Parse(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(q.After, map[string]any{"built": built, "id": int64(1 << 60)}) {
+		t.Errorf("Expected the page to start after the last item, got %v", q.After)
+	}
+	if res := Response(next, q, []boat{{ID: 3}}, -1); res.Content.(Envelope[boat]).NextCursor != "" {
+		t.Errorf("Expected no next cursor on the last page")
+	}
+
+	for _, url := range []string{
+		"/api/boats?limit=1&sort=-built&filter=length:gt:5&cursor=" + envelope.NextCursor + "x",
+		"/api/boats?limit=1&sort=name&filter=length:gt:5&cursor=" + envelope.NextCursor,
+		"/api/boats?limit=1&sort=-built&cursor=" + envelope.NextCursor,
+		"/api/boats?cursor=garbage",
+	} {
+		_, err := p.Parse(httptest.NewRequest(http.MethodGet, url, nil))
+		expectCode(t, err, "invalidcursor")
+	}
+	other := New(boatSchema(CursorMode), []byte("other secret"))
+	_, err = other.Parse(next)
+	expectCode(t, err, "invalidcursor")
+}
@@ -0,0 +1,103 @@
+package pagination
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lucastomic/msBaseProj/internal/controller/apitypes"
+)
+
+// Envelope is the content of the responses holding a page.
+type Envelope[T any] struct {
+	Data       []T    `json:"data"`                 // Data holds the items of the page.
+	Total      *int   `json:"total,omitempty"`      // Total is the number of items matching the filters, if known.
+	Limit      int    `json:"limit"`                // Limit is the size of the pages.
+	Offset     *int   `json:"offset,omitempty"`     // Offset is the number of items skipped, in OffsetMode.
+	NextCursor string `json:"nextCursor,omitempty"` // NextCursor points to the next page, in CursorMode, if there's one.
+}
+
+// FieldsRoot makes the fields selected by the client apply to the items of the page rather than to the
+// envelope, which is always sent whole.
+func (e Envelope[T]) FieldsRoot() string {
+	return "data"
+}
+
+// Response returns the response holding the page q of the collection: its items wrapped in an Envelope,
+// with Link headers to the first, previous, next and last pages as far as they're known, and the total
+// in the X-Total-Count header. items are the ones returned by the repository, up to Fetch of them; the extra
+// one only tells there's a next page. total is the number of items matching the filters, or negative if
+// it isn't known, as counting is often expensive with cursors. Next cursors are built from the JSON
+// encoding of the last item, which must hold the sort fields under their names.
+func Response[T any](r *http.Request, q Query, items []T, total int) apitypes.Response {
+	hasNext := len(items) > q.Limit
+	if hasNext {
+		items = items[:q.Limit]
+	}
+	if items == nil {
+		items = []T{}
+	}
+	envelope := Envelope[T]{Data: items, Limit: q.Limit}
+	headers := map[string]string{}
+	if total >= 0 {
+		envelope.Total = &total
+		headers["X-Total-Count"] = strconv.Itoa(total)
+	}
+
+	var links []string
+	link := func(rel string, set map[string]string) {
+		values := r.URL.Query()
+		for name, value := range set {
+			if value == "" {
+				values.Del(name)
+			} else {
+				values.Set(name, value)
+			}
+		}
+		target := r.URL.Path
+		if encoded := values.Encode(); encoded != "" {
+			target += "?" + encoded
+		}
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, target, rel))
+	}
+	switch q.Mode {
+	case OffsetMode:
+		envelope.Offset = &q.Offset
+		offset := func(offset int) map[string]string { return map[string]string{OffsetParam: strconv.Itoa(offset)} }
+		link("first", offset(0))
+		if q.Offset > 0 {
+			link("prev", offset(max(q.Offset-q.Limit, 0)))
+		}
+		if hasNext {
+			link("next", offset(q.Offset+q.Limit))
+		}
+		if total >= 0 {
+			link("last", offset(max(total-1, 0)/q.Limit*q.Limit))
+		}
+	case CursorMode:
+		link("first", map[string]string{CursorParam: ""})
+		if hasNext && len(items) > 0 {
+			envelope.NextCursor = q.paginator.encodeCursor(q, sortValues(items[len(items)-1], q.Sort))
+			link("next", map[string]string{CursorParam: envelope.NextCursor})
+		}
+	}
+	headers["Link"] = strings.Join(links, ", ")
+	return apitypes.Response{Status: http.StatusOK, Content: envelope, Headers: headers}
+}
+
+// sortValues returns the values of the sort fields of item, read from its JSON encoding.
+func sortValues(item any, sorts []Sort) map[string]any {
+	encoded, _ := json.Marshal(item)
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var fields map[string]any
+	_ = decoder.Decode(&fields)
+	values := make(map[string]any, len(sorts))
+	for _, sort := range sorts {
+		values[sort.Field] = fields[sort.Field]
+	}
+	return values
+}
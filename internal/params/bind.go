@@ -0,0 +1,101 @@
+package params
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+)
+
+// Bind fills the fields of the struct pointed to by dst from the request's path values and query params,
+// as declared by their tags:
+//
+//	type listBoats struct {
+//		Port   uint32    `path:"port"`
+//		Page   int       `query:"page" default:"1"`
+//		Sort   string    `query:"sort" enum:"name,length" default:"name"`
+//		Since  time.Time `query:"since" required:"true"`
+//		Tags   []string  `query:"tag"`
+//		Length *float64  `query:"length"`
+//	}
+//
+// Fields can be of any Value type, a pointer to one, left nil when the param is missing, or a slice of them,
+// filled from repeated or comma-separated query params. Path values are always required. Every value is checked
+// against the comma-separated enum tag if there is one. The first invalid param fails the binding with the same
+// errors as Path and Query, while a dst which isn't a pointer to a struct is a plain error.
+func Bind(r *http.Request, dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("params: Bind expects a pointer to a struct")
+	}
+	v = v.Elem()
+	query := r.URL.Query()
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		var name string
+		var raws []string
+		required := field.Tag.Get("required") == "true"
+		if name = field.Tag.Get("path"); name != "" {
+			required = true
+			if raw := r.PathValue(name); raw != "" {
+				raws = []string{raw}
+			}
+		} else if name = field.Tag.Get("query"); name != "" {
+			raws = query[name]
+		} else {
+			continue
+		}
+		if err := bindField(v.Field(i), field, name, raws, required); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindField parses the raw values of the param name into the field.
+func bindField(dst reflect.Value, field reflect.StructField, name string, raws []string, required bool) error {
+	var allowed []string
+	if enum := field.Tag.Get("enum"); enum != "" {
+		allowed = splitValues([]string{enum})
+	}
+	if dst.Kind() == reflect.Slice {
+		raws = splitValues(raws)
+	} else if len(raws) > 0 && raws[0] != "" {
+		raws = raws[:1]
+	} else {
+		raws = nil
+	}
+	if len(raws) == 0 {
+		if def, ok := field.Tag.Lookup("default"); ok {
+			raws = []string{def}
+			if dst.Kind() == reflect.Slice {
+				raws = splitValues(raws)
+			}
+		} else if required {
+			return missing(name)
+		} else {
+			return nil
+		}
+	}
+
+	for _, raw := range raws {
+		if allowed != nil {
+			if err := checkEnum(raw, name, allowed); err != nil {
+				return err
+			}
+		}
+	}
+	if dst.Kind() != reflect.Slice {
+		return parse(raws[0], name, dst)
+	}
+	values := reflect.MakeSlice(dst.Type(), len(raws), len(raws))
+	for i, raw := range raws {
+		if err := parse(raw, name, values.Index(i)); err != nil {
+			return err
+		}
+	}
+	dst.Set(values)
+	return nil
+}
@@ -0,0 +1,196 @@
+package params
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// UUID is a UUID in its canonical form: lowercase hex digits grouped as 8-4-4-4-12.
+type UUID string
+
+// Value are the types parameters can be parsed into. Times are parsed as RFC 3339 timestamps or dates
+// like 2024-05-01, durations like 1h30m and booleans as strconv.ParseBool does.
+type Value interface {
+	~string | ~bool | ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64 | time.Time
+}
+
+var (
+	timeType = reflect.TypeFor[time.Time]()
+	uuidType = reflect.TypeFor[UUID]()
+	durType  = reflect.TypeFor[time.Duration]()
+)
+
+// Path parses the path value name, failing if it's missing.
+func Path[T Value](r *http.Request, name string) (T, error) {
+	var value T
+	raw := r.PathValue(name)
+	if raw == "" {
+		return value, missing(name)
+	}
+	err := parse(raw, name, reflect.ValueOf(&value).Elem())
+	return value, err
+}
+
+// Query parses the query param name, returning def if it's missing or empty.
+func Query[T Value](r *http.Request, name string, def T) (T, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	var value T
+	err := parse(raw, name, reflect.ValueOf(&value).Elem())
+	return value, err
+}
+
+// RequiredQuery parses the query param name, failing if it's missing or empty.
+func RequiredQuery[T Value](r *http.Request, name string) (T, error) {
+	var value T
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return value, missing(name)
+	}
+	err := parse(raw, name, reflect.ValueOf(&value).Elem())
+	return value, err
+}
+
+// QuerySlice parses the values of the query param name, which can be repeated or separated by commas,
+// like ?tag=a&tag=b or ?tag=a,b. It's empty if the param is missing.
+func QuerySlice[T Value](r *http.Request, name string) ([]T, error) {
+	var values []T
+	for _, raw := range splitValues(r.URL.Query()[name]) {
+		var value T
+		if err := parse(raw, name, reflect.ValueOf(&value).Elem()); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// QueryEnum returns the query param name, which must be one of allowed, or def if it's missing or empty.
+func QueryEnum[T ~string](r *http.Request, name string, def T, allowed ...T) (T, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	if err := checkEnum(raw, name, toStrings(allowed)); err != nil {
+		return def, err
+	}
+	return T(raw), nil
+}
+
+// parse parses raw into dst, which must be of one of the Value types, or a pointer to one.
+func parse(raw, name string, dst reflect.Value) error {
+	if dst.Kind() == reflect.Pointer {
+		dst.Set(reflect.New(dst.Type().Elem()))
+		dst = dst.Elem()
+	}
+	var err error
+	switch t := dst.Type(); {
+	case t == timeType:
+		var parsed time.Time
+		if parsed, err = time.Parse(time.RFC3339, raw); err != nil {
+			parsed, err = time.Parse(time.DateOnly, raw)
+		}
+		dst.Set(reflect.ValueOf(parsed))
+	case t == uuidType:
+		var parsed UUID
+		parsed, err = parseUUID(raw)
+		dst.SetString(string(parsed))
+	case t == durType:
+		var parsed time.Duration
+		parsed, err = time.ParseDuration(raw)
+		dst.SetInt(int64(parsed))
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			dst.SetString(raw)
+		case reflect.Bool:
+			var parsed bool
+			parsed, err = strconv.ParseBool(raw)
+			dst.SetBool(parsed)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var parsed int64
+			parsed, err = strconv.ParseInt(raw, 10, t.Bits())
+			dst.SetInt(parsed)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			var parsed uint64
+			parsed, err = strconv.ParseUint(raw, 10, t.Bits())
+			dst.SetUint(parsed)
+		case reflect.Float32, reflect.Float64:
+			var parsed float64
+			parsed, err = strconv.ParseFloat(raw, t.Bits())
+			dst.SetFloat(parsed)
+		default:
+			return fmt.Errorf("parameter %s has the unsupported type %s", name, t)
+		}
+	}
+	if err != nil {
+		return invalid(name, err)
+	}
+	return nil
+}
+
+// parseUUID parses a UUID, with or without its dashes or braces, into its canonical form.
+func parseUUID(raw string) (UUID, error) {
+	digits := strings.ReplaceAll(strings.Trim(raw, "{}"), "-", "")
+	decoded, err := hex.DecodeString(digits)
+	if err != nil || len(decoded) != 16 {
+		return "", errors.New("invalid UUID")
+	}
+	s := hex.EncodeToString(decoded)
+	return UUID(s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]), nil
+}
+
+// splitValues splits the comma-separated values of a repeated param, skipping the empty ones.
+func splitValues(raws []string) []string {
+	var values []string
+	for _, raw := range raws {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// checkEnum fails if value isn't one of allowed.
+func checkEnum(value, name string, allowed []string) error {
+	if slices.Contains(allowed, value) {
+		return nil
+	}
+	err := errs.NewI18NError(fmt.Sprintf("parameter %s must be one of %v: %%w", name, allowed), errs.ErrInvalidInput, "invalidenum")
+	return err.WithParam("param", name).WithParam("allowed", strings.Join(allowed, ", "))
+}
+
+// toStrings converts a slice of string-based values to strings.
+func toStrings[T ~string](values []T) []string {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = string(value)
+	}
+	return strs
+}
+
+// missing returns the error of a required parameter which wasn't given.
+func missing(name string) error {
+	err := errs.NewI18NError(fmt.Sprintf("parameter %s is required: %%w", name), errs.ErrInvalidInput, "missingparam")
+	return err.WithParam("param", name)
+}
+
+// invalid returns the error of a parameter whose value couldn't be parsed.
+func invalid(name string, cause error) error {
+	err := errs.NewI18NError(fmt.Sprintf("parameter %s is invalid: %%w", name), errors.Join(errs.ErrInvalidInput, cause), "invalidparam")
+	return err.WithParam("param", name)
+}
@@ -0,0 +1,110 @@
+package params
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// newRequest builds a request to url matched against pattern, so its path values are set.
+func newRequest(pattern, url string) *http.Request {
+	var matched *http.Request
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) { matched = r })
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+	return matched
+}
+
+// expectParamError checks err is an I18nError with the given code, naming the param and wrapping errs.ErrInvalidInput.
+func expectParamError(t *testing.T, err error, code, param string) {
+	t.Helper()
+	i18n := &errs.I18nError{}
+	if !errors.As(err, i18n) || i18n.Code != code || i18n.Params["param"] != param || !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected the error %s for %s, got %v", code, param, err)
+	}
+}
+
+// TestPathAndQuery checks typed path values and query params are parsed, with defaults for the missing ones.
+func TestPathAndQuery(t *testing.T) {
+	r := newRequest("GET /boats/{id}/{ref}", "/boats/42/9B2E1C1A-0F4D-4A55-8A2B-1234567890AB?length=12.5&active=true&since=2024-05-01&tag=a,b&tag=c&sort=name")
+	if id, err := Path[uint32](r, "id"); err != nil || id != 42 {
+		t.Errorf("Expected the id 42, got %v %v", id, err)
+	}
+	if ref, err := Path[UUID](r, "ref"); err != nil || ref != "9b2e1c1a-0f4d-4a55-8a2b-1234567890ab" {
+		t.Errorf("Expected the canonical UUID, got %v %v", ref, err)
+	}
+	if length, err := Query(r, "length", 0.0); err != nil || length != 12.5 {
+		t.Errorf("Expected the length 12.5, got %v %v", length, err)
+	}
+	if active, err := Query(r, "active", false); err != nil || !active {
+		t.Errorf("Expected active, got %v %v", active, err)
+	}
+	if since, err := Query(r, "since", time.Time{}); err != nil || !since.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the date, got %v %v", since, err)
+	}
+	if page, err := Query(r, "page", 1); err != nil || page != 1 {
+		t.Errorf("Expected the default page, got %v %v", page, err)
+	}
+	if tags, err := QuerySlice[string](r, "tag"); err != nil || !reflect.DeepEqual(tags, []string{"a", "b", "c"}) {
+		t.Errorf("Expected the tags, got %v %v", tags, err)
+	}
+	if sort, err := QueryEnum(r, "sort", "length", "name", "length"); err != nil || sort != "name" {
+		t.Errorf("Expected the sort, got %v %v", sort, err)
+	}
+
+	_, err := Path[int](r, "missing")
+	expectParamError(t, err, "missingparam", "missing")
+	_, err = Path[uint8](newRequest("GET /boats/{id}", "/boats/300"), "id")
+	expectParamError(t, err, "invalidparam", "id")
+	_, err = Query(newRequest("GET /boats", "/boats?active=maybe"), "active", false)
+	expectParamError(t, err, "invalidparam", "active")
+	_, err = QuerySlice[int](newRequest("GET /boats", "/boats?id=1,x"), "id")
+	expectParamError(t, err, "invalidparam", "id")
+	_, err = QueryEnum(newRequest("GET /boats", "/boats?sort=price"), "sort", "name", "name", "length")
+	expectParamError(t, err, "invalidenum", "sort")
+}
+
+// TestBind checks structs are filled from their tags, and that the first invalid param fails the binding.
+func TestBind(t *testing.T) {
+	type listBoats struct {
+		Port    uint32        `path:"port"`
+		Page    int           `query:"page" default:"1"`
+		Sort    string        `query:"sort" enum:"name,length" default:"name"`
+		Since   time.Time     `query:"since" required:"true"`
+		Tags    []string      `query:"tag"`
+		Length  *float64      `query:"length"`
+		Timeout time.Duration `query:"timeout" default:"5s"`
+		ignored string
+	}
+	var got listBoats
+	r := newRequest("GET /ports/{port}/boats", "/ports/7/boats?since=2024-05-01T10:00:00Z&tag=a&tag=b,c&length=10")
+	if err := Bind(r, &got); err != nil {
+		t.Fatal(err)
+	}
+	length := 10.0
+	expected := listBoats{Port: 7, Page: 1, Sort: "name", Since: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Tags: []string{"a", "b", "c"}, Length: &length, Timeout: 5 * time.Second}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	tests := map[string]struct{ code, param string }{
+		"/ports/7/boats": {"missingparam", "since"},
+		"/ports/7/boats?since=2024-05-01&page=first":  {"invalidparam", "page"},
+		"/ports/7/boats?since=2024-05-01&sort=price":  {"invalidenum", "sort"},
+		"/ports/x/boats?since=2024-05-01":             {"invalidparam", "port"},
+		"/ports/7/boats?since=2024-05-01&length=long": {"invalidparam", "length"},
+	}
+	for url, tt := range tests {
+		err := Bind(newRequest("GET /ports/{port}/boats", url), &listBoats{})
+		expectParamError(t, err, tt.code, tt.param)
+	}
+	if err := Bind(r, listBoats{}); err == nil {
+		t.Errorf("Expected binding into a non-pointer to fail")
+	}
+}
@@ -0,0 +1,168 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// operation is an operation of a JSON Patch document.
+type operation struct {
+	Op    string          `json:"op"`    // Op is the operation: add, remove, replace, move, copy or test.
+	Path  *string         `json:"path"`  // Path is the JSON Pointer of the value the operation applies to.
+	From  *string         `json:"from"`  // From is the JSON Pointer of the value moved or copied.
+	Value json.RawMessage `json:"value"` // Value is the value added, replacing or tested, null included.
+}
+
+// applyOperations applies the operations of a JSON Patch to doc in order, as defined by RFC 6902, and
+// returns the patched document. doc is modified, so it must be a copy. The patch is atomic, as the
+// caller discards the document when any operation fails.
+func applyOperations(doc any, operations []operation) (any, error) {
+	for _, op := range operations {
+		if op.Path == nil {
+			return nil, malformed(fmt.Errorf("%s operation without path", op.Op))
+		}
+		path, err := parsePointer(*op.Path)
+		if err != nil {
+			return nil, malformed(err)
+		}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			value, err := op.value()
+			if err != nil {
+				return nil, err
+			}
+			switch op.Op {
+			case "add":
+				doc, err = add(doc, path, value)
+			case "replace":
+				if doc, err = remove(doc, path); err == nil {
+					doc, err = add(doc, path, value)
+				}
+			case "test":
+				if current, ok := path.get(doc); !ok || !equal(current, value) {
+					err := errs.NewI18NError("patch test failed: %w", errs.ErrConflict, "patchtestfailed")
+					return nil, err.WithParam("path", path.String())
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+		case "remove":
+			if doc, err = remove(doc, path); err != nil {
+				return nil, err
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return nil, malformed(fmt.Errorf("%s operation without from", op.Op))
+			}
+			from, err := parsePointer(*op.From)
+			if err != nil {
+				return nil, malformed(err)
+			}
+			value, ok := from.get(doc)
+			if !ok {
+				return nil, conflict(from)
+			}
+			if op.Op == "move" {
+				if len(path) > len(from) && from.contains(path) {
+					return nil, malformed(fmt.Errorf("can't move %s into itself", from))
+				}
+				if doc, err = remove(doc, from); err != nil {
+					return nil, err
+				}
+			} else {
+				value = clone(value)
+			}
+			if doc, err = add(doc, path, value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, malformed(fmt.Errorf("unknown operation %q", op.Op))
+		}
+	}
+	return doc, nil
+}
+
+// value returns the value of an add, replace or test operation.
+func (op operation) value() (any, error) {
+	if op.Value == nil {
+		return nil, malformed(fmt.Errorf("%s operation without value", op.Op))
+	}
+	var value any
+	if err := decode(op.Value, &value); err != nil {
+		return nil, malformed(err)
+	}
+	return value, nil
+}
+
+// add adds value to doc at p and returns the resulting document: it's set as the member of an object,
+// replacing any previous one, or inserted into an array. The container of p must exist.
+func add(doc any, p pointer, value any) (any, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+	parent, token := p.parent()
+	container, _ := parent.get(doc)
+	switch container := container.(type) {
+	case map[string]any:
+		container[token] = value
+		return doc, nil
+	case []any:
+		i, ok := index(token, len(container))
+		if !ok {
+			return nil, conflict(p)
+		}
+		return set(doc, parent, slices.Insert(container, i, value)), nil
+	default:
+		return nil, conflict(p)
+	}
+}
+
+// remove removes the value at p from doc and returns the resulting document. The value must exist.
+func remove(doc any, p pointer) (any, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	if _, ok := p.get(doc); !ok {
+		return nil, conflict(p)
+	}
+	parent, token := p.parent()
+	container, _ := parent.get(doc)
+	switch container := container.(type) {
+	case map[string]any:
+		delete(container, token)
+		return doc, nil
+	case []any:
+		i, _ := index(token, len(container))
+		return set(doc, parent, slices.Delete(container, i, i+1)), nil
+	default:
+		return nil, conflict(p)
+	}
+}
+
+// set replaces the existing value at p with value and returns the resulting document.
+func set(doc any, p pointer, value any) any {
+	if len(p) == 0 {
+		return value
+	}
+	parent, token := p.parent()
+	container, _ := parent.get(doc)
+	switch container := container.(type) {
+	case map[string]any:
+		container[token] = value
+	case []any:
+		i, _ := index(token, len(container))
+		container[i] = value
+	}
+	return doc
+}
+
+// conflict returns the error of an operation referring to a value which doesn't exist in the resource.
+func conflict(p pointer) error {
+	err := errs.NewI18NError("patch path doesn't exist: %w", errs.ErrConflict, "patchconflict")
+	return err.WithParam("path", p.String())
+}
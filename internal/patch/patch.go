@@ -0,0 +1,198 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"github.com/lucastomic/msBaseProj/internal/codec"
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// Media types of the patch documents accepted by Apply.
+const (
+	JSONPatchMediaType  = "application/json-patch+json"  // JSONPatchMediaType is the media type of RFC 6902 JSON Patch documents.
+	MergePatchMediaType = "application/merge-patch+json" // MergePatchMediaType is the media type of RFC 7396 JSON Merge Patch documents.
+)
+
+// MediaTypes are the media types Apply accepts, meant to be the ContentTypes of PATCH routes, as the
+// server only accepts the media types of its codecs by default.
+var MediaTypes = []string{JSONPatchMediaType, MergePatchMediaType}
+
+// Options restricts what a patch can do.
+type Options struct {
+	// ReadOnly are the JSON Pointers of the fields patches can't change, like /id or /owner/name, which
+	// also protect the fields nested in them. Patches leaving their value untouched are still accepted.
+	ReadOnly []string
+}
+
+// Apply applies the patch sent in the body of r to the resource pointed to by resource, which is only
+// replaced with the patched one when everything succeeded. The patch is a JSON Patch or a JSON Merge Patch,
+// told apart by the Content-Type of the request, applied to the JSON representation of the resource.
+// The patched representation is strictly decoded into a new value of the resource's type, whose fields out of
+// it, like the unexported ones or the ones tagged json:"-", keep the resource's values, and validated afterwards
+// if the type has a Validate() error method. Every failure is an errs.I18nError: patches which are malformed,
+// touch read-only fields or leave an invalid resource wrap errs.ErrInvalidInput, and JSON Patches which don't
+// fit the resource, because a path doesn't exist or a test operation fails, wrap errs.ErrConflict.
+// It panics if resource isn't a non-nil pointer or a read-only field isn't a valid JSON Pointer.
+func Apply(r *http.Request, resource any, opts Options) error {
+	target := reflect.ValueOf(resource)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		panic(fmt.Sprintf("patch: resource must be a non-nil pointer, got %T", resource))
+	}
+	readOnly := make([]pointer, len(opts.ReadOnly))
+	for i, field := range opts.ReadOnly {
+		p, err := parsePointer(field)
+		if err != nil {
+			panic(fmt.Sprintf("patch: read-only field %q: %v", field, err))
+		}
+		readOnly[i] = p
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != JSONPatchMediaType && mediaType != MergePatchMediaType {
+		return errs.NewI18NError("content type not accepted for patches: %w", errs.ErrUnsupportedMediaType, "unsupportedmediatype")
+	}
+	var body json.RawMessage
+	if err := codec.DecodeJSON(r.Body, &body, codec.DefaultMaxDepth); err != nil {
+		return err
+	}
+	doc, err := toDocument(resource)
+	if err != nil {
+		return err
+	}
+
+	var patched any
+	if mediaType == MergePatchMediaType {
+		var mergePatch any
+		if err := decode(body, &mergePatch); err != nil {
+			return malformed(err)
+		}
+		patched = merge(doc, mergePatch)
+	} else {
+		var operations []operation
+		if err := decode(body, &operations); err != nil {
+			return malformed(err)
+		}
+		if patched, err = applyOperations(clone(doc), operations); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range readOnly {
+		before, hadBefore := p.get(doc)
+		after, hasAfter := p.get(patched)
+		if hadBefore != hasAfter || !equal(before, after) {
+			err := errs.NewI18NError("patch changes a read-only field: %w", errs.ErrInvalidInput, "readonlyfield")
+			return err.WithParam("field", p.String())
+		}
+	}
+
+	encoded, err := json.Marshal(patched)
+	if err != nil {
+		return err
+	}
+	result := reflect.New(target.Elem().Type())
+	if err := codec.DecodeJSON(bytes.NewReader(encoded), result.Interface(), codec.DefaultMaxDepth); err != nil {
+		return err
+	}
+	if result.Elem().Kind() == reflect.Struct {
+		result.Elem().Set(keepHidden(result.Elem(), target.Elem()))
+	}
+	if validator, ok := result.Interface().(interface{ Validate() error }); ok {
+		if err := validator.Validate(); err != nil {
+			return invalidResource(err)
+		}
+	}
+	target.Elem().Set(result.Elem())
+	return nil
+}
+
+// keepHidden returns a copy of original with the fields of its JSON representation taken from patched, so
+// the fields out of it, unexported or tagged json:"-", keep their values instead of being zeroed. Nested
+// structs, pointed to or not, are merged the same way, while slices and maps are taken from patched as a whole.
+func keepHidden(patched reflect.Value, original reflect.Value) reflect.Value {
+	result := reflect.New(original.Type()).Elem()
+	result.Set(original)
+	for i := range result.NumField() {
+		field := result.Type().Field(i)
+		if !field.IsExported() || field.Tag.Get("json") == "-" {
+			continue
+		}
+		from, to := patched.Field(i), original.Field(i)
+		switch {
+		case from.Kind() == reflect.Struct:
+			result.Field(i).Set(keepHidden(from, to))
+		case from.Kind() == reflect.Pointer && from.Type().Elem().Kind() == reflect.Struct && !from.IsNil() && !to.IsNil():
+			merged := reflect.New(from.Type().Elem())
+			merged.Elem().Set(keepHidden(from.Elem(), to.Elem()))
+			result.Field(i).Set(merged)
+		default:
+			result.Field(i).Set(from)
+		}
+	}
+	return result
+}
+
+// merge returns the result of applying a JSON Merge Patch to doc, as defined by RFC 7396: objects are
+// merged recursively, null members remove the matching ones and any other value replaces the target.
+// doc isn't modified.
+func merge(doc any, mergePatch any) any {
+	patchObject, ok := mergePatch.(map[string]any)
+	if !ok {
+		return mergePatch
+	}
+	docObject, ok := doc.(map[string]any)
+	result := make(map[string]any, len(docObject))
+	if ok {
+		for name, value := range docObject {
+			result[name] = value
+		}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(result, name)
+		} else {
+			result[name] = merge(result[name], value)
+		}
+	}
+	return result
+}
+
+// toDocument returns the generic value of the JSON representation of resource.
+func toDocument(resource any) (any, error) {
+	encoded, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := decode(encoded, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decode decodes a JSON document keeping its numbers as json.Number, so they don't lose precision.
+func decode(data []byte, dst any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(dst)
+}
+
+// malformed returns the error of a patch document which can't be understood.
+func malformed(cause error) error {
+	return errs.NewI18NError("malformed patch: %w", errors.Join(errs.ErrInvalidInput, cause), "malformedpatch")
+}
+
+// invalidResource returns the error of a patch leaving the resource invalid. Translated validation
+// errors reporting invalid input or a conflict are kept, so clients are told what's wrong.
+func invalidResource(cause error) error {
+	if errors.As(cause, &errs.I18nError{}) && (errors.Is(cause, errs.ErrInvalidInput) || errors.Is(cause, errs.ErrConflict)) {
+		return cause
+	}
+	return errs.NewI18NError("patched resource is invalid: %w", errors.Join(errs.ErrInvalidInput, cause), "invalidpatchedresource")
+}
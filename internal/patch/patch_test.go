@@ -0,0 +1,142 @@
+package patch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+type owner struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Notes string `json:"-"`
+}
+
+type boat struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Length int64    `json:"length"`
+	Tags   []string `json:"tags"`
+	Owner  *owner   `json:"owner,omitempty"`
+	Secret string   `json:"-"`
+	synced bool
+}
+
+func (b boat) Validate() error {
+	if b.Name == "" {
+		return errors.New("a boat needs a name")
+	}
+	return nil
+}
+
+func newBoat() boat {
+	return boat{ID: 1, Name: "Nautilus", Length: 1 << 60, Tags: []string{"submarine", "fiction"}, Owner: &owner{Name: "Nemo", Notes: "captain"}, Secret: "hash", synced: true}
+}
+
+func patchRequest(mediaType string, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPatch, "/boats/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", mediaType)
+	return req
+}
+
+// TestApply checks JSON Patches and JSON Merge Patches are applied to the JSON representation of the resource,
+// keeping the fields out of it.
+func TestApply(t *testing.T) {
+	for name, test := range map[string]struct {
+		mediaType string
+		patch     string
+		expected  func(b *boat)
+	}{
+		"merge": {MergePatchMediaType, `{"name":"Argonaut","owner":{"email":"nemo@sea"},"tags":null}`, func(b *boat) {
+			b.Name, b.Owner.Email, b.Tags = "Argonaut", "nemo@sea", nil
+		}},
+		"merge removing an object": {MergePatchMediaType + "; charset=utf-8", `{"owner":null}`, func(b *boat) {
+			b.Owner = nil
+		}},
+		"add and replace": {JSONPatchMediaType, `[{"op":"add","path":"/tags/-","value":"steam"},{"op":"add","path":"/tags/0","value":"iron"},{"op":"replace","path":"/name","value":"Argonaut"}]`, func(b *boat) {
+			b.Tags, b.Name = []string{"iron", "submarine", "fiction", "steam"}, "Argonaut"
+		}},
+		"remove": {JSONPatchMediaType, `[{"op":"remove","path":"/tags/0"},{"op":"remove","path":"/owner"}]`, func(b *boat) {
+			b.Tags, b.Owner = []string{"fiction"}, nil
+		}},
+		"move and copy": {JSONPatchMediaType, `[{"op":"copy","from":"/owner/name","path":"/name"},{"op":"move","from":"/tags/1","path":"/tags/0"}]`, func(b *boat) {
+			b.Name, b.Tags = "Nemo", []string{"fiction", "submarine"}
+		}},
+		"test": {JSONPatchMediaType, `[{"op":"test","path":"/length","value":1152921504606846976.0},{"op":"test","path":"/owner","value":{"name":"Nemo"}},{"op":"replace","path":"/length","value":70}]`, func(b *boat) {
+			b.Length = 70
+		}},
+	} {
+		b := newBoat()
+		if err := Apply(patchRequest(test.mediaType, test.patch), &b, Options{}); err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+			continue
+		}
+		expected := newBoat()
+		test.expected(&expected)
+		if !reflect.DeepEqual(b, expected) {
+			t.Errorf("%s: expected %+v, got %+v", name, expected, b)
+		}
+	}
+}
+
+// TestApplyErrors checks failed patches leave the resource untouched and are reported as translated errors.
+func TestApplyErrors(t *testing.T) {
+	for name, test := range map[string]struct {
+		mediaType string
+		patch     string
+		code      string
+		sentinel  error
+	}{
+		"unsupported media type":  {"application/json", `{"name":"Argonaut"}`, "unsupportedmediatype", errs.ErrUnsupportedMediaType},
+		"empty body":              {MergePatchMediaType, ``, "emptybody", errs.ErrInvalidInput},
+		"not an array":            {JSONPatchMediaType, `{"op":"remove","path":"/name"}`, "malformedpatch", errs.ErrInvalidInput},
+		"unknown operation":       {JSONPatchMediaType, `[{"op":"rename","path":"/name"}]`, "malformedpatch", errs.ErrInvalidInput},
+		"missing value":           {JSONPatchMediaType, `[{"op":"add","path":"/name"}]`, "malformedpatch", errs.ErrInvalidInput},
+		"invalid pointer":         {JSONPatchMediaType, `[{"op":"remove","path":"name"}]`, "malformedpatch", errs.ErrInvalidInput},
+		"move into itself":        {JSONPatchMediaType, `[{"op":"move","from":"/owner","path":"/owner/name"}]`, "malformedpatch", errs.ErrInvalidInput},
+		"missing path":            {JSONPatchMediaType, `[{"op":"replace","path":"/owner/phone","value":"1"}]`, "patchconflict", errs.ErrConflict},
+		"index out of range":      {JSONPatchMediaType, `[{"op":"add","path":"/tags/5","value":"steam"}]`, "patchconflict", errs.ErrConflict},
+		"failed test":             {JSONPatchMediaType, `[{"op":"replace","path":"/name","value":"Argonaut"},{"op":"test","path":"/id","value":2}]`, "patchtestfailed", errs.ErrConflict},
+		"formatting path":         {JSONPatchMediaType, `[{"op":"remove","path":"/%d"}]`, "patchconflict", errs.ErrConflict},
+		"formatting test path":    {JSONPatchMediaType, `[{"op":"test","path":"/x%v","value":1}]`, "patchtestfailed", errs.ErrConflict},
+		"read-only field":         {JSONPatchMediaType, `[{"op":"replace","path":"/id","value":2}]`, "readonlyfield", errs.ErrInvalidInput},
+		"nested read-only field":  {MergePatchMediaType, `{"owner":{"name":"Ned"}}`, "readonlyfield", errs.ErrInvalidInput},
+		"removed read-only field": {MergePatchMediaType, `{"owner":null}`, "readonlyfield", errs.ErrInvalidInput},
+		"unknown field":           {MergePatchMediaType, `{"color":"yellow"}`, "unknownfield", errs.ErrInvalidInput},
+		"wrong type":              {MergePatchMediaType, `{"length":"long"}`, "invalidfieldtype", errs.ErrInvalidInput},
+		"invalid result":          {MergePatchMediaType, `{"name":""}`, "invalidpatchedresource", errs.ErrInvalidInput},
+	} {
+		b := newBoat()
+		err := Apply(patchRequest(test.mediaType, test.patch), &b, Options{ReadOnly: []string{"/id", "/owner/name"}})
+		i18n := errs.I18nError{}
+		if !errors.As(err, &i18n) || i18n.Code != test.code || !errors.Is(err, test.sentinel) {
+			t.Errorf("%s: expected a %s error, got %v", name, test.code, err)
+		}
+		if !reflect.DeepEqual(b, newBoat()) {
+			t.Errorf("%s: expected the resource to be untouched, got %+v", name, b)
+		}
+	}
+
+	b := newBoat()
+	if err := Apply(patchRequest(JSONPatchMediaType, `[{"op":"replace","path":"/id","value":1}]`), &b, Options{ReadOnly: []string{"/id"}}); err != nil {
+		t.Errorf("Expected patches leaving read-only fields untouched to be accepted, got %v", err)
+	}
+}
+
+// TestParsePointer checks JSON Pointers are unescaped and the invalid ones rejected.
+func TestParsePointer(t *testing.T) {
+	p, err := parsePointer("/a~1b/m~0n/0")
+	if err != nil || !reflect.DeepEqual(p, pointer{"a/b", "m~n", "0"}) || p.String() != "/a~1b/m~0n/0" {
+		t.Errorf("Unexpected pointer %q, %v", p, err)
+	}
+	for _, invalid := range []string{"a", "/a~2", "/a~"} {
+		if _, err := parsePointer(invalid); err == nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// pointer is a parsed RFC 6901 JSON Pointer: the unescaped reference tokens. The empty pointer refers
+// to the whole document.
+type pointer []string
+
+// parsePointer parses a JSON Pointer like /owner/name, unescaping ~1 into / and ~0 into ~.
+func parsePointer(s string) (pointer, error) {
+	if s == "" {
+		return pointer{}, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, errors.New("a JSON pointer must start with /")
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, errors.New("a JSON pointer can only escape ~0 and ~1")
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// String returns the pointer in its escaped form.
+func (p pointer) String() string {
+	var b strings.Builder
+	for _, token := range p {
+		b.WriteByte('/')
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+// parent returns the pointer to the container of the value p refers to, and the token of the value in it.
+// p must not be empty.
+func (p pointer) parent() (pointer, string) {
+	return p[:len(p)-1], p[len(p)-1]
+}
+
+// contains reports whether other refers to the value p refers to or to a value nested in it.
+func (p pointer) contains(other pointer) bool {
+	if len(other) < len(p) {
+		return false
+	}
+	for i := range p {
+		if p[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the value p refers to in doc, and whether there is one.
+func (p pointer) get(doc any) (any, bool) {
+	value := doc
+	for _, token := range p {
+		switch container := value.(type) {
+		case map[string]any:
+			child, ok := container[token]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []any:
+			i, ok := index(token, len(container))
+			if !ok || i == len(container) {
+				return nil, false
+			}
+			value = container[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// index parses the token referring to an element of an array of the given length. Besides the existing
+// elements, the length itself and "-" refer to the position past the last element, where values are appended.
+func index(token string, length int) (int, bool) {
+	if token == "-" {
+		return length, true
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > length {
+		return 0, false
+	}
+	return i, true
+}
+
+// equal reports whether two generic JSON values are equal, comparing numbers by their value
+// and objects regardless of the order of their members.
+func equal(a any, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for name, value := range a {
+			other, ok := b[name]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okA := new(big.Float).SetString(a.String())
+		y, okB := new(big.Float).SetString(b.String())
+		return okA && okB && x.Cmp(y) == 0
+	default:
+		return a == b
+	}
+}
+
+// clone returns a deep copy of a generic JSON value.
+func clone(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(value))
+		for name, child := range value {
+			copied[name] = clone(child)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(value))
+		for i, child := range value {
+			copied[i] = clone(child)
+		}
+		return copied
+	default:
+		return value
+	}
+}
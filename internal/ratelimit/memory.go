@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the number of calls to Allow between sweeps of idle clients.
+const sweepEvery = 1024
+
+// MemoryStore is an in-process Store. Limits are only enforced per instance, so services running
+// several replicas should use a shared store instead.
+type MemoryStore struct {
+	mu      sync.Mutex
+	clients map[string]*clientState // clients holds the state of every key.
+	calls   int                     // calls counts the calls to Allow since the last sweep.
+	now     func() time.Time        // now returns the current time.
+}
+
+// clientState is the state of a key under both algorithms.
+type clientState struct {
+	tokens   float64       // tokens left in the bucket. Used by TokenBucket.
+	last     time.Time     // last is when the state was last updated.
+	start    time.Time     // start is when the current fixed window began. Used by SlidingWindow.
+	current  int           // current is the count of the current fixed window. Used by SlidingWindow.
+	previous int           // previous is the count of the previous fixed window. Used by SlidingWindow.
+	window   time.Duration // window is the rule's window, to know when the state becomes idle.
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{clients: map[string]*clientState{}, now: time.Now}
+}
+
+// Allow implements Store.
+func (m *MemoryStore) Allow(_ context.Context, key string, rule Rule) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+
+	state, ok := m.clients[key]
+	if !ok {
+		state = &clientState{tokens: float64(rule.Limit), last: now, start: now, window: rule.Window}
+		m.clients[key] = state
+	}
+	if rule.Algorithm == SlidingWindow {
+		return state.slidingWindow(rule, now), nil
+	}
+	return state.tokenBucket(rule, now), nil
+}
+
+// tokenBucket refills the bucket for the time elapsed since the last request and takes a token if there's one.
+func (s *clientState) tokenBucket(rule Rule, now time.Time) Result {
+	rate := float64(rule.Limit) / rule.Window.Seconds()
+	s.tokens = math.Min(float64(rule.Limit), s.tokens+now.Sub(s.last).Seconds()*rate)
+	s.last = now
+
+	result := Result{Limit: rule.Limit}
+	if s.tokens >= 1 {
+		s.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - s.tokens) / rate)
+	}
+	result.Remaining = int(s.tokens)
+	result.Reset = seconds((float64(rule.Limit) - s.tokens) / rate)
+	return result
+}
+
+// slidingWindow rolls the fixed windows forward and counts the request if the weighted count allows it.
+func (s *clientState) slidingWindow(rule Rule, now time.Time) Result {
+	elapsed := now.Sub(s.start)
+	if elapsed >= 2*rule.Window {
+		s.start, s.previous, s.current = now, 0, 0
+	} else if elapsed >= rule.Window {
+		s.start, s.previous, s.current = s.start.Add(rule.Window), s.current, 0
+	}
+	s.last = now
+
+	elapsedRatio := float64(now.Sub(s.start)) / float64(rule.Window)
+	weighted := float64(s.previous)*(1-elapsedRatio) + float64(s.current)
+
+	result := Result{Limit: rule.Limit, Reset: s.start.Add(rule.Window).Sub(now)}
+	if weighted+1 <= float64(rule.Limit) {
+		s.current++
+		weighted++
+		result.Allowed = true
+	} else if s.previous > 0 {
+		// The weighted count decreases as the previous window slides out. Wait until it makes room for one more.
+		excess := weighted + 1 - float64(rule.Limit)
+		result.RetryAfter = seconds(excess / float64(s.previous) * rule.Window.Seconds())
+	} else {
+		result.RetryAfter = result.Reset
+	}
+	result.Remaining = max(0, rule.Limit-int(math.Ceil(weighted)))
+	return result
+}
+
+// sweep removes the clients which have been idle long enough to have their quota fully restored.
+// It only runs every sweepEvery calls. The caller must hold the lock.
+func (m *MemoryStore) sweep(now time.Time) {
+	m.calls++
+	if m.calls < sweepEvery {
+		return
+	}
+	m.calls = 0
+	for key, state := range m.clients {
+		if now.Sub(state.last) > 2*state.window {
+			delete(m.clients, key)
+		}
+	}
+}
+
+// seconds converts a number of seconds into a time.Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/contextypes"
+)
+
+// clock is a manually advanced time source for the store.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestStore() (*MemoryStore, *clock) {
+	c := &clock{time.Date(2024, 2, 3, 20, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = c.now
+	return store, c
+}
+
+// TestTokenBucket checks a burst up to the limit is allowed and tokens are refilled over time.
+func TestTokenBucket(t *testing.T) {
+	store, c := newTestStore()
+	rule := Rule{Algorithm: TokenBucket, Limit: 2, Window: 2 * time.Second}
+	for i := 0; i < 2; i++ {
+		if res, _ := store.Allow(context.Background(), "a", rule); !res.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+	res, _ := store.Allow(context.Background(), "a", rule)
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Errorf("Expected rejection with a 1s retry, got %+v", res)
+	}
+	if res, _ := store.Allow(context.Background(), "b", rule); !res.Allowed {
+		t.Errorf("Expected another key to have its own bucket")
+	}
+
+	c.t = c.t.Add(time.Second)
+	if res, _ := store.Allow(context.Background(), "a", rule); !res.Allowed {
+		t.Errorf("Expected a token to be refilled after a second")
+	}
+}
+
+// TestSlidingWindow checks the count of the previous window is weighted by its overlap with the sliding one.
+func TestSlidingWindow(t *testing.T) {
+	store, c := newTestStore()
+	rule := Rule{Algorithm: SlidingWindow, Limit: 4, Window: time.Minute}
+	for i := 0; i < 4; i++ {
+		store.Allow(context.Background(), "a", rule)
+	}
+	if res, _ := store.Allow(context.Background(), "a", rule); res.Allowed {
+		t.Errorf("Expected the fifth request to be rejected")
+	}
+
+	// Halfway through the next window half of the previous count still weighs in.
+	c.t = c.t.Add(90 * time.Second)
+	allowed := 0
+	for i := 0; i < 4; i++ {
+		if res, _ := store.Allow(context.Background(), "a", rule); res.Allowed {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("Expected 2 requests allowed, got %d", allowed)
+	}
+}
+
+// user is a principal identified by its ID() method.
+type user struct{ id string }
+
+func (u *user) ID() string { return u.id }
+
+// TestByPrincipal checks clients are told apart by stable principal identifiers, and principals which have
+// none, like pointers to plain structs, are keyed by their IP address instead of their address in memory.
+func TestByPrincipal(t *testing.T) {
+	withPrincipal := func(principal any) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		return r.WithContext(context.WithValue(r.Context(), contextypes.ContextAuthKey{}, principal))
+	}
+	type session struct{ token string }
+	for _, test := range []struct {
+		principal any
+		key       string
+	}{
+		{nil, "ip:10.0.0.1"},
+		{"ana", "principal:ana"},
+		{&user{"42"}, "principal:42"},
+		{&session{"secret"}, "ip:10.0.0.1"},
+	} {
+		if key := ByPrincipal(withPrincipal(test.principal)); key != test.key {
+			t.Errorf("%#v: expected the key %s, got %s", test.principal, test.key, key)
+		}
+	}
+}
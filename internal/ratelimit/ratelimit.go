@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/contextypes"
+)
+
+// Algorithm is the algorithm used to decide whether a request is allowed.
+type Algorithm int
+
+const (
+	// TokenBucket allows bursts of up to Limit requests and refills the bucket at Limit requests per Window.
+	TokenBucket Algorithm = iota
+	// SlidingWindow allows up to Limit requests in any Window, approximated by weighting the count
+	// of the previous fixed window by how much of it overlaps the sliding one.
+	SlidingWindow
+)
+
+// KeyFunc returns the key of the client a request is counted against.
+type KeyFunc func(*http.Request) string
+
+// Rule defines a rate limit: how many requests are allowed per window, with which algorithm
+// and how clients are told apart.
+type Rule struct {
+	Algorithm Algorithm     // Algorithm is the algorithm applied. TokenBucket by default.
+	Limit     int           // Limit is the number of requests allowed per Window. Zero disables the rule.
+	Window    time.Duration // Window is the period Limit applies to.
+	Key       KeyFunc       // Key identifies the client of a request. ByIP when nil.
+}
+
+// Enabled reports whether the rule limits anything.
+func (r Rule) Enabled() bool {
+	return r.Limit > 0 && r.Window > 0
+}
+
+// Result is the outcome of evaluating a request against a rule.
+type Result struct {
+	Allowed    bool          // Allowed reports whether the request can go through.
+	Limit      int           // Limit is the rule's limit.
+	Remaining  int           // Remaining is how many requests the client can still make right now.
+	Reset      time.Duration // Reset is the time until the client's quota is fully restored.
+	RetryAfter time.Duration // RetryAfter is the time the client must wait before retrying a rejected request.
+}
+
+// Store keeps the state of every client and evaluates requests against rules.
+// The algorithm runs inside the store so a shared store, e.g. one backed by Redis, can apply it atomically.
+type Store interface {
+	// Allow counts a request of the client identified by key against rule and reports whether it's allowed.
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// ByIP identifies clients by the IP address the request comes from.
+// Forwarding headers are ignored, as they can be forged unless a trusted proxy sets them.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ByAPIKey identifies clients by the API key sent in the given header, falling back to their IP address
+// for requests without one.
+func ByAPIKey(header string) KeyFunc {
+	return func(r *http.Request) string {
+		if key := r.Header.Get(header); key != "" {
+			return "apikey:" + key
+		}
+		return ByIP(r)
+	}
+}
+
+// ByPrincipal identifies clients by the authenticated principal stored by the authentication middleware,
+// as told apart by contextypes.PrincipalID, falling back to their IP address for anonymous requests and
+// principals which can't be identified.
+func ByPrincipal(r *http.Request) string {
+	if principal, ok := contextypes.PrincipalID(r.Context()); ok && principal != "" {
+		return "principal:" + principal
+	}
+	return ByIP(r)
+}
+
+// ByRoute counts every request against the same key, so the limit applies to the route as a whole
+// regardless of who makes the requests.
+func ByRoute(*http.Request) string {
+	return "route"
+}
@@ -0,0 +1,308 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/pprof"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/middleware"
+)
+
+// maskedValue replaces the values of secrets in the config served by the admin server.
+const maskedValue = "****"
+
+// secretNames are the lowercase fragments of the field names whose values are masked even if they aren't
+// tagged as secrets.
+var secretNames = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "privatekey", "credential"}
+
+// AdminConfig configures the admin server, a separate listener exposing profiling and runtime information
+// which mustn't be reachable from the public one.
+type AdminConfig struct {
+	Listener ListenerSpec // Listener is where the admin server listens, e.g. a loopback address or a Unix socket.
+	// AuthMiddleware protects every admin endpoint. It can only be nil for Unix listeners,
+	// which are protected by the socket file's mode instead.
+	AuthMiddleware middleware.Middleware
+	// Config returns the config in effect, served with its secrets masked. Fields tagged `secret:"true"`,
+	// or whose name looks like a secret, are masked. The server's own settings are served when nil.
+	Config func() any
+}
+
+// RouteInfo describes a route as the server serves it, with the server's defaults applied.
+type RouteInfo struct {
+	Method       string        `json:"method"`
+	Pattern      string        `json:"pattern"`
+	RequireAuth  bool          `json:"requireAuth"`
+	Timeout      time.Duration `json:"timeout"`
+	MaxBodyBytes int64         `json:"maxBodyBytes"`
+	RateLimit    string        `json:"rateLimit,omitempty"`
+	CacheTTL     time.Duration `json:"cacheTTL,omitempty"`
+	Priority     int           `json:"priority,omitempty"`
+	WebSocket    bool          `json:"websocket,omitempty"`
+}
+
+// Routes returns the table of the routes served under /api, in the order the controllers declare them.
+func (s *Server) Routes() []RouteInfo {
+	routes := []RouteInfo{}
+	for _, controller := range s.controller {
+		for _, route := range controller.Router() {
+			info := RouteInfo{
+				Method:       route.Method,
+				Pattern:      "/api" + route.Path,
+				RequireAuth:  route.RequireAuth,
+				Timeout:      route.Timeout,
+				MaxBodyBytes: route.MaxBodyBytes,
+				CacheTTL:     route.Cache.TTL,
+				Priority:     route.Priority,
+				WebSocket:    route.WebSocket != nil,
+			}
+			if info.Timeout == 0 {
+				info.Timeout = s.timeouts.Handler
+			}
+			if info.MaxBodyBytes == 0 {
+				info.MaxBodyBytes = s.maxBodyBytes
+			}
+			if route.RateLimit.Enabled() {
+				info.RateLimit = fmt.Sprintf("%d/%s", route.RateLimit.Limit, route.RateLimit.Window)
+			}
+			routes = append(routes, info)
+		}
+	}
+	return routes
+}
+
+// adminHandler builds the http.Handler of the admin server, serving under /debug:
+//   - pprof/ the runtime profiles, as served by net/http/pprof.
+//   - vars the variables published through expvar.
+//   - runtime the goroutine, memory and GC stats.
+//   - routes the route table.
+//   - build the build info embedded in the binary.
+//   - config the config in effect, with its secrets masked.
+//
+// The health probes are served too, so orchestrators can use the admin listener for them.
+func (s *Server) adminHandler() (http.Handler, error) {
+	auth := s.admin.AuthMiddleware
+	if auth == nil && s.admin.Listener.Kind != ListenUnix {
+		return nil, errors.New("the admin server requires an auth middleware unless it listens on a Unix socket")
+	}
+	middlewares := []middleware.Middleware{middleware.NewLangMiddleware()}
+	if auth != nil {
+		middlewares = append(middlewares, auth)
+	}
+	protect := func(h http.HandlerFunc) http.Handler {
+		return middleware.ChainMiddleware(h, s.handleError, middlewares...)
+	}
+
+	r := http.NewServeMux()
+	r.Handle("GET /debug/pprof/", protect(pprof.Index))
+	r.Handle("GET /debug/pprof/cmdline", protect(pprof.Cmdline))
+	r.Handle("GET /debug/pprof/profile", protect(pprof.Profile))
+	r.Handle("GET /debug/pprof/symbol", protect(pprof.Symbol))
+	r.Handle("POST /debug/pprof/symbol", protect(pprof.Symbol))
+	r.Handle("GET /debug/pprof/trace", protect(pprof.Trace))
+	r.Handle("GET /debug/vars", protect(expvar.Handler().ServeHTTP))
+	r.Handle("GET /debug/runtime", protect(func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, runtimeStats())
+	}))
+	r.Handle("GET /debug/routes", protect(func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, s.Routes())
+	}))
+	r.Handle("GET /debug/build", protect(func(w http.ResponseWriter, r *http.Request) {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			http.Error(w, "build info not available", http.StatusNotFound)
+			return
+		}
+		writeAdminJSON(w, info)
+	}))
+	r.Handle("GET /debug/config", protect(func(w http.ResponseWriter, r *http.Request) {
+		var config any = s.settings()
+		if reloaded := s.live.Load().Config; reloaded != nil {
+			config = reloaded
+		} else if s.admin.Config != nil {
+			config = s.admin.Config()
+		}
+		writeAdminJSON(w, MaskSecrets(config))
+	}))
+	r.Handle("GET "+LivenessPath, s.health.LivenessHandler())
+	r.Handle("GET "+ReadinessPath, s.health.ReadinessHandler())
+	return r, nil
+}
+
+// writeAdminJSON writes v as indented JSON, meant to be read by people.
+func writeAdminJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// runtimeStats returns the goroutine, memory and GC stats of the process.
+func runtimeStats() map[string]any {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	var lastGC time.Time
+	if mem.LastGC > 0 {
+		lastGC = time.Unix(0, int64(mem.LastGC))
+	}
+	return map[string]any{
+		"goVersion":  runtime.Version(),
+		"goroutines": runtime.NumGoroutine(),
+		"cpus":       runtime.NumCPU(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"memory": map[string]uint64{
+			"alloc":       mem.Alloc,
+			"totalAlloc":  mem.TotalAlloc,
+			"sys":         mem.Sys,
+			"heapAlloc":   mem.HeapAlloc,
+			"heapInuse":   mem.HeapInuse,
+			"heapObjects": mem.HeapObjects,
+			"stackInuse":  mem.StackInuse,
+		},
+		"gc": map[string]any{
+			"numGC":         mem.NumGC,
+			"pauseTotal":    time.Duration(mem.PauseTotalNs).String(),
+			"lastGC":        lastGC,
+			"gcCPUFraction": mem.GCCPUFraction,
+		},
+	}
+}
+
+// serverSettings are the server's own settings, served as its config when the admin server isn't given one.
+type serverSettings struct {
+	Listeners    []string `json:"listeners"`
+	AllowOrigins []string `json:"allowOrigins"`
+	Timeouts     Timeouts `json:"timeouts"`
+	MaxBodyBytes int64    `json:"maxBodyBytes"`
+	TLS          struct {
+		CertFile      string   `json:"certFile"`
+		KeyFile       string   `json:"keyFile"`
+		ClientCAFiles []string `json:"clientCAFiles"`
+		MinVersion    uint16   `json:"minVersion"`
+	} `json:"tls"`
+	RateLimit string `json:"rateLimit,omitempty"`
+}
+
+// settings returns the server's own settings.
+func (s *Server) settings() serverSettings {
+	settings := serverSettings{
+		AllowOrigins: s.Reloadable().AllowOrigins,
+		Timeouts:     s.timeouts,
+		MaxBodyBytes: s.maxBodyBytes,
+	}
+	for _, spec := range s.listenerSpecs {
+		settings.Listeners = append(settings.Listeners, spec.String())
+	}
+	if len(s.listenerSpecs) == 0 && len(s.listeners) == 0 {
+		settings.Listeners = append(settings.Listeners, ListenerSpec{Kind: ListenTCP, Address: s.listenAddr}.String())
+	}
+	for _, listener := range s.listeners {
+		settings.Listeners = append(settings.Listeners, listener.Addr().String())
+	}
+	settings.TLS.CertFile, settings.TLS.KeyFile = s.tls.CertFile, s.tls.KeyFile
+	settings.TLS.ClientCAFiles, settings.TLS.MinVersion = s.tls.ClientCAFiles, s.tls.MinVersion
+	if rule := s.globalRateLimit(); rule.Enabled() {
+		settings.RateLimit = fmt.Sprintf("%d/%s", rule.Limit, rule.Window)
+	}
+	return settings
+}
+
+// MaskSecrets returns v as a tree of maps, slices and values ready to be encoded, with the values of
+// secrets replaced. Struct fields are named by their json tag and masked when tagged `secret:"true"`
+// or when their name looks like a secret, as are map entries whose key looks like one.
+func MaskSecrets(v any) any {
+	return maskValue(reflect.ValueOf(v))
+}
+
+// maskValue masks the secrets held by v.
+func maskValue(v reflect.Value) any {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if _, ok := v.Interface().(time.Time); ok {
+			return v.Interface()
+		}
+		fields := map[string]any{}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+				// Embedded structs are flattened, as encoding/json does with their promoted fields.
+				if embedded, ok := maskValue(v.Field(i)).(map[string]any); ok {
+					maps.Copy(fields, embedded)
+				}
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if field.Tag.Get("secret") == "true" || looksSecret(field.Name) {
+				fields[name] = maskSecret(v.Field(i))
+				continue
+			}
+			fields[name] = maskValue(v.Field(i))
+		}
+		return fields
+	case reflect.Map:
+		entries := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			if looksSecret(key) {
+				entries[key] = maskSecret(iter.Value())
+				continue
+			}
+			entries[key] = maskValue(iter.Value())
+		}
+		return entries
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = maskValue(v.Index(i))
+		}
+		return items
+	case reflect.Invalid:
+		return nil
+	default:
+		return v.Interface()
+	}
+}
+
+// maskSecret returns the masked value of a secret. Empty secrets are left empty, so it shows they aren't set.
+func maskSecret(v reflect.Value) any {
+	if !v.IsValid() || v.IsZero() {
+		return ""
+	}
+	return maskedValue
+}
+
+// looksSecret reports whether name looks like the name of a secret.
+func looksSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range secretNames {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/lucastomic/msBaseProj/internal/controller/apitypes"
+	"github.com/lucastomic/msBaseProj/internal/etag"
+)
+
+// writeFile sends a file with http.ServeContent, which answers Range, If-Range and the conditional headers,
+// and copies the content with io.ReaderFrom when the writer supports it. Files aren't given a computed ETag,
+// as that would mean reading them whole first.
+func (s *Server) writeFile(req *http.Request, w http.ResponseWriter, res apitypes.Response, file apitypes.File) {
+	if closer, ok := file.Content.(io.Closer); ok {
+		defer closer.Close()
+	}
+	setCustomHeaders(w, res.Headers)
+	if file.ContentType != "" {
+		w.Header().Set("Content-Type", file.ContentType)
+	}
+	disposition := "attachment"
+	if file.Inline {
+		disposition = "inline"
+	}
+	params := map[string]string{}
+	if file.Name != "" {
+		params["filename"] = file.Name
+	}
+	if w.Header().Get("Content-Disposition") == "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, params))
+	}
+	if res.ETag != "" {
+		w.Header().Set("ETag", etag.Normalize(res.ETag))
+	}
+	http.ServeContent(w, req, file.Name, res.LastModified, file.Content)
+}
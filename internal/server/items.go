@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/codec"
+	"github.com/lucastomic/msBaseProj/internal/controller/apitypes"
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+const (
+	// NDJSONMediaType is the media type of collections streamed as newline-delimited JSON.
+	NDJSONMediaType = "application/x-ndjson"
+	// StreamErrorTrailer is the trailer carrying the error which ended a streamed collection.
+	StreamErrorTrailer = "X-Stream-Error"
+	// itemsFlushInterval is how often the items buffered are flushed to the client. Items are buffered
+	// so large collections aren't sent in tiny chunks, and flushed so slow ones still reach the client.
+	itemsFlushInterval = 100 * time.Millisecond
+)
+
+// writeItems streams a collection of items as NDJSON or as a JSON array, as negotiated from the Accept
+// header. The status code is only sent with the first item, so an error yielded before it can still be
+// answered with a 500. Streams aren't given validators, as their body isn't known upfront.
+func (s *Server) writeItems(req *http.Request, w http.ResponseWriter, res apitypes.Response, items apitypes.Items) {
+	accept := req.Header.Get("Accept")
+	ndjsonQ, jsonQ := codec.Quality(accept, NDJSONMediaType), codec.Quality(accept, "application/json")
+	if ndjsonQ == 0 && jsonQ == 0 {
+		err := errs.NewI18NError("no acceptable media type: %w", errs.ErrNotAcceptable, "notacceptable")
+		s.writeResponse(req, w, apitypes.Response{Status: http.StatusNotAcceptable, Content: errorContent(req, err)})
+		return
+	}
+	ndjson := ndjsonQ > jsonQ
+	writer := &itemsWriter{w: w, controller: http.NewResponseController(w), writeTimeout: s.timeouts.Write}
+
+	started := false
+	start := func() {
+		started = true
+		setCustomHeaders(w, res.Headers)
+		w.Header().Add("Vary", "Accept")
+		w.Header().Set("Content-Type", "application/json")
+		if ndjson {
+			w.Header().Set("Content-Type", NDJSONMediaType)
+		}
+		w.Header().Set("Trailer", StreamErrorTrailer)
+		status := res.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		writer.buf = bufio.NewWriter(w)
+		writer.lastFlush = time.Now()
+		if !ndjson {
+			writer.buf.WriteString("[")
+		}
+	}
+
+	var streamErr error
+	first := true
+	for item, err := range items {
+		if req.Context().Err() != nil {
+			return
+		}
+		if err != nil {
+			streamErr = err
+			break
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			streamErr = err
+			break
+		}
+		if !started {
+			start()
+		}
+		if !ndjson && !first {
+			writer.buf.WriteString(",")
+		}
+		first = false
+		writer.buf.Write(data)
+		if ndjson {
+			writer.buf.WriteString("\n")
+		}
+		if err := writer.flushIfDue(); err != nil {
+			if req.Context().Err() == nil {
+				s.logger.Error(req.Context(), "Failed to stream response: %v", err)
+			}
+			return
+		}
+	}
+
+	if streamErr != nil {
+		s.logger.Error(req.Context(), "Failed to stream collection: %v", streamErr)
+		if !started {
+			// The error is sent as JSON, which the client accepts along with NDJSON.
+			s.writeResponse(req, w, apitypes.Response{
+				Status:  http.StatusInternalServerError,
+				Content: errorContent(req, streamErr),
+				Headers: map[string]string{"Content-Type": "application/json"},
+			})
+			return
+		}
+		message := errorContent(req, streamErr)["error"]
+		if ndjson {
+			line, _ := json.Marshal(map[string]string{"error": message})
+			writer.buf.Write(append(line, '\n'))
+		}
+		w.Header().Set(StreamErrorTrailer, message)
+	} else {
+		if !started {
+			start()
+		}
+		if !ndjson {
+			writer.buf.WriteString("]\n")
+		}
+	}
+	if err := writer.flush(); err != nil && req.Context().Err() == nil {
+		s.logger.Error(req.Context(), "Failed to stream response: %v", err)
+	}
+}
+
+// itemsWriter buffers the items of a streamed collection and flushes them to the client periodically,
+// extending the write deadline on every flush so long collections aren't cut by the server's write timeout.
+type itemsWriter struct {
+	w            http.ResponseWriter
+	controller   *http.ResponseController
+	writeTimeout time.Duration
+	buf          *bufio.Writer
+	lastFlush    time.Time
+}
+
+// flushIfDue flushes the buffered items if itemsFlushInterval went by since the last flush.
+func (iw *itemsWriter) flushIfDue() error {
+	if time.Since(iw.lastFlush) < itemsFlushInterval {
+		return nil
+	}
+	return iw.flush()
+}
+
+// flush writes the buffered items and flushes them to the client.
+func (iw *itemsWriter) flush() error {
+	iw.lastFlush = time.Now()
+	if iw.writeTimeout > 0 {
+		// Writers which can't set deadlines, like test recorders, aren't bound by one.
+		_ = iw.controller.SetWriteDeadline(time.Now().Add(iw.writeTimeout))
+	}
+	if err := iw.buf.Flush(); err != nil {
+		return err
+	}
+	return iw.controller.Flush()
+}
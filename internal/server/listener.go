@@ -0,0 +1,150 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ListenerKind is the kind of a listener the server serves on.
+type ListenerKind string
+
+const (
+	ListenTCP  ListenerKind = "tcp"  // ListenTCP serves HTTP/1.1 and, with TLS, HTTP/2 over TCP.
+	ListenH2C  ListenerKind = "h2c"  // ListenH2C serves HTTP/1.1 and HTTP/2 cleartext over TCP. TLS never applies.
+	ListenUnix ListenerKind = "unix" // ListenUnix serves HTTP/1.1 over a Unix domain socket. TLS never applies.
+)
+
+// ListenerSpec describes a listener the server opens and serves its router on.
+type ListenerSpec struct {
+	Kind    ListenerKind // Kind is the kind of listener.
+	Address string       // Address is the host and port for TCP listeners or the socket path for Unix ones.
+	Mode    fs.FileMode  // Mode is the permission of the socket file of Unix listeners. The umask decides when zero.
+}
+
+// ParseListenerSpec parses a listener spec written as a URL, like "tcp://:8080", "h2c://127.0.0.1:8081"
+// or "unix:///run/service.sock?mode=0660".
+func ParseListenerSpec(spec string) (ListenerSpec, error) {
+	kind, address, ok := strings.Cut(spec, "://")
+	if !ok {
+		return ListenerSpec{}, fmt.Errorf("invalid listener %q: expected kind://address", spec)
+	}
+	parsed := ListenerSpec{Kind: ListenerKind(kind)}
+	address, rawQuery, _ := strings.Cut(address, "?")
+	parsed.Address = address
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return ListenerSpec{}, fmt.Errorf("invalid listener %q: %w", spec, err)
+	}
+	if mode := query.Get("mode"); mode != "" {
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return ListenerSpec{}, fmt.Errorf("invalid listener %q: invalid mode %q", spec, mode)
+		}
+		parsed.Mode = fs.FileMode(perm)
+	}
+	return parsed, parsed.validate()
+}
+
+// String returns the spec written as a URL, as accepted by ParseListenerSpec.
+func (l ListenerSpec) String() string {
+	if l.Mode != 0 {
+		return fmt.Sprintf("%s://%s?mode=%#o", l.Kind, l.Address, uint32(l.Mode))
+	}
+	return fmt.Sprintf("%s://%s", l.Kind, l.Address)
+}
+
+// validate checks the spec can be opened.
+func (l ListenerSpec) validate() error {
+	switch l.Kind {
+	case ListenTCP, ListenH2C, ListenUnix:
+	default:
+		return fmt.Errorf("invalid listener %s: unknown kind %q", l, l.Kind)
+	}
+	if l.Address == "" && l.Kind == ListenUnix {
+		return fmt.Errorf("invalid listener %s: missing socket path", l)
+	}
+	return nil
+}
+
+// listen opens the listener. A stale socket file left by a previous run is removed first, and the new one
+// is given the spec's mode. Closing the listener removes the socket file.
+func (l ListenerSpec) listen() (net.Listener, error) {
+	if err := l.validate(); err != nil {
+		return nil, err
+	}
+	if l.Kind != ListenUnix {
+		return net.Listen("tcp", l.Address)
+	}
+	if info, err := os.Lstat(l.Address); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("listening on %s: file exists and isn't a socket", l.Address)
+		}
+		if err := os.Remove(l.Address); err != nil {
+			return nil, fmt.Errorf("listening on %s: removing stale socket: %w", l.Address, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("listening on %s: %w", l.Address, err)
+	}
+	listener, err := net.Listen("unix", l.Address)
+	if err != nil {
+		return nil, err
+	}
+	if l.Mode != 0 {
+		if err := os.Chmod(l.Address, l.Mode); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("listening on %s: %w", l.Address, err)
+		}
+	}
+	return listener, nil
+}
+
+// servedListener is an open listener along with how it's served.
+type servedListener struct {
+	net.Listener
+	name  string      // name describes the listener in logs.
+	h2c   bool        // h2c reports whether HTTP/2 cleartext is served.
+	admin bool        // admin reports whether the admin server is served instead of the router.
+	tls   *tls.Config // tls is the TLS config of the listener, if TLS applies to it.
+}
+
+// listen opens the listeners of the server: the ones given, the ones described by the specs and,
+// if there are none, a TCP one on the listen address, plus the admin server's one if configured.
+// TLS applies to the TCP ones when enabled. If any listener can't be opened the ones already opened are closed.
+func (s *Server) listen(tlsConfig *tls.Config) ([]servedListener, error) {
+	specs := append([]ListenerSpec(nil), s.listenerSpecs...)
+	if len(specs) == 0 && len(s.listeners) == 0 {
+		specs = []ListenerSpec{{Kind: ListenTCP, Address: s.listenAddr}}
+	}
+	listeners := make([]servedListener, 0, len(s.listeners)+len(specs))
+	for _, listener := range s.listeners {
+		listeners = append(listeners, servedListener{Listener: listener, name: listener.Addr().String()})
+	}
+	if s.admin != nil {
+		specs = append(specs, s.admin.Listener)
+	}
+	for i, spec := range specs {
+		listener, err := spec.listen()
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, err
+		}
+		served := servedListener{Listener: listener, name: spec.String(), h2c: spec.Kind == ListenH2C}
+		if s.admin != nil && i == len(specs)-1 {
+			served.name, served.admin = "admin "+served.name, true
+		}
+		if spec.Kind == ListenTCP {
+			served.tls = tlsConfig
+		}
+		listeners = append(listeners, served)
+	}
+	return listeners, nil
+}
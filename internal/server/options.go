@@ -0,0 +1,216 @@
+package server
+
+import (
+	"net"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/cache"
+	"github.com/lucastomic/msBaseProj/internal/codec"
+	"github.com/lucastomic/msBaseProj/internal/concurrency"
+	"github.com/lucastomic/msBaseProj/internal/health"
+	"github.com/lucastomic/msBaseProj/internal/ratelimit"
+	"github.com/lucastomic/msBaseProj/internal/tlsconfig"
+)
+
+// DefaultMaxBodyBytes is the request body limit applied to routes which don't declare their own.
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// DefaultCacheEntries and DefaultCacheBytes bound the in-memory response cache used when no other
+// store is given.
+const (
+	DefaultCacheEntries = 10000
+	DefaultCacheBytes   = 64 << 20
+)
+
+// DefaultShedRetryAfter is the time clients shed by a concurrency limit are told to wait before retrying.
+const DefaultShedRetryAfter = time.Second
+
+// LivenessPath and ReadinessPath are the paths of the health probes served by every server.
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// Default server timeouts. The handler timeout applies to routes which don't declare their own.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultWriteTimeout      = 60 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultHandlerTimeout    = 30 * time.Second
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
+// Timeouts bounds the time spent on every stage of a connection. Zero values disable the matching timeout.
+type Timeouts struct {
+	ReadHeader time.Duration // ReadHeader is the time allowed to read the request headers.
+	Read       time.Duration // Read is the time allowed to read the whole request, body included.
+	Write      time.Duration // Write is the time allowed from the end of the request headers to the end of the response.
+	Idle       time.Duration // Idle is how long a keep-alive connection waits for the next request.
+	Handler    time.Duration // Handler bounds the handlers of the routes which don't declare their own timeout.
+	Drain      time.Duration // Drain is how long the server keeps serving with readiness failing before shutting down.
+	Shutdown   time.Duration // Shutdown is the time in-flight requests are given to finish when the server stops.
+}
+
+// DefaultTimeouts returns the timeouts used when no others are given.
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		ReadHeader: DefaultReadHeaderTimeout,
+		Read:       DefaultReadTimeout,
+		Write:      DefaultWriteTimeout,
+		Idle:       DefaultIdleTimeout,
+		Handler:    DefaultHandlerTimeout,
+		Shutdown:   DefaultShutdownTimeout,
+	}
+}
+
+// CORS are the CORS settings of the server, besides the allowed origins given to New.
+type CORS struct {
+	AllowedMethods   []string      // AllowedMethods are the methods cross-origin requests can use.
+	AllowedHeaders   []string      // AllowedHeaders are the headers cross-origin requests can send.
+	ExposedHeaders   []string      // ExposedHeaders are the response headers exposed to cross-origin clients.
+	AllowCredentials bool          // AllowCredentials lets cross-origin requests send credentials.
+	MaxAge           time.Duration // MaxAge is how long preflight responses can be cached. Zero leaves it to the browser.
+}
+
+// DefaultCORS returns the CORS settings used when no others are given.
+func DefaultCORS() CORS {
+	return CORS{
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Credentials"},
+		AllowCredentials: true,
+	}
+}
+
+// ETagMode defines how the server computes the entity tags of responses which don't provide their own.
+type ETagMode int
+
+const (
+	ETagStrong ETagMode = iota // ETagStrong computes strong tags, identifying the exact encoded bytes.
+	ETagWeak                   // ETagWeak computes weak tags, for representations which are only semantically equal.
+	ETagNone                   // ETagNone doesn't compute tags. Tags provided by handlers are still sent.
+)
+
+// Option configures optional settings of a Server. Options are applied by New in the given order.
+type Option func(*Server)
+
+// WithMaxBodyBytes sets the request body limit in bytes for routes which don't declare their own.
+// A negative value disables the limit.
+func WithMaxBodyBytes(maxBytes int64) Option {
+	return func(s *Server) {
+		s.maxBodyBytes = maxBytes
+	}
+}
+
+// WithContentTypes sets the request body media types accepted by routes which don't declare their own.
+// By default, the media types of the registered codecs are accepted.
+func WithContentTypes(contentTypes ...string) Option {
+	return func(s *Server) {
+		s.contentTypes = contentTypes
+	}
+}
+
+// WithCodec registers an additional codec for content negotiation and request decoding,
+// replacing the built-in codec with the same canonical media type if there is one.
+func WithCodec(c codec.Codec) Option {
+	return func(s *Server) {
+		s.codecs.Register(c)
+	}
+}
+
+// WithETagMode sets how the server computes the entity tags of responses which don't provide their own.
+// Tags are computed as strong ones by default.
+func WithETagMode(mode ETagMode) Option {
+	return func(s *Server) {
+		s.etagMode = mode
+	}
+}
+
+// WithCacheStore sets the store where the responses of the routes with a cache policy are kept,
+// replacing the default in-memory one. Handlers invalidate it through cache.Invalidate.
+func WithCacheStore(store cache.Store) Option {
+	return func(s *Server) {
+		s.cacheStore = store
+	}
+}
+
+// WithRateLimit sets a rate limit applied to every route on top of their own ones.
+// Routes can opt out by declaring a negative limit. It can be changed later with Server.Reload.
+func WithRateLimit(rule ratelimit.Rule) Option {
+	return func(s *Server) {
+		s.rateLimit = rule
+	}
+}
+
+// WithRateLimitStore sets the store keeping the state of the rate limited clients, replacing the default
+// in-memory one. A shared store makes limits apply across every replica of the service.
+func WithRateLimitStore(store ratelimit.Store) Option {
+	return func(s *Server) {
+		s.rateLimitStore = store
+	}
+}
+
+// WithConcurrencyLimit bounds the number of requests the server handles at once. Requests over the limit
+// wait in a queue ordered by their route's priority and are shed with a 503 when it's full or they time out.
+func WithConcurrencyLimit(config concurrency.Config) Option {
+	return func(s *Server) {
+		if config.Enabled() {
+			s.concurrency = concurrency.NewLimiter(config)
+		}
+	}
+}
+
+// WithTimeouts sets the connection timeouts of the server and the default handler timeout,
+// replacing DefaultTimeouts.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(s *Server) {
+		s.timeouts = timeouts
+	}
+}
+
+// WithTLS makes the server terminate TLS with the given config. Its files are reloaded when they change,
+// so certificates can be rotated without a restart. When it enables mutual TLS, the server's authMiddleware
+// can be a middleware.NewMTLSAuthMiddleware to authenticate clients by their certificate.
+func WithTLS(config tlsconfig.Config) Option {
+	return func(s *Server) {
+		s.tls = config
+	}
+}
+
+// WithListeners makes the server serve on the given listeners, e.g. ones inherited from a supervisor.
+// The server takes ownership of them and closes them when it stops. TLS doesn't apply to them.
+func WithListeners(listeners ...net.Listener) Option {
+	return func(s *Server) {
+		s.listeners = append(s.listeners, listeners...)
+	}
+}
+
+// WithListenerSpecs makes the server open and serve on the described listeners instead of its listen address.
+func WithListenerSpecs(specs ...ListenerSpec) Option {
+	return func(s *Server) {
+		s.listenerSpecs = append(s.listenerSpecs, specs...)
+	}
+}
+
+// WithHealthChecker sets the registry of the checks deciding whether the server is ready, replacing
+// the empty one the server starts with. It lets services register their checks before building the server.
+func WithHealthChecker(checker *health.HealthChecker) Option {
+	return func(s *Server) {
+		s.health = checker
+	}
+}
+
+// WithAdmin makes the server run the admin server, exposing profiling and runtime information on a separate
+// listener. See AdminConfig.
+func WithAdmin(config AdminConfig) Option {
+	return func(s *Server) {
+		s.admin = &config
+	}
+}
+
+// WithCORS sets the CORS settings of the server, replacing DefaultCORS. They can be changed later with Server.Reload.
+func WithCORS(settings CORS) Option {
+	return func(s *Server) {
+		s.cors = settings
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/rs/cors"
+
+	"github.com/lucastomic/msBaseProj/internal/ratelimit"
+)
+
+// Reloadable are the settings of the server which can be changed while it runs, without a restart.
+type Reloadable struct {
+	AllowOrigins []string       // AllowOrigins are the origins allowed to make cross-origin requests.
+	CORS         CORS           // CORS are the CORS settings applied along with AllowOrigins.
+	RateLimit    ratelimit.Rule // RateLimit is the server-wide rate limit. Zero disables it.
+	// Config is the config in effect, served by the admin server instead of the one returned by
+	// AdminConfig.Config when set, so it shows the reloaded one.
+	Config any
+}
+
+// liveSettings is a snapshot of the reloadable settings in effect. It's never modified once stored,
+// so requests see either the old settings or the new ones as a whole.
+type liveSettings struct {
+	Reloadable
+	cors *cors.Cors // cors applies AllowOrigins and CORS to the requests.
+}
+
+// newLiveSettings returns the snapshot of the given settings.
+func newLiveSettings(settings Reloadable) *liveSettings {
+	settings.AllowOrigins = slices.Clone(settings.AllowOrigins)
+	return &liveSettings{
+		Reloadable: settings,
+		cors: cors.New(cors.Options{
+			AllowedOrigins:   settings.AllowOrigins,
+			AllowCredentials: settings.CORS.AllowCredentials,
+			AllowedMethods:   settings.CORS.AllowedMethods,
+			AllowedHeaders:   settings.CORS.AllowedHeaders,
+			ExposedHeaders:   settings.CORS.ExposedHeaders,
+			MaxAge:           int(settings.CORS.MaxAge.Seconds()),
+		}),
+	}
+}
+
+// Reload atomically replaces the reloadable settings of the server. Requests in flight finish with
+// the settings they started with, and the following ones get the new settings.
+// It's safe to call while the server is serving.
+func (s *Server) Reload(settings Reloadable) {
+	s.live.Store(newLiveSettings(settings))
+}
+
+// Reloadable returns the reloadable settings in effect.
+func (s *Server) Reloadable() Reloadable {
+	settings := s.live.Load().Reloadable
+	settings.AllowOrigins = slices.Clone(settings.AllowOrigins)
+	return settings
+}
+
+// corsHandler wraps next with the CORS settings in effect when each request arrives.
+func (s *Server) corsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.live.Load().cors.ServeHTTP(w, r, next.ServeHTTP)
+	})
+}
+
+// globalRateLimit returns the server-wide rate limit in effect.
+func (s *Server) globalRateLimit() ratelimit.Rule {
+	return s.live.Load().RateLimit
+}
@@ -1,19 +1,36 @@
 package server
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
-	"github.com/rs/cors"
-
+	"github.com/lucastomic/msBaseProj/internal/cache"
+	"github.com/lucastomic/msBaseProj/internal/codec"
+	"github.com/lucastomic/msBaseProj/internal/concurrency"
 	"github.com/lucastomic/msBaseProj/internal/controller"
 	"github.com/lucastomic/msBaseProj/internal/controller/apitypes"
 	"github.com/lucastomic/msBaseProj/internal/errs"
+	"github.com/lucastomic/msBaseProj/internal/etag"
+	"github.com/lucastomic/msBaseProj/internal/fields"
+	"github.com/lucastomic/msBaseProj/internal/health"
 	"github.com/lucastomic/msBaseProj/internal/logging"
 	"github.com/lucastomic/msBaseProj/internal/middleware"
+	"github.com/lucastomic/msBaseProj/internal/ratelimit"
+	"github.com/lucastomic/msBaseProj/internal/tlsconfig"
 	"github.com/lucastomic/msBaseProj/internal/translator"
 )
 
@@ -26,10 +43,33 @@ type Server struct {
 	middlewares    []middleware.Middleware // middlewares is a slice of Middleware interfaces to be applied to all requests.
 	authMiddleware middleware.Middleware   // authMiddleware is the middleware for those routes who requires authentication
 	allowOrigins   []string                // allowOrigins is a list of origins that are allowed to make requests to the server
+	maxBodyBytes   int64                   // maxBodyBytes is the default request body limit for routes without their own
+	contentTypes   []string                // contentTypes are the default accepted request media types for routes without their own
+	codecs         *codec.Registry         // codecs are the encoders negotiated for responses and decoders for request bodies
+	etagMode       ETagMode                // etagMode defines how entity tags are computed for responses without their own
+	cacheStore     cache.Store             // cacheStore keeps the responses of the routes with a cache policy
+	rateLimit      ratelimit.Rule          // rateLimit is the server-wide rate limit, applied to every route
+	rateLimitStore ratelimit.Store         // rateLimitStore keeps the state of the rate limited clients
+	concurrency    *concurrency.Limiter    // concurrency bounds the requests in flight across the server, if set
+	timeouts       Timeouts                // timeouts bound the connections and the handlers of the routes without their own
+	tls            tlsconfig.Config        // tls is the TLS config of the server. Plain HTTP is served when it isn't enabled
+	listeners      []net.Listener          // listeners are the listeners given to the server, served along with listenerSpecs
+	listenerSpecs  []ListenerSpec          // listenerSpecs describe the listeners the server opens. listenAddr is used when there are none
+	health         *health.HealthChecker   // health holds the checks deciding whether the server is ready
+	admin          *AdminConfig            // admin configures the admin server, which only runs if set
+	cors           CORS                    // cors are the CORS settings applied along with allowOrigins
+	// live holds the reloadable settings in effect, starting with allowOrigins, cors and rateLimit.
+	// It's a pointer so it's shared by the copies of the Server, which is passed around by value.
+	live *atomic.Pointer[liveSettings]
+	// statics are the file systems mounted outside /api.
+	statics []StaticConfig
+	// websockets are the open WebSocket connections, closed when the server shuts down.
+	websockets *websocketConns
 }
 
 // New creates a new instance of the Server struct, initializing it with the provided parameters
 // such as listen address, controller, API and logic loggers, and middlewares.
+// Optional settings are given as Options and fall back to their defaults when omitted.
 func New(
 	listenAddr string,
 	controller []controller.Controller,
@@ -37,92 +77,445 @@ func New(
 	middlewares []middleware.Middleware,
 	authMiddleware middleware.Middleware,
 	allowOrigins []string,
+	opts ...Option,
 ) Server {
-	return Server{
-		listenAddr,
-		controller,
-		logger,
-		middlewares,
-		authMiddleware,
-		allowOrigins,
+	s := Server{
+		listenAddr:     listenAddr,
+		controller:     controller,
+		logger:         logger,
+		middlewares:    middlewares,
+		authMiddleware: authMiddleware,
+		allowOrigins:   allowOrigins,
+		maxBodyBytes:   DefaultMaxBodyBytes,
+		codecs:         codec.NewDefaultRegistry(),
+		cacheStore:     cache.NewMemoryStore(DefaultCacheEntries, DefaultCacheBytes),
+		rateLimitStore: ratelimit.NewMemoryStore(),
+		timeouts:       DefaultTimeouts(),
+		health:         health.NewHealthChecker(),
+		cors:           DefaultCORS(),
+		websockets:     newWebSocketConns(),
+	}
+	for _, opt := range opts {
+		opt(&s)
 	}
+	s.live = &atomic.Pointer[liveSettings]{}
+	s.Reload(Reloadable{AllowOrigins: s.allowOrigins, CORS: s.cors, RateLimit: s.rateLimit})
+	return s
 }
 
 // Run initializes the server's routes based on the controller's router, applies middlewares,
 // starts listening on the specified address, and logs the server's start or any errors encountered.
+// It serves until the process receives SIGINT or SIGTERM, then shuts down gracefully.
 func (s *Server) Run() {
-	r := http.NewServeMux()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := s.Serve(ctx); err != nil {
+		s.logger.Error(context.Background(), "Failed to start server: %v", err)
+	}
+}
+
+// Serve serves the router on every listener of the server, and the admin server if configured, until ctx
+// is canceled or one of them fails.
+// Connections are bound by the server's timeouts and, on TCP listeners with TLS enabled, served over TLS.
+// When it stops, readiness starts failing and the server keeps serving for the drain timeout, so orchestrators
+// stop routing traffic to it. Then in-flight requests are given the shutdown timeout to finish and the
+// listeners are closed, which removes the socket files of Unix ones. It returns the error of the listener
+// which failed, if any.
+func (s *Server) Serve(ctx context.Context) error {
+	var tlsConfig *tls.Config
+	if s.tls.Enabled() {
+		reloader, err := tlsconfig.NewReloader(s.tls, func(err error) {
+			s.logger.Error(context.Background(), "Failed to reload TLS certificates: %v", err)
+		})
+		if err != nil {
+			return err
+		}
+		tlsConfig = reloader.TLSConfig()
+	}
+	handler := s.handler()
+	var adminHandler http.Handler
+	if s.admin != nil {
+		var err error
+		if adminHandler, err = s.adminHandler(); err != nil {
+			return err
+		}
+	}
+	listeners, err := s.listen(tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	httpServers := make([]*http.Server, 0, len(listeners))
+	serveErrs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		httpServer := s.httpServer(handler, listener)
+		if listener.admin {
+			// Profiles and traces stream for as long as they're asked to, so they can't be bound by the write timeout.
+			httpServer.Handler, httpServer.WriteTimeout = adminHandler, 0
+		}
+		httpServers = append(httpServers, httpServer)
+		go func() {
+			if listener.tls != nil {
+				serveErrs <- httpServer.ServeTLS(listener, "", "")
+			} else {
+				serveErrs <- httpServer.Serve(listener)
+			}
+		}()
+		s.logger.Info(context.Background(), "Service running in %s", listener.name)
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-serveErrs:
+	}
+	s.logger.Info(context.Background(), "Shutting down service")
+	s.health.SetShuttingDown()
+	if serveErr == nil && s.timeouts.Drain > 0 {
+		time.Sleep(s.timeouts.Drain)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.timeouts.Shutdown)
+	defer cancel()
+	for _, httpServer := range httpServers {
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			s.logger.Error(context.Background(), "Failed to shut down gracefully: %v", err)
+			httpServer.Close()
+		}
+	}
+	return serveErr
+}
+
+// httpServer builds the http.Server serving handler on the given listener, bound by the server's timeouts.
+func (s *Server) httpServer(handler http.Handler, listener servedListener) *http.Server {
+	httpServer := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: s.timeouts.ReadHeader,
+		ReadTimeout:       s.timeouts.Read,
+		WriteTimeout:      s.timeouts.Write,
+		IdleTimeout:       s.timeouts.Idle,
+		TLSConfig:         listener.tls,
+	}
+	// Connections taken over by WebSocket handlers aren't closed by Shutdown, so they're closed along.
+	httpServer.RegisterOnShutdown(s.websockets.closeAll)
+	if listener.h2c {
+		protocols := &http.Protocols{}
+		protocols.SetHTTP1(true)
+		protocols.SetUnencryptedHTTP2(true)
+		httpServer.Protocols = protocols
+	}
+	return httpServer
+}
+
+// HealthChecker returns the registry where services add the checks deciding whether the server is ready.
+func (s *Server) HealthChecker() *health.HealthChecker {
+	return s.health
+}
+
+// handler builds the http.Handler serving every controller's routes under /api,
+// each one wrapped with its middlewares, and the whole router wrapped with the CORS settings in effect.
+// The health probes are served at LivenessPath and ReadinessPath without middlewares, as orchestrators
+// must be able to reach them without authenticating or being rate limited.
+// Requests under /api are routed by their own mux, so static files mounted on / never turn the 404 of
+// an unknown API path, or the 405 of a known one requested with another method, into something else.
+func (s *Server) handler() http.Handler {
+	api := http.NewServeMux()
 	for _, controller := range s.controller {
 		for _, route := range controller.Router() {
-			middlewares := s.middlewares
-			if route.RequireAuth {
-				middlewares = append(middlewares, s.authMiddleware)
+			handler := s.makeHTTPHandlerFunc(route.Handler)
+			if route.WebSocket != nil {
+				handler = s.makeWebSocketHandlerFunc(*route.WebSocket)
 			}
 			handlerWithMiddlewares := middleware.ChainMiddleware(
-				s.makeHTTPHandlerFunc(route.Handler),
+				handler,
 				s.handleError,
-				middlewares...,
+				s.routeMiddlewares(route)...,
 			)
-			r.Handle(fmt.Sprintf("%s /api%s", route.Method, route.Path), handlerWithMiddlewares)
+			api.Handle(fmt.Sprintf("%s /api%s", route.Method, route.Path), handlerWithMiddlewares)
 		}
 	}
+	r := http.NewServeMux()
+	r.Handle("GET "+LivenessPath, s.health.LivenessHandler())
+	r.Handle("GET "+ReadinessPath, s.health.ReadinessHandler())
+	for _, static := range s.statics {
+		r.Handle(staticPattern(static), s.staticHandler(static))
+	}
+	return s.corsHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api" || strings.HasPrefix(req.URL.Path, "/api/") {
+			api.ServeHTTP(w, req)
+			return
+		}
+		r.ServeHTTP(w, req)
+	}))
+}
 
-	c := cors.New(cors.Options{
-		AllowedOrigins:   s.allowOrigins,
-		AllowCredentials: true,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type", "Credentials"},
-	})
-	handler := c.Handler(r)
-
-	s.logger.Info(context.Background(), "Service running in %s", s.listenAddr)
-	if err := http.ListenAndServe(s.listenAddr, handler); err != nil {
-		s.logger.Error(context.Background(), "Failed to start server: %v", err)
+// routeMiddlewares returns the middlewares applied to the given route: the server-wide ones first,
+// then the server-wide concurrency limit, the authentication middleware if the route requires it,
+// the rate limits, the route's concurrency limit, the body limits, the fields selection, the response
+// cache if the route declares a cache policy and finally the handler timeout.
+// The server-wide concurrency limit goes before authentication so requests are shed as cheaply as possible,
+// and rate limits go after it so clients can be told apart by their principal. The timeout goes last so
+// it bounds the handler alone, including when the cache revalidates a stale response in the background.
+// WebSocket routes stop after the rate limits, as the rest doesn't apply to long-lived connections.
+func (s *Server) routeMiddlewares(route apitypes.Route) []middleware.Middleware {
+	middlewares := append([]middleware.Middleware{}, s.middlewares...)
+	if s.concurrency != nil && route.WebSocket == nil {
+		middlewares = append(middlewares, middleware.NewConcurrencyMiddleware(s.concurrency, route.Priority, DefaultShedRetryAfter))
+	}
+	if route.RequireAuth {
+		middlewares = append(middlewares, s.authMiddleware)
+	}
+	if route.RateLimit.Limit >= 0 {
+		middlewares = append(middlewares, middleware.NewReloadableRateLimitMiddleware(s.rateLimitStore, s.globalRateLimit, "global"))
+		if route.RateLimit.Enabled() {
+			scope := fmt.Sprintf("%s /api%s", route.Method, route.Path)
+			middlewares = append(middlewares, middleware.NewRateLimitMiddleware(s.rateLimitStore, route.RateLimit, scope))
+		}
+	}
+	if route.WebSocket != nil {
+		return middlewares
 	}
+	if route.Concurrency.Enabled() {
+		limiter := concurrency.NewLimiter(route.Concurrency)
+		middlewares = append(middlewares, middleware.NewConcurrencyMiddleware(limiter, route.Priority, DefaultShedRetryAfter))
+	}
+	maxBodyBytes := route.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = s.maxBodyBytes
+	}
+	contentTypes := route.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = s.contentTypes
+	}
+	if len(contentTypes) == 0 {
+		contentTypes = s.codecs.MediaTypes()
+	}
+	middlewares = append(middlewares, middleware.NewBodyLimitMiddleware(maxBodyBytes, contentTypes...))
+	middlewares = append(middlewares, middleware.NewFieldsMiddleware(route.Fields, route.Expand))
+	if route.Cache.TTL > 0 {
+		// The selection changes the response, so it must be part of the cache key.
+		policy := route.Cache
+		policy.QueryParams = slices.Clone(policy.QueryParams)
+		for _, param := range []string{fields.FieldsParam, fields.ExpandParam} {
+			if !slices.Contains(policy.QueryParams, param) {
+				policy.QueryParams = append(policy.QueryParams, param)
+			}
+		}
+		middlewares = append(middlewares, middleware.NewCacheMiddleware(s.cacheStore, policy))
+	}
+	timeout := route.Timeout
+	if timeout == 0 {
+		timeout = s.timeouts.Handler
+	}
+	if timeout > 0 {
+		middlewares = append(middlewares, middleware.NewTimeoutMiddleware(timeout))
+	}
+	return middlewares
 }
 
 // makeHTTPHandlerFunc wraps the API function into an http.HandlerFunc, facilitating the handling
 // of HTTP requests and responses within the server's routing mechanism.
 func (s *Server) makeHTTPHandlerFunc(apiFn apitypes.APIFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := codec.WithRegistry(r.Context(), s.codecs)
+		ctx = cache.WithStore(ctx, s.cacheStore)
+		*r = *r.WithContext(ctx)
 		res := apiFn(w, r)
 		s.writeResponse(r, w, res)
 	}
 }
 
-// handleError handles errors by writing an error message as a JSON response, utilizing the
-// writeJSON method to ensure the response format is consistent.
+// handleError handles errors by writing an error message as a response, utilizing the
+// writeResponse method to ensure the response format is consistent.
 func (s *Server) handleError(
 	req *http.Request,
 	w http.ResponseWriter,
 	err error,
 	statusCode int,
 ) {
-	i18n := &errs.I18nError{}
-	var message string
-	if errors.As(err, i18n) {
-		message = translator.TranslateGivenCtx(req.Context(), i18n.Code)
-	} else {
-		message = err.Error()
-	}
-	errMsg := map[string]string{"error": message}
-	s.writeResponse(req, w, apitypes.Response{Status: statusCode, Content: errMsg})
+	s.writeResponse(req, w, apitypes.Response{Status: statusCode, Content: errorContent(req, err)})
 }
 
 // writeResponse prepares and sends an HTTP response based on the provided apitypes.Response struct.
-// It sets custom headers, writes the status code, and sends the response content, which can vary in type.
+// The content is encoded with the codec negotiated from the request's Accept header, unless the response
+// sets a Content-Type of its own which a codec is registered for. When no codec is acceptable, successful responses
+// are replaced with a 406 and error responses keep their status, both encoded with the default codec.
+// It sets custom headers, writes the status code, and sends the encoded content. Streamed responses
+// are handed to writeStream instead, collections of apitypes.Items to writeItems and apitypes.File to writeFile,
+// all of them with the handler timeout lifted.
 func (s *Server) writeResponse(req *http.Request, w http.ResponseWriter, res apitypes.Response) {
+	_, isItems := res.Content.(apitypes.Items)
+	file, isFile := res.Content.(apitypes.File)
+	if res.Stream != nil || isItems || isFile {
+		// Streamed bodies take as long as the client needs, so only the handler is bounded by its timeout.
+		var ok bool
+		if req, ok = middleware.DetachTimeout(req); !ok {
+			if closer, ok := file.Content.(io.Closer); ok {
+				closer.Close()
+			}
+			return
+		}
+	}
+	if res.Stream != nil {
+		s.writeStream(req, w, res)
+		return
+	}
+	switch content := res.Content.(type) {
+	case apitypes.Items:
+		s.writeItems(req, w, res, content)
+		return
+	case apitypes.File:
+		s.writeFile(req, w, res, content)
+		return
+	}
+	registry := s.registry(req)
+	c, contentType, ok := responseCodec(registry, req, res)
+	if !ok {
+		// Only successful responses are replaced with a 406, errors keep their status.
+		if res.Status >= 200 && res.Status < 300 {
+			err := errs.NewI18NError("no acceptable media type: %w", errs.ErrNotAcceptable, "notacceptable")
+			res = apitypes.Response{Status: http.StatusNotAcceptable, Content: errorContent(req, err)}
+		}
+		c = registry.Default()
+		contentType = c.MediaTypes()[0]
+	}
+	content, err := projectContent(req, contentType, res)
+	var body []byte
+	if err == nil {
+		body, err = encodeContent(c, content)
+	}
+	if err != nil {
+		s.logger.Error(req.Context(), "Failed to encode response: %v", err)
+		res = apitypes.Response{Status: http.StatusInternalServerError}
+		c = registry.Default()
+		contentType = c.MediaTypes()[0]
+		body, _ = encodeContent(c, map[string]string{"error": translator.TranslateGivenCtx(req.Context(), "internalerror")})
+	}
+
 	setCustomHeaders(w, res.Headers)
+	w.Header().Add("Vary", "Accept")
+	// The body is always the one of the selected codec, so its media type replaces any other Content-Type.
+	w.Header().Set("Content-Type", contentType)
+	if s.setValidators(req, w, res, body) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	w.WriteHeader(res.Status)
-	if err := writeContent(w, res.Content); err != nil {
+	if !bodyAllowed(res.Status) {
+		return
+	}
+	if _, err := w.Write(body); err != nil {
 		s.logger.Error(req.Context(), "Failed to write response: %v", err)
 	}
 }
 
-// writeContent writes the content as a JSON to the response writer.
-// Returns an error if it encounters an issue during the write operation.
-func writeContent(w http.ResponseWriter, content interface{}) error {
-	return json.NewEncoder(w).Encode(content)
+// writeStream sends the headers and status code of a streamed response and lets its StreamFunc write
+// the body. Streams aren't given validators, as their body isn't known upfront.
+func (s *Server) writeStream(req *http.Request, w http.ResponseWriter, res apitypes.Response) {
+	setCustomHeaders(w, res.Headers)
+	status := res.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	if err := res.Stream(w, req); err != nil && req.Context().Err() == nil {
+		s.logger.Error(req.Context(), "Failed to stream response: %v", err)
+	}
+}
+
+// setValidators sets the ETag and Last-Modified headers of the response and reports whether the
+// conditional headers of a GET or HEAD request make it not modified for the client.
+// The ETag is the one provided by the response or, for successful GET and HEAD responses, one computed
+// from the encoded body. If-None-Match takes precedence over If-Modified-Since, as RFC 9110 mandates.
+func (s *Server) setValidators(req *http.Request, w http.ResponseWriter, res apitypes.Response, body []byte) bool {
+	safe := req.Method == http.MethodGet || req.Method == http.MethodHead
+	tag := ""
+	if res.ETag != "" {
+		tag = etag.Normalize(res.ETag)
+	} else if s.etagMode != ETagNone && safe && res.Status == http.StatusOK {
+		tag = etag.Compute(body, s.etagMode == ETagWeak)
+	}
+	if tag != "" {
+		w.Header().Set("ETag", tag)
+	}
+	if !res.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", res.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if !safe || res.Status != http.StatusOK {
+		return false
+	}
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etag.MatchWeak(ifNoneMatch, tag)
+	}
+	if ifModifiedSince := req.Header.Get("If-Modified-Since"); ifModifiedSince != "" && !res.LastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		return err == nil && !res.LastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// bodyAllowed reports whether a response with the given status code can have a body.
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// registry returns the codecs of the server, or the ones carried by the request if the server has none.
+func (s *Server) registry(req *http.Request) *codec.Registry {
+	if s.codecs != nil {
+		return s.codecs
+	}
+	return codec.RegistryFromCtx(req.Context())
+}
+
+// responseCodec selects the codec used to encode res and the Content-Type announced for it.
+// A Content-Type set explicitly in the response headers takes precedence over the Accept header
+// as long as a codec is registered for it. Otherwise it's ignored and replaced with the negotiated one.
+func responseCodec(registry *codec.Registry, req *http.Request, res apitypes.Response) (codec.Codec, string, bool) {
+	if contentType, ok := res.Headers["Content-Type"]; ok {
+		if c, ok := registry.ForContentType(contentType); ok {
+			return c, contentType, true
+		}
+	}
+	return registry.Negotiate(req.Header.Get("Accept"), res.Content)
+}
+
+// projectContent returns the content of a response reduced to the fields selected by the client, as read
+// by the fields middleware. Only successful JSON responses are projected: the projection is the generic
+// value of the content's JSON encoding, which other formats wouldn't represent faithfully, e.g. CSV needs
+// typed rows and the numbers would lose their type in YAML or MessagePack. Those are sent whole.
+func projectContent(req *http.Request, contentType string, res apitypes.Response) (any, error) {
+	sel := fields.FromCtx(req.Context())
+	if len(sel.Fields) == 0 || res.Status < 200 || res.Status >= 300 {
+		return res.Content, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !(mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		return res.Content, nil
+	}
+	return fields.Project(res.Content, sel)
+}
+
+// errorContent builds the content of an error response, translating the error message
+// if err is an errs.I18nError.
+func errorContent(req *http.Request, err error) map[string]string {
+	i18n := &errs.I18nError{}
+	var message string
+	if errors.As(err, i18n) {
+		message = i18n.Translate(req.Context())
+	} else {
+		message = err.Error()
+	}
+	return map[string]string{"error": message}
+}
+
+// encodeContent encodes the content with the given codec.
+// Returns an error if it encounters an issue during the encoding.
+func encodeContent(c codec.Codec, content any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, content); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // setCustomHeaders sets the headers provided in the response struct.
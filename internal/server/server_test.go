@@ -1,11 +1,37 @@
 package server
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/lucastomic/msBaseProj/internal/contextypes"
+	"github.com/lucastomic/msBaseProj/internal/controller"
 	"github.com/lucastomic/msBaseProj/internal/controller/apitypes"
+	"github.com/lucastomic/msBaseProj/internal/errs"
+	"github.com/lucastomic/msBaseProj/internal/fields"
+	"github.com/lucastomic/msBaseProj/internal/health"
+	"github.com/lucastomic/msBaseProj/internal/logging"
+	"github.com/lucastomic/msBaseProj/internal/middleware"
+	"github.com/lucastomic/msBaseProj/internal/ratelimit"
+	"github.com/lucastomic/msBaseProj/internal/sse"
+	"github.com/lucastomic/msBaseProj/internal/websocket"
 )
 
 // TestWriteResponse checks if the writeResponse correctly sets headers and writes the response.
@@ -48,3 +74,835 @@ func TestSetCustomHeaders(t *testing.T) {
 		t.Errorf("Expected X-Custom-Header to be set to value")
 	}
 }
+
+// testController is a controller.Controller serving a fixed router.
+type testController apitypes.Router
+
+func (c testController) Router() apitypes.Router {
+	return apitypes.Router(c)
+}
+
+// newTestServer builds a Server serving the given routes with the language middleware applied.
+func newTestServer(routes apitypes.Router, opts ...Option) Server {
+	return New(
+		":0",
+		[]controller.Controller{testController(routes)},
+		logging.NewLogrusLogger(),
+		[]middleware.Middleware{middleware.NewLangMiddleware()},
+		nil,
+		nil,
+		opts...,
+	)
+}
+
+// TestRouteBodyLimit checks the server default body limit applies to every route unless the route
+// declares its own.
+func TestRouteBodyLimit(t *testing.T) {
+	echo := func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+		return apitypes.Response{Status: http.StatusOK}
+	}
+	srv := newTestServer(apitypes.Router{
+		{Path: "/small", Method: http.MethodPost, Handler: echo},
+		{Path: "/big", Method: http.MethodPost, Handler: echo, MaxBodyBytes: 64},
+	}, WithMaxBodyBytes(8))
+	handler := srv.handler()
+
+	for path, expected := range map[string]int{
+		"/api/small": http.StatusRequestEntityTooLarge,
+		"/api/big":   http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"name":"boat"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != expected {
+			t.Errorf("Expected status code %v for %s, got %v", expected, path, w.Code)
+		}
+	}
+}
+
+// TestRouteTimeout checks the server default handler timeout applies to every route unless the route
+// declares its own, and that timed out requests get a 504.
+func TestRouteTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+		<-release
+		return apitypes.Response{Status: http.StatusOK}
+	}
+	sleepy := func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+		time.Sleep(30 * time.Millisecond)
+		return apitypes.Response{Status: http.StatusOK}
+	}
+	srv := newTestServer(apitypes.Router{
+		{Path: "/slow", Method: http.MethodGet, Handler: slow},
+		{Path: "/unbounded", Method: http.MethodGet, Handler: sleepy, Timeout: -1},
+	}, WithTimeouts(Timeouts{Handler: 10 * time.Millisecond}))
+	handler := srv.handler()
+
+	for path, expected := range map[string]int{
+		"/api/slow":      http.StatusGatewayTimeout,
+		"/api/unbounded": http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != expected {
+			t.Errorf("Expected status code %v for %s, got %v", expected, path, w.Code)
+		}
+	}
+}
+
+// TestWriteResponseNegotiation checks the response is encoded with the codec negotiated from the Accept
+// header, that a 406 is sent when none is acceptable for a successful response while errors keep their status,
+// and that a Content-Type no codec handles doesn't end up describing another codec's body.
+func TestWriteResponseNegotiation(t *testing.T) {
+	srv := newTestServer(nil)
+	response := apitypes.Response{Status: http.StatusOK, Content: map[string]string{"message": "success"}}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req.Header.Set("Accept", "application/yaml")
+	w := httptest.NewRecorder()
+	srv.writeResponse(req, w, response)
+	if w.Header().Get("Content-Type") != "application/yaml" {
+		t.Errorf("Expected Content-Type application/yaml, got %v", w.Header().Get("Content-Type"))
+	}
+	if w.Body.String() != "message: success\n" {
+		t.Errorf("Unexpected YAML body %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req = req.WithContext(context.WithValue(req.Context(), contextypes.ContextLangKey{}, "en"))
+	req.Header.Set("Accept", "image/png")
+	w = httptest.NewRecorder()
+	srv.writeResponse(req, w, response)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected status code %v, got %v", http.StatusNotAcceptable, w.Code)
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the 406 to be sent as JSON, got %v", w.Header().Get("Content-Type"))
+	}
+
+	req.Header.Set("Accept", "image/png")
+	w = httptest.NewRecorder()
+	srv.writeResponse(req, w, apitypes.Response{Status: http.StatusNotFound, Content: map[string]string{"error": "not found"}})
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the error to keep its status and be sent as JSON, got %v %v", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	req.Header.Set("Accept", "application/yaml")
+	w = httptest.NewRecorder()
+	srv.writeResponse(req, w, apitypes.Response{Status: http.StatusOK, Content: response.Content, Headers: map[string]string{"Content-Type": "text/html"}})
+	if w.Header().Get("Content-Type") != "application/yaml" || w.Body.String() != "message: success\n" {
+		t.Errorf("Expected a Content-Type without codec to be replaced, got %v %q", w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+// TestWriteResponseConditional checks a GET whose If-None-Match matches the ETag computed from the
+// body, or whose If-Modified-Since isn't older than the Last-Modified time, gets a 304 without body.
+func TestWriteResponseConditional(t *testing.T) {
+	srv := newTestServer(nil)
+	lastModified := time.Date(2024, 2, 3, 20, 17, 12, 0, time.UTC)
+	response := apitypes.Response{Status: http.StatusOK, Content: "boat", LastModified: lastModified}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	w := httptest.NewRecorder()
+	srv.writeResponse(req, w, response)
+	tag := w.Header().Get("ETag")
+	if tag == "" {
+		t.Fatalf("Expected an ETag to be computed")
+	}
+
+	for header, value := range map[string]string{
+		"If-None-Match":     tag,
+		"If-Modified-Since": lastModified.Format(http.TimeFormat),
+	} {
+		req = httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+		req.Header.Set(header, value)
+		w = httptest.NewRecorder()
+		srv.writeResponse(req, w, response)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("Expected status code %v for %s, got %v", http.StatusNotModified, header, w.Code)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected no body for %s, got %q", header, w.Body.String())
+		}
+	}
+}
+
+// TestServeListeners checks the router is served on h2c, Unix and given listeners alike, and that
+// the socket file gets its mode and is removed on shutdown.
+func TestServeListeners(t *testing.T) {
+	proto := func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+		return apitypes.Response{Status: http.StatusOK, Content: map[string]string{"proto": r.Proto}}
+	}
+	h2cListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2cAddress := h2cListener.Addr().String()
+	h2cListener.Close()
+	given, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(t.TempDir(), "service.sock")
+	srv := newTestServer(
+		apitypes.Router{{Path: "/proto", Method: http.MethodGet, Handler: proto}},
+		WithListeners(given),
+		WithListenerSpecs(
+			ListenerSpec{Kind: ListenH2C, Address: h2cAddress},
+			ListenerSpec{Kind: ListenUnix, Address: socket, Mode: 0o600},
+		),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx) }()
+
+	h2c := &http.Protocols{}
+	h2c.SetUnencryptedHTTP2(true)
+	unixTransport := &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+	}}
+	for name, test := range map[string]struct {
+		client   *http.Client
+		url      string
+		expected string
+	}{
+		"h2c":   {&http.Client{Transport: &http.Transport{Protocols: h2c}}, "http://" + h2cAddress, "HTTP/2.0"},
+		"unix":  {&http.Client{Transport: unixTransport}, "http://unix", "HTTP/1.1"},
+		"given": {http.DefaultClient, "http://" + given.Addr().String(), "HTTP/1.1"},
+	} {
+		var res *http.Response
+		for attempt := 0; attempt < 50; attempt++ {
+			if res, err = test.client.Get(test.url + "/api/proto"); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if !strings.Contains(string(body), test.expected) {
+			t.Errorf("%s: expected the request to be served over %s, got %s", name, test.expected, body)
+		}
+	}
+
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the socket file to have mode 0600, got %v", info)
+	}
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("Expected the server to shut down cleanly, got %v", err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket file to be removed on shutdown")
+	}
+}
+
+// TestParseListenerSpec checks listener specs are parsed from their URL form.
+func TestParseListenerSpec(t *testing.T) {
+	spec, err := ParseListenerSpec("unix:///run/service.sock?mode=0660")
+	if err != nil || spec != (ListenerSpec{Kind: ListenUnix, Address: "/run/service.sock", Mode: 0o660}) {
+		t.Errorf("Expected a unix spec with mode 0660, got %+v, %v", spec, err)
+	}
+	if spec.String() != "unix:///run/service.sock?mode=0660" {
+		t.Errorf("Expected the spec to be written back as it was parsed, got %s", spec)
+	}
+	for _, invalid := range []string{":8080", "udp://:53", "unix://", "unix:///a.sock?mode=rw"} {
+		if _, err := ParseListenerSpec(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+// TestHealthProbes checks the probes are served outside /api, with readiness reflecting the checks.
+func TestHealthProbes(t *testing.T) {
+	checker := health.NewHealthChecker()
+	checker.Register(health.Check{Name: "db", Critical: true, Func: func(context.Context) error {
+		return errors.New("connection refused")
+	}})
+	srv := newTestServer(nil, WithHealthChecker(checker))
+	handler := srv.handler()
+
+	for path, expected := range map[string]int{
+		LivenessPath:  http.StatusOK,
+		ReadinessPath: http.StatusServiceUnavailable,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != expected {
+			t.Errorf("Expected status code %v for %s, got %v", expected, path, w.Code)
+		}
+	}
+}
+
+// TestAdminHandler checks the admin endpoints are protected by their own auth middleware and that
+// the config is served with its secrets masked.
+func TestAdminHandler(t *testing.T) {
+	type database struct {
+		URL      string `json:"url"`
+		Password string `json:"password"`
+		Pepper   string `json:"pepper" secret:"true"`
+	}
+	srv := newTestServer(
+		apitypes.Router{{Path: "/boats", Method: http.MethodGet}},
+		WithAdmin(AdminConfig{
+			Listener:       ListenerSpec{Kind: ListenTCP, Address: "127.0.0.1:0"},
+			AuthMiddleware: middleware.NewMTLSAuthMiddleware(),
+			Config: func() any {
+				return map[string]any{"db": database{URL: "postgres://db", Password: "hunter2", Pepper: "salt"}}
+			},
+		}),
+	)
+	handler, err := srv.adminHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticated := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "operator"}, SerialNumber: big.NewInt(1)}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unauthenticated requests to be rejected, got %v", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, authenticated("/debug/routes"))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"pattern": "/api/boats"`) {
+		t.Errorf("Expected the route table, got %v %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, authenticated("/debug/config"))
+	body := w.Body.String()
+	if strings.Contains(body, "hunter2") || strings.Contains(body, "salt") || !strings.Contains(body, "postgres://db") {
+		t.Errorf("Expected the secrets to be masked and the rest served, got %s", body)
+	}
+
+	for _, path := range []string{"/debug/runtime", "/debug/pprof/", "/debug/vars"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, authenticated(path))
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected %s to be served, got %v", path, w.Code)
+		}
+	}
+
+	unprotected := newTestServer(nil, WithAdmin(AdminConfig{Listener: ListenerSpec{Kind: ListenTCP, Address: ":0"}}))
+	if _, err := unprotected.adminHandler(); err == nil {
+		t.Errorf("Expected an admin server on TCP without auth middleware to be refused")
+	}
+}
+
+// TestReload checks reloaded origins and rate limits apply to the requests which follow, without rebuilding
+// the handler.
+func TestReload(t *testing.T) {
+	srv := newTestServer(apitypes.Router{
+		{Path: "/items", Method: http.MethodGet, Handler: func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+			return apitypes.Response{Status: http.StatusOK}
+		}},
+	})
+	handler := srv.handler()
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+		req.Header.Set("Origin", "https://app.example")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	srv.Reload(Reloadable{AllowOrigins: []string{"https://other.example"}, CORS: DefaultCORS()})
+	if w := get(); w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("Expected neither CORS nor rate limit headers, got %v", w.Header())
+	}
+	srv.Reload(Reloadable{
+		AllowOrigins: []string{"https://app.example"},
+		CORS:         DefaultCORS(),
+		RateLimit:    ratelimit.Rule{Limit: 1, Window: time.Minute},
+	})
+	if w := get(); w.Header().Get("Access-Control-Allow-Origin") != "https://app.example" || w.Code != http.StatusOK {
+		t.Errorf("Expected the reloaded origin to be allowed, got %v %v", w.Code, w.Header())
+	}
+	if w := get(); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the reloaded rate limit to apply, got %v", w.Code)
+	}
+	if got := srv.Reloadable().AllowOrigins; len(got) != 1 {
+		t.Errorf("Expected the reloaded origins, got %v", got)
+	}
+}
+
+// TestServerSentEvents checks events reach the client as they're sent, through the compression
+// and logging middlewares, and the handler timeout doesn't cut the stream off.
+func TestServerSentEvents(t *testing.T) {
+	events := make(chan sse.Event)
+	srv := New(":0", []controller.Controller{testController(apitypes.Router{
+		{Path: "/events", Method: http.MethodGet, Handler: func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+			return sse.Response(events, sse.Options{})
+		}},
+	})}, logging.NewLogrusLogger(), []middleware.Middleware{
+		middleware.NewLoggingMiddleware(logging.NewLogrusLogger()),
+		middleware.NewCompressionMiddleware(0),
+	}, nil, nil, WithTimeouts(Timeouts{Handler: 50 * time.Millisecond}))
+	ts := httptest.NewServer(srv.handler())
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != "text/event-stream" || res.Header.Get("ETag") != "" {
+		t.Errorf("Expected an event stream without validators, got %v", res.Header)
+	}
+	if !res.Uncompressed {
+		t.Errorf("Expected the stream to be compressed")
+	}
+	lines := bufio.NewReader(res.Body)
+	time.Sleep(100 * time.Millisecond)
+	for _, data := range []string{"first", "second"} {
+		events <- sse.Event{Data: data}
+		for {
+			line, err := lines.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Expected the event %q, got %v", data, err)
+			}
+			if line == "data: "+data+"\n" {
+				break
+			}
+		}
+	}
+	close(events)
+}
+
+// TestWebSocketRoute checks that WebSocket routes are upgraded after going through the middlewares, whose
+// context values reach the handler, and that authentication is enforced on the handshake.
+func TestWebSocketRoute(t *testing.T) {
+	endpoint := &websocket.Endpoint{Handler: func(conn *websocket.Conn, r *http.Request) {
+		var msg string
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		_ = conn.WriteJSON(map[string]string{"requestID": r.Context().Value(contextypes.CTXRequestIDKey{}).(string), "message": msg})
+	}}
+	srv := New(":0", []controller.Controller{testController(apitypes.Router{
+		{Path: "/ws", Method: http.MethodGet, WebSocket: endpoint},
+		{Path: "/private/ws", Method: http.MethodGet, RequireAuth: true, WebSocket: endpoint},
+	})}, logging.NewLogrusLogger(), []middleware.Middleware{
+		middleware.NewRequestIDMiddleware(),
+		middleware.NewLoggingMiddleware(logging.NewLogrusLogger()),
+	}, middleware.NewMTLSAuthMiddleware(), nil, WithTimeouts(Timeouts{Handler: 50 * time.Millisecond}))
+	ts := httptest.NewServer(srv.handler())
+	defer ts.Close()
+
+	handshake := func(path string) (net.Conn, *bufio.Reader, *http.Response) {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("X-Request-ID", "ws-1")
+		if err := req.Write(conn); err != nil {
+			t.Fatal(err)
+		}
+		reader := bufio.NewReader(conn)
+		res, err := http.ReadResponse(reader, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, reader, res
+	}
+
+	conn, _, res := handshake("/api/private/ws")
+	conn.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the unauthenticated handshake to be rejected with 401, got %d", res.StatusCode)
+	}
+
+	conn, reader, res := handshake("/api/ws")
+	defer conn.Close()
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", res.StatusCode)
+	}
+	// The handler outlives the handler timeout, which doesn't apply to WebSocket routes.
+	time.Sleep(100 * time.Millisecond)
+	payload, mask := []byte(`"hello"`), []byte{1, 2, 3, 4}
+	frame := append([]byte{0x81, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		t.Fatal(err)
+	}
+	body := make([]byte, header[1]&0x7F)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"message":"hello","requestID":"ws-1"}`; header[0] != 0x81 || string(body) != want {
+		t.Errorf("Expected the text message %s, got %#x %s", want, header[0], body)
+	}
+}
+
+// TestWriteItems checks that collections of items are streamed as NDJSON or JSON arrays as negotiated,
+// and that errors are answered with a 500 before the first item and reported in the trailer after it.
+func TestWriteItems(t *testing.T) {
+	items := func(n int, failAt int) apitypes.Items {
+		return apitypes.SeqItems(func(yield func(map[string]int, error) bool) {
+			for i := range n {
+				if i == failAt {
+					yield(nil, errs.NewI18NError("cursor failed: %w", errs.ErrinternalError, "internalerror"))
+					return
+				}
+				if !yield(map[string]int{"id": i}, nil) {
+					return
+				}
+			}
+		})
+	}
+	srv := newTestServer(apitypes.Router{
+		{Path: "/boats", Method: http.MethodGet, Handler: func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+			return apitypes.Response{Content: items(3, -1)}
+		}},
+		{Path: "/empty", Method: http.MethodGet, Handler: func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+			empty := make(chan int)
+			close(empty)
+			return apitypes.Response{Content: apitypes.ChanItems(empty)}
+		}},
+		{Path: "/broken", Method: http.MethodGet, Handler: func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+			return apitypes.Response{Content: items(3, 0)}
+		}},
+		{Path: "/truncated", Method: http.MethodGet, Handler: func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+			return apitypes.Response{Content: items(3, 2)}
+		}},
+	})
+	ts := httptest.NewServer(srv.handler())
+	defer ts.Close()
+
+	tests := []struct {
+		path, accept string
+		status       int
+		contentType  string
+		body         string
+		trailer      string
+	}{
+		{"/boats", "", http.StatusOK, "application/json", "[{\"id\":0},{\"id\":1},{\"id\":2}]\n", ""},
+		{"/boats", NDJSONMediaType, http.StatusOK, NDJSONMediaType, "{\"id\":0}\n{\"id\":1}\n{\"id\":2}\n", ""},
+		{"/boats", "application/json, application/x-ndjson;q=0.5", http.StatusOK, "application/json", "[{\"id\":0},{\"id\":1},{\"id\":2}]\n", ""},
+		{"/boats", "text/csv", http.StatusNotAcceptable, "application/json", "", ""},
+		{"/empty", "", http.StatusOK, "application/json", "[]\n", ""},
+		{"/broken", NDJSONMediaType, http.StatusInternalServerError, "application/json", "", ""},
+		{"/truncated", "", http.StatusOK, "application/json", "[{\"id\":0},{\"id\":1}", "internalerror"},
+		{"/truncated", NDJSONMediaType, http.StatusOK, NDJSONMediaType, "{\"id\":0}\n{\"id\":1}\n{\"error\":\"internalerror\"}\n", "internalerror"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api"+tt.path, nil)
+		req.Header.Set("Accept", tt.accept)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != tt.status || !strings.HasPrefix(res.Header.Get("Content-Type"), tt.contentType) {
+			t.Errorf("%s with Accept %q: expected %d %s, got %d %s", tt.path, tt.accept, tt.status, tt.contentType, res.StatusCode, res.Header.Get("Content-Type"))
+		}
+		if tt.status == http.StatusOK && string(body) != tt.body {
+			t.Errorf("%s with Accept %q: expected the body %q, got %q", tt.path, tt.accept, tt.body, body)
+		}
+		if trailer := res.Trailer.Get(StreamErrorTrailer); trailer != tt.trailer {
+			t.Errorf("%s with Accept %q: expected the trailer %q, got %q", tt.path, tt.accept, tt.trailer, trailer)
+		}
+	}
+}
+
+// TestWriteFile checks that files are sent whole or by ranges, with their disposition, and that If-Range
+// falls back to the whole file once it changed.
+func TestWriteFile(t *testing.T) {
+	content := "0123456789abcdefghij"
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	srv := newTestServer(apitypes.Router{
+		{Path: "/report", Method: http.MethodGet, Handler: func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+			return apitypes.Response{
+				Content:      apitypes.File{Content: strings.NewReader(content), Name: "informe año.txt"},
+				ETag:         "v1",
+				LastModified: modTime,
+			}
+		}},
+	})
+	ts := httptest.NewServer(srv.handler())
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		headers       map[string]string
+		status        int
+		contentRange  string
+		contentLength string
+		body          string
+	}{
+		{"whole", nil, http.StatusOK, "", "20", content},
+		{"range", map[string]string{"Range": "bytes=5-9"}, http.StatusPartialContent, "bytes 5-9/20", "5", "56789"},
+		{"suffix", map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "bytes 17-19/20", "3", "hij"},
+		{"unsatisfiable", map[string]string{"Range": "bytes=30-"}, http.StatusRequestedRangeNotSatisfiable, "bytes */20", "", ""},
+		{"if-range match", map[string]string{"Range": "bytes=0-1", "If-Range": `"v1"`}, http.StatusPartialContent, "bytes 0-1/20", "2", "01"},
+		{"if-range changed", map[string]string{"Range": "bytes=0-1", "If-Range": `"v0"`}, http.StatusOK, "", "20", content},
+		{"not modified", map[string]string{"If-None-Match": `"v1"`}, http.StatusNotModified, "", "", ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/report", nil)
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != tt.status || res.Header.Get("Content-Range") != tt.contentRange {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.name, tt.status, tt.contentRange, res.StatusCode, res.Header.Get("Content-Range"))
+		}
+		if tt.body != "" && (string(body) != tt.body || res.Header.Get("Content-Length") != tt.contentLength) {
+			t.Errorf("%s: expected %q with Content-Length %s, got %q with %s", tt.name, tt.body, tt.contentLength, body, res.Header.Get("Content-Length"))
+		}
+		if tt.status == http.StatusOK {
+			if disposition := res.Header.Get("Content-Disposition"); disposition != "attachment; filename*=utf-8''informe%20a%C3%B1o.txt" {
+				t.Errorf("%s: unexpected Content-Disposition %q", tt.name, disposition)
+			}
+			if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/plain") || res.Header.Get("Accept-Ranges") != "bytes" {
+				t.Errorf("%s: unexpected headers %v", tt.name, res.Header)
+			}
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/report", nil)
+	req.Header.Set("Range", "bytes=0-1,10-11")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	mediaType, params, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if res.StatusCode != http.StatusPartialContent || mediaType != "multipart/byteranges" {
+		t.Fatalf("Expected a multipart/byteranges response, got %d %s", res.StatusCode, mediaType)
+	}
+	reader := multipart.NewReader(res.Body, params["boundary"])
+	for _, expected := range []string{"01", "ab"} {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(part); string(body) != expected {
+			t.Errorf("Expected the range %q, got %q", expected, body)
+		}
+	}
+}
+
+// readerFromRecorder is a recorder which counts the bytes copied through its io.ReaderFrom, like the
+// connection's writer does with sendfile.
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom int64
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	n, err := io.Copy(r.ResponseRecorder, src)
+	r.readFrom += n
+	return n, err
+}
+
+// TestWriteFileReadFrom checks files reach the io.ReaderFrom of the client's writer through the middlewares
+// of the service and the default handler timeout, so they're copied without going through user space.
+func TestWriteFileReadFrom(t *testing.T) {
+	content := strings.Repeat("\x89PNG", 4096)
+	logger := logging.NewLogrusLogger()
+	srv := New(":0", []controller.Controller{testController(apitypes.Router{
+		{Path: "/image", Method: http.MethodGet, Handler: func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+			return apitypes.Response{Content: apitypes.File{Content: strings.NewReader(content), Name: "image.png", Inline: true}}
+		}},
+	})}, logger, []middleware.Middleware{
+		middleware.NewRequestIDMiddleware(),
+		middleware.NewLoggingMiddleware(logger),
+		middleware.NewLangMiddleware(),
+		middleware.NewCompressionMiddleware(0),
+	}, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/image", nil)
+	req.Header.Set("X-Request-ID", "1")
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	srv.handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != content {
+		t.Fatalf("Expected the image, got %v and %d bytes", w.Code, w.Body.Len())
+	}
+	if w.readFrom == 0 {
+		t.Errorf("Expected the image to be copied through the client's ReadFrom")
+	}
+}
+
+// TestStatic checks that mounted files are served with their precompressed variants and cache headers,
+// that SPA routes fall back to the index and that directories aren't listed.
+func TestStatic(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":                {Data: []byte("<html>app</html>")},
+		"favicon.ico":               {Data: []byte("icon")},
+		"assets/app.3f2a9c1b.js":    {Data: []byte("console.log('app')")},
+		"assets/app.3f2a9c1b.js.br": {Data: []byte("brotli")},
+		"assets/app.3f2a9c1b.js.gz": {Data: []byte("gzip")},
+	}
+	srv := newTestServer(apitypes.Router{{Path: "/boats", Method: http.MethodGet, Handler: func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+		return apitypes.Response{Status: http.StatusOK, Content: "boats"}
+	}}}, WithStatic(StaticConfig{Prefix: "/app", FS: files, SPA: true}), WithStatic(StaticConfig{FS: files}))
+	ts := httptest.NewServer(srv.handler())
+	defer ts.Close()
+
+	tests := []struct {
+		path, acceptEncoding string
+		status               int
+		body, encoding       string
+		cacheControl         string
+	}{
+		{"/app/", "", http.StatusOK, "<html>app</html>", "", revalidateCacheControl},
+		{"/app/settings/profile", "", http.StatusOK, "<html>app</html>", "", revalidateCacheControl},
+		{"/app/assets/app.3f2a9c1b.js", "gzip, br", http.StatusOK, "brotli", "br", immutableCacheControl},
+		{"/app/assets/app.3f2a9c1b.js", "gzip", http.StatusOK, "gzip", "gzip", immutableCacheControl},
+		{"/app/assets/app.3f2a9c1b.js", "identity", http.StatusOK, "console.log('app')", "", immutableCacheControl},
+		{"/app/favicon.ico", "", http.StatusOK, "icon", "", revalidateCacheControl},
+		{"/app/assets/", "", http.StatusNotFound, "", "", ""},
+		{"/app/missing.png", "", http.StatusNotFound, "", "", ""},
+		{"/settings", "", http.StatusNotFound, "", "", ""},
+		{"/favicon.ico", "", http.StatusOK, "icon", "", revalidateCacheControl},
+		{"/api/boats", "", http.StatusOK, "\"boats\"\n", "", ""},
+		{"/api/missing", "", http.StatusNotFound, "", "", ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+tt.path, nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.status, res.StatusCode)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		if string(body) != tt.body || res.Header.Get("Content-Encoding") != tt.encoding || res.Header.Get("Cache-Control") != tt.cacheControl {
+			t.Errorf("%s with %q: expected %q encoded as %q with %q, got %q encoded as %q with %q", tt.path, tt.acceptEncoding,
+				tt.body, tt.encoding, tt.cacheControl, body, res.Header.Get("Content-Encoding"), res.Header.Get("Cache-Control"))
+		}
+		if strings.HasSuffix(tt.path, ".js") && !strings.HasPrefix(res.Header.Get("Content-Type"), "text/javascript") {
+			t.Errorf("%s: expected the type of the original file, got %q", tt.path, res.Header.Get("Content-Type"))
+		}
+	}
+
+	for path, status := range map[string]int{"/api/boats": http.StatusMethodNotAllowed, "/api/missing": http.StatusNotFound} {
+		res, err := http.Post(ts.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != status {
+			t.Errorf("POST %s: expected %d despite the mount on /, got %d", path, status, res.StatusCode)
+		}
+	}
+
+	res, err := http.Get(ts.URL + "/app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/app/", nil)
+	req.Header.Set("If-None-Match", res.Header.Get("ETag"))
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected the index to be revalidated with its ETag, got %d", res.StatusCode)
+	}
+}
+
+// TestFingerprinted checks which file names are recognized as fingerprinted.
+func TestFingerprinted(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"assets/app.3f2a9c1b.js":     true,
+		"assets/index-D9fHk2Qz.js":   true,
+		"main.3f2a9c1b.chunk.css.gz": true,
+		"bootstrap-override.css":     false,
+		"favicon.ico":                false,
+		"index.html":                 false,
+	} {
+		if Fingerprinted(name) != expected {
+			t.Errorf("Expected Fingerprinted(%q) to be %v", name, expected)
+		}
+	}
+}
+
+// TestRouteFields checks JSON responses are reduced to the fields selected within the route's allow-list,
+// handlers see the expanded relations, other formats are sent whole and cached responses are kept per selection.
+func TestRouteFields(t *testing.T) {
+	type owner struct {
+		Name string `json:"name" yaml:"name"`
+	}
+	type boat struct {
+		ID    int    `json:"id" yaml:"id"`
+		Name  string `json:"name" yaml:"name"`
+		Owner *owner `json:"owner,omitempty" yaml:"owner,omitempty"`
+	}
+	get := func(w http.ResponseWriter, r *http.Request) apitypes.Response {
+		b := boat{ID: 1, Name: "Nautilus"}
+		if fields.Expanded(r, "owner") {
+			b.Owner = &owner{Name: "Nemo"}
+		}
+		return apitypes.Response{Status: http.StatusOK, Content: b}
+	}
+	srv := newTestServer(apitypes.Router{
+		{Path: "/boat", Method: http.MethodGet, Handler: get, Fields: []string{"id", "name"}, Expand: []string{"owner"}, Cache: apitypes.CachePolicy{TTL: time.Minute}},
+	})
+	handler := srv.handler()
+
+	for _, test := range []struct {
+		url, accept, expected string
+		status                int
+	}{
+		{"/api/boat?fields=id", "", `{"id":1}`, http.StatusOK},
+		{"/api/boat?fields=name", "", `{"name":"Nautilus"}`, http.StatusOK},
+		{"/api/boat?fields=id&expand=owner", "", `{"id":1,"owner":{"name":"Nemo"}}`, http.StatusOK},
+		{"/api/boat", "", `{"id":1,"name":"Nautilus"}`, http.StatusOK},
+		{"/api/boat?fields=id", "application/yaml", "id: 1\nname: Nautilus\n", http.StatusOK},
+		{"/api/boat?fields=owner", "", "", http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s: expected status code %v, got %v", test.url, test.status, w.Code)
+			continue
+		}
+		if body := strings.TrimSuffix(w.Body.String(), "\n"); test.expected != "" && body != strings.TrimSuffix(test.expected, "\n") {
+			t.Errorf("%s: expected body %q, got %q", test.url, test.expected, body)
+		}
+	}
+}
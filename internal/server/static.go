@@ -0,0 +1,214 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+	"github.com/lucastomic/msBaseProj/internal/etag"
+	"github.com/lucastomic/msBaseProj/internal/middleware"
+)
+
+const (
+	// DefaultIndex is the file served for directories and, in SPAs, for client-side routes.
+	DefaultIndex = "index.html"
+	// immutableCacheControl lets clients keep fingerprinted files for a year, as a new build renames them.
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// revalidateCacheControl makes clients check with the server before reusing a file, so a new build
+	// is picked up straight away.
+	revalidateCacheControl = "no-cache"
+)
+
+// precompressed are the encodings precompressed variants of the static files are looked for, in order of
+// preference, with the extension of their files.
+var precompressed = []struct{ encoding, ext string }{{"br", ".br"}, {"gzip", ".gz"}}
+
+// StaticConfig mounts a file system, like an embed.FS holding a built frontend, on a path of the server.
+// Files are served with GET and HEAD, with validators and Range support, and outside the API's middlewares,
+// as browsers loading assets don't send the headers the API requires. Directories are never listed.
+type StaticConfig struct {
+	// Prefix is the path the files are served under, like / or /app. It can't be under /api.
+	Prefix string
+	// FS holds the files. Use fs.Sub to serve a subdirectory of an embed.FS.
+	FS fs.FS
+	// Index is the file served for directories. DefaultIndex when empty.
+	Index string
+	// SPA serves the root index for the paths which match no file and have no extension, so the
+	// client-side router handles them, instead of a 404.
+	SPA bool
+	// Immutable reports whether a file is fingerprinted, i.e. its name changes along with its content,
+	// so clients can cache it for good. Fingerprinted when nil. Other files are revalidated on every use.
+	Immutable func(name string) bool
+}
+
+// Fingerprinted reports whether the file name carries a content hash, as bundlers add them: a segment of
+// at least 8 letters and digits, with at least a digit, separated by a dot or a dash, like app.3f2a9c1b.js
+// or index-D9fHk2Qz.js. Precompressed variants are judged by the name of the original file.
+func Fingerprinted(name string) bool {
+	base := path.Base(name)
+	base = strings.TrimSuffix(base, path.Ext(base))
+	for _, segment := range strings.FieldsFunc(base, func(r rune) bool { return r == '.' || r == '-' }) {
+		if len(segment) >= 8 && strings.ContainsAny(segment, "0123456789") && alphanumeric(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// alphanumeric reports whether s only holds ASCII letters, digits and underscores.
+func alphanumeric(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// WithStatic mounts a file system on the server, e.g. to serve a single-page application from the same
+// binary as its API. It can be given several times for different prefixes. See StaticConfig.
+// It panics if the prefix is under /api, as that is a programming error.
+func WithStatic(config StaticConfig) Option {
+	if config.Prefix == "" {
+		config.Prefix = "/"
+	}
+	if !strings.HasPrefix(config.Prefix, "/") || config.Prefix == "/api" || strings.HasPrefix(config.Prefix, "/api/") {
+		panic(fmt.Sprintf("invalid static prefix %q, it must start with / and be outside /api", config.Prefix))
+	}
+	if config.Index == "" {
+		config.Index = DefaultIndex
+	}
+	if config.Immutable == nil {
+		config.Immutable = Fingerprinted
+	}
+	return func(s *Server) {
+		s.statics = append(s.statics, config)
+	}
+}
+
+// staticPattern returns the pattern the files of config are registered with.
+func staticPattern(config StaticConfig) string {
+	return "GET " + strings.TrimSuffix(config.Prefix, "/") + "/"
+}
+
+// staticHandler returns the handler serving the files of config. Paths which match no file, and
+// directories without an index, are answered with a 404 unless the SPA fallback applies.
+func (s *Server) staticHandler(config StaticConfig) http.Handler {
+	etags := &sync.Map{}
+	prefix := strings.TrimSuffix(config.Prefix, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(strings.TrimPrefix(path.Clean(r.URL.Path), prefix), "/")
+		if name == "" {
+			name = "."
+		}
+		info, err := fs.Stat(config.FS, name)
+		switch {
+		case err == nil && info.IsDir():
+			name = path.Join(name, config.Index)
+			if _, err := fs.Stat(config.FS, name); err != nil {
+				s.staticNotFound(w, r)
+				return
+			}
+		case err != nil && config.SPA && path.Ext(name) == "":
+			name = config.Index
+		case err != nil:
+			s.staticNotFound(w, r)
+			return
+		}
+		if err := s.serveStatic(w, r, config, name, etags); err != nil {
+			s.logger.Error(r.Context(), "Failed to serve static file %s: %v", name, err)
+			s.handleError(r, w, errs.NewI18NError("serving a static file: %w", errs.ErrinternalError, "internalerror"), http.StatusInternalServerError)
+		}
+	})
+}
+
+// serveStatic sends the file name of config, or its precompressed variant best accepted by the client.
+// Its entity tag is computed from its content the first time it's served and kept in etags.
+func (s *Server) serveStatic(w http.ResponseWriter, r *http.Request, config StaticConfig, name string, etags *sync.Map) error {
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+	contentType := mime.TypeByExtension(path.Ext(name))
+	cacheControl := revalidateCacheControl
+	if path.Base(name) != config.Index && config.Immutable(name) {
+		cacheControl = immutableCacheControl
+	}
+
+	served := name
+	var available []string
+	for _, variant := range precompressed {
+		if _, err := fs.Stat(config.FS, name+variant.ext); err == nil {
+			available = append(available, variant.encoding)
+		}
+	}
+	if encoding := middleware.NegotiateEncoding(r.Header.Get("Accept-Encoding"), available); encoding != "" {
+		for _, variant := range precompressed {
+			if variant.encoding == encoding {
+				served = name + variant.ext
+			}
+		}
+		header.Set("Content-Encoding", encoding)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+
+	file, err := config.FS.Open(served)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+	tag, err := staticETag(etags, served, info, content)
+	if err != nil {
+		return err
+	}
+
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("Cache-Control", cacheControl)
+	header.Set("ETag", tag)
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return nil
+}
+
+// staticETag returns the entity tag of the file, computing it from its content unless etags holds one
+// for the same name, size and modification time. content is rewound afterwards.
+func staticETag(etags *sync.Map, name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	key := fmt.Sprintf("%s|%d|%d", name, info.Size(), info.ModTime().UnixNano())
+	if tag, ok := etags.Load(key); ok {
+		return tag.(string), nil
+	}
+	tag, err := etag.ComputeReader(content, false)
+	if err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etags.Store(key, tag)
+	return tag, nil
+}
+
+// staticNotFound answers a request for a static file which doesn't exist.
+func (s *Server) staticNotFound(w http.ResponseWriter, r *http.Request) {
+	s.handleError(r, w, errs.NewI18NError("static file not found: %w", errs.ErrNotFound, "resourcenotfound"), http.StatusNotFound)
+}
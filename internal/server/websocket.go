@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/lucastomic/msBaseProj/internal/websocket"
+)
+
+// websocketConns tracks the open WebSocket connections, which the http.Server stops tracking once they're
+// taken over, so they can be closed when the server shuts down.
+type websocketConns struct {
+	mu    sync.Mutex
+	conns map[*websocket.Conn]struct{}
+}
+
+// newWebSocketConns creates an empty set of connections.
+func newWebSocketConns() *websocketConns {
+	return &websocketConns{conns: map[*websocket.Conn]struct{}{}}
+}
+
+// add adds conn to the set.
+func (c *websocketConns) add(conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns[conn] = struct{}{}
+}
+
+// remove removes conn from the set.
+func (c *websocketConns) remove(conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.conns, conn)
+}
+
+// closeAll closes every connection with websocket.StatusGoingAway, so clients know they can reconnect
+// to another replica.
+func (c *websocketConns) closeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for conn := range c.conns {
+		go conn.Close(websocket.StatusGoingAway, "server shutting down")
+	}
+}
+
+// makeWebSocketHandlerFunc wraps the WebSocket endpoint into an http.HandlerFunc upgrading the requests
+// and running the endpoint's handler with their connection, which is closed once it returns.
+// Requests which can't be upgraded are answered through handleError.
+func (s *Server) makeWebSocketHandlerFunc(endpoint websocket.Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r, endpoint)
+		if err != nil {
+			s.handleError(r, w, err, websocket.HandshakeStatus(err))
+			return
+		}
+		s.websockets.add(conn)
+		defer s.websockets.remove(conn)
+		defer conn.Close(websocket.StatusNormalClosure, "")
+		endpoint.Handler(conn, r.WithContext(conn.Context()))
+	}
+}
@@ -0,0 +1,199 @@
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/controller/apitypes"
+)
+
+const (
+	// DefaultHeartbeat is how often a comment is sent on idle streams by default, so proxies and load
+	// balancers don't close them and disconnected clients are noticed.
+	DefaultHeartbeat = 15 * time.Second
+	// DefaultWriteTimeout is the time allowed to write an event by default. A client which doesn't read
+	// its events for that long is disconnected.
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+// Event is a server-sent event. Only the fields set are sent.
+type Event struct {
+	ID    string        // ID identifies the event. Clients send the last one received in Last-Event-ID when reconnecting.
+	Event string        // Event is the type of the event, "message" for clients when empty.
+	Data  any           // Data is the payload. Strings and byte slices are sent as they are, anything else as JSON.
+	Retry time.Duration // Retry tells the client how long to wait before reconnecting if the stream is lost.
+}
+
+// Options configures a stream of events.
+type Options struct {
+	// Heartbeat is how often a comment is sent while no event is. DefaultHeartbeat when zero, disabled when negative.
+	Heartbeat time.Duration
+	// Retry is the reconnection time sent to the client when the stream starts. The client's default when zero.
+	Retry time.Duration
+	// WriteTimeout is the time allowed to write an event, replacing the server's write timeout, which would
+	// otherwise close long streams. DefaultWriteTimeout when zero.
+	WriteTimeout time.Duration
+}
+
+// Response returns a response streaming the events received from events until it's closed or the client
+// goes away. The producer must stop sending when the request's context is done, as nobody reads events after.
+// The handler timeout only bounds the handler, not the stream, but routes streaming events should have no cache policy.
+// For example:
+//
+//	func (c Controller) progress(w http.ResponseWriter, r *http.Request) apitypes.Response {
+//		events := make(chan sse.Event)
+//		go c.jobs.Watch(r.Context(), sse.LastEventID(r), events)
+//		return sse.Response(events, sse.Options{})
+//	}
+func Response(events <-chan Event, opts Options) apitypes.Response {
+	return apitypes.Response{
+		Status: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":  "text/event-stream",
+			"Cache-Control": "no-cache",
+			// Keeps nginx from buffering the stream.
+			"X-Accel-Buffering": "no",
+		},
+		Stream: func(w http.ResponseWriter, r *http.Request) error {
+			return stream(w, r, events, opts)
+		},
+	}
+}
+
+// LastEventID returns the ID of the last event received by a reconnecting client, so the stream can be
+// resumed after it. It's empty for new clients.
+func LastEventID(r *http.Request) string {
+	return r.Header.Get("Last-Event-ID")
+}
+
+// stream writes the events received from events to w, with a heartbeat while idle, until events is closed
+// or the request's context is done.
+func stream(w http.ResponseWriter, r *http.Request, events <-chan Event, opts Options) error {
+	heartbeat := opts.Heartbeat
+	if heartbeat == 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	writer := NewWriter(w, opts.WriteTimeout)
+	if opts.Retry > 0 {
+		if err := writer.Send(Event{Retry: opts.Retry}); err != nil {
+			return err
+		}
+	} else if err := writer.Comment("stream opened"); err != nil {
+		// A comment sends the headers straight away, so the client knows the stream is open.
+		return err
+	}
+
+	var ticks <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := writer.Send(event); err != nil {
+				return err
+			}
+		case <-ticks:
+			if err := writer.Comment("heartbeat"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Writer writes server-sent events to a response, flushing each one so it reaches the client straight away.
+// It's meant for handlers writing their own StreamFunc; Response covers the common case.
+type Writer struct {
+	w            http.ResponseWriter
+	controller   *http.ResponseController
+	writeTimeout time.Duration
+}
+
+// NewWriter creates a Writer sending events to w, allowing writeTimeout to write each of them
+// (DefaultWriteTimeout when zero).
+func NewWriter(w http.ResponseWriter, writeTimeout time.Duration) *Writer {
+	if writeTimeout == 0 {
+		writeTimeout = DefaultWriteTimeout
+	}
+	return &Writer{w: w, controller: http.NewResponseController(w), writeTimeout: writeTimeout}
+}
+
+// Send writes the event and flushes it.
+func (sw *Writer) Send(event Event) error {
+	var b strings.Builder
+	if event.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", singleLine(event.ID))
+	}
+	if event.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", singleLine(event.Event))
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", event.Retry.Milliseconds())
+	}
+	if event.Data != nil {
+		data, err := encodeData(event.Data)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(data, "\n") {
+			fmt.Fprintf(&b, "data: %s\n", line)
+		}
+	}
+	b.WriteString("\n")
+	return sw.write(b.String())
+}
+
+// Comment writes a comment, which clients ignore, and flushes it.
+func (sw *Writer) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(&b, ": %s\n", line)
+	}
+	b.WriteString("\n")
+	return sw.write(b.String())
+}
+
+// write writes s within the write timeout and flushes it.
+func (sw *Writer) write(s string) error {
+	// Writers which can't set deadlines, like test recorders, aren't bound by one.
+	_ = sw.controller.SetWriteDeadline(time.Now().Add(sw.writeTimeout))
+	if _, err := io.WriteString(sw.w, s); err != nil {
+		return err
+	}
+	return sw.controller.Flush()
+}
+
+// encodeData returns the data of an event as it's sent: strings and byte slices as they are, with their
+// line breaks normalized, and anything else as JSON.
+func encodeData(data any) (string, error) {
+	var s string
+	switch d := data.(type) {
+	case string:
+		s = d
+	case []byte:
+		s = string(d)
+	default:
+		encoded, err := json.Marshal(d)
+		if err != nil {
+			return "", err
+		}
+		s = string(encoded)
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n"), nil
+}
+
+// singleLine strips the line breaks of a field which must fit in a single line, like the ID.
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
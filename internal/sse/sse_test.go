@@ -0,0 +1,64 @@
+package sse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestResponse checks events are written in the event stream format, multi-line data split in data fields.
+func TestResponse(t *testing.T) {
+	events := make(chan Event, 3)
+	events <- Event{ID: "1", Event: "progress", Data: map[string]int{"done": 50}}
+	events <- Event{ID: "2\n", Data: "first line\r\nsecond line", Retry: 3 * time.Second}
+	close(events)
+
+	res := Response(events, Options{Retry: time.Second})
+	if res.Headers["Content-Type"] != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %v", res.Headers)
+	}
+	w := httptest.NewRecorder()
+	if err := res.Stream(w, httptest.NewRequest(http.MethodGet, "/events", nil)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "retry: 1000\n\n" +
+		"id: 1\nevent: progress\ndata: {\"done\":50}\n\n" +
+		"id: 2\nretry: 3000\ndata: first line\ndata: second line\n\n"
+	if w.Body.String() != expected {
+		t.Errorf("Expected\n%q\ngot\n%q", expected, w.Body.String())
+	}
+	if !w.Flushed {
+		t.Errorf("Expected the events to be flushed")
+	}
+}
+
+// TestResponseHeartbeat checks idle streams get heartbeats and end when the client goes away.
+func TestResponseHeartbeat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "41")
+	if LastEventID(req) != "41" {
+		t.Errorf("Expected the last event ID to be 41, got %q", LastEventID(req))
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	w := httptest.NewRecorder()
+	done := make(chan error)
+	go func() {
+		done <- Response(make(chan Event), Options{Heartbeat: 10 * time.Millisecond}).Stream(w, req)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the stream to end when the client went away")
+	}
+	if body := w.Body.String(); !strings.HasPrefix(body, ": stream opened\n\n") || !strings.Contains(body, ": heartbeat\n\n") {
+		t.Errorf("Expected the stream to be opened and kept alive, got %q", body)
+	}
+}
@@ -0,0 +1,64 @@
+package tlsconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// Identity is the identity asserted by a verified client certificate.
+type Identity struct {
+	CommonName     string   // CommonName is the subject's common name.
+	Organization   []string // Organization are the subject's organizations.
+	DNSNames       []string // DNSNames are the DNS subject alternative names.
+	URIs           []string // URIs are the URI subject alternative names, e.g. SPIFFE IDs.
+	EmailAddresses []string // EmailAddresses are the email subject alternative names.
+	SerialNumber   string   // SerialNumber is the certificate's serial number in hexadecimal.
+	Fingerprint    string   // Fingerprint is the SHA-256 of the certificate in hexadecimal.
+}
+
+// String returns the most specific name of the identity: its first URI, DNS name or common name.
+func (i Identity) String() string {
+	switch {
+	case len(i.URIs) > 0:
+		return i.URIs[0]
+	case len(i.DNSNames) > 0:
+		return i.DNSNames[0]
+	default:
+		return i.CommonName
+	}
+}
+
+// Names returns every name the identity can be matched by: its common name and subject alternative names.
+func (i Identity) Names() []string {
+	names := []string{}
+	if i.CommonName != "" {
+		names = append(names, i.CommonName)
+	}
+	names = append(names, i.URIs...)
+	names = append(names, i.DNSNames...)
+	return append(names, i.EmailAddresses...)
+}
+
+// ClientIdentity returns the identity of the client certificate of the request, if the request came over
+// TLS with a certificate verified against the client CAs. Certificates which were sent but not verified,
+// like those accepted by the RequireAnyClientCert policy, don't count.
+func ClientIdentity(r *http.Request) (Identity, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Identity{}, false
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	fingerprint := sha256.Sum256(cert.Raw)
+	identity := Identity{
+		CommonName:     cert.Subject.CommonName,
+		Organization:   cert.Subject.Organization,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		SerialNumber:   cert.SerialNumber.Text(16),
+		Fingerprint:    hex.EncodeToString(fingerprint[:]),
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+	return identity, true
+}
@@ -0,0 +1,111 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader serves the TLS configuration built from a Config, reloading it when its files change so
+// certificates can be rotated without restarting the server.
+// Files are checked lazily, at most once every ReloadInterval, during the handshakes. If a reload fails the
+// previous configuration keeps being served, so a half-written certificate doesn't break the connections.
+type Reloader struct {
+	config  Config
+	onError func(error) // onError is called with the errors of failed reloads.
+
+	mu        sync.Mutex
+	current   *tls.Config          // current is the configuration being served.
+	modTimes  map[string]time.Time // modTimes are the modification times of the files current was loaded from.
+	lastCheck time.Time            // lastCheck is when the files were last checked.
+	now       func() time.Time     // now returns the current time.
+}
+
+// NewReloader loads the given config, failing if its files can't be loaded. onError, which can be nil,
+// is called with the errors of later reloads.
+func NewReloader(config Config, onError func(error)) (*Reloader, error) {
+	if config.ReloadInterval <= 0 {
+		config.ReloadInterval = DefaultReloadInterval
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+	r := &Reloader{config: config, onError: onError, now: time.Now}
+	current, err := config.load()
+	if err != nil {
+		return nil, err
+	}
+	r.current, r.modTimes, r.lastCheck = current, r.stat(), r.now()
+	return r, nil
+}
+
+// TLSConfig returns the tls.Config to give to the http.Server. Every handshake is served the current configuration.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.Current(), nil
+		},
+	}
+}
+
+// Current returns the configuration being served, reloading it first if it's time to check the files
+// and they changed.
+func (r *Reloader) Current() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if now.Sub(r.lastCheck) < r.config.ReloadInterval {
+		return r.current
+	}
+	r.lastCheck = now
+	if modTimes := r.stat(); !sameModTimes(modTimes, r.modTimes) {
+		if err := r.reload(modTimes); err != nil {
+			r.onError(err)
+		}
+	}
+	return r.current
+}
+
+// Reload loads the files straight away, regardless of whether they changed.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reload(r.stat())
+}
+
+// reload loads the files, which had the given modification times. The caller must hold the lock.
+func (r *Reloader) reload(modTimes map[string]time.Time) error {
+	current, err := r.config.load()
+	if err != nil {
+		return err
+	}
+	r.current, r.modTimes = current, modTimes
+	return nil
+}
+
+// stat returns the modification times of the files. Files which can't be read are left out,
+// which counts as a change once they can be read again.
+func (r *Reloader) stat() map[string]time.Time {
+	modTimes := map[string]time.Time{}
+	for _, file := range r.config.files() {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// sameModTimes reports whether a and b hold the same files with the same modification times.
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, modTime := range a {
+		if other, ok := b[file]; !ok || !other.Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultReloadInterval is how often the certificate files are checked for changes when Config doesn't set it.
+const DefaultReloadInterval = 30 * time.Second
+
+// Config defines how the server terminates TLS connections.
+type Config struct {
+	CertFile       string             // CertFile is the PEM encoded certificate chain of the server.
+	KeyFile        string             // KeyFile is the PEM encoded private key of the server.
+	MinVersion     uint16             // MinVersion is the lowest TLS version accepted. TLS 1.2 when zero.
+	CipherSuites   []uint16           // CipherSuites restricts the cipher suites of TLS 1.2 and lower. Go's defaults when empty.
+	ClientCAFiles  []string           // ClientCAFiles are the PEM encoded CAs client certificates are verified against.
+	ClientAuth     tls.ClientAuthType // ClientAuth is the client certificate policy. RequireAndVerifyClientCert when there are client CAs.
+	ReloadInterval time.Duration      // ReloadInterval is how often the files are checked for changes. DefaultReloadInterval when zero.
+}
+
+// Enabled reports whether the config enables TLS.
+func (c Config) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// MutualTLS reports whether client certificates are requested.
+func (c Config) MutualTLS() bool {
+	return c.clientAuth() != tls.NoClientCert
+}
+
+// clientAuth returns the client certificate policy, applying the default.
+func (c Config) clientAuth() tls.ClientAuthType {
+	if c.ClientAuth == tls.NoClientCert && len(c.ClientCAFiles) > 0 {
+		return tls.RequireAndVerifyClientCert
+	}
+	return c.ClientAuth
+}
+
+// load reads the files of the config and builds the tls.Config served to clients.
+func (c Config) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading the server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   c.MinVersion,
+		CipherSuites: c.CipherSuites,
+		ClientAuth:   c.clientAuth(),
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+	if len(c.ClientCAFiles) > 0 {
+		pool := x509.NewCertPool()
+		for _, file := range c.ClientCAFiles {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("loading the client CAs: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("loading the client CAs: no certificate found in %s", file)
+			}
+		}
+		tlsConfig.ClientCAs = pool
+	} else if tlsConfig.ClientAuth >= tls.VerifyClientCertIfGiven {
+		return nil, errors.New("verifying client certificates requires client CAs")
+	}
+	return tlsConfig, nil
+}
+
+// files returns every file the config is loaded from.
+func (c Config) files() []string {
+	return append([]string{c.CertFile, c.KeyFile}, c.ClientCAFiles...)
+}
@@ -0,0 +1,185 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and its key generated for the tests.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate for the given template fields, signed by parent or self-signed if nil.
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert, key}
+}
+
+// newTestCA creates a self-signed CA.
+func newTestCA(t *testing.T) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil)
+}
+
+// writeFiles writes the PEM encoded certificate and key of c into dir, returning their paths.
+func (c *testCert) writeFiles(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	keyDER, _ := x509.MarshalECPrivateKey(c.key)
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certFile, keyFile
+}
+
+// tlsCertificate returns c as a tls.Certificate.
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+// writes counts the files written, to give every write a different modification time.
+var writes int
+
+// writeFile writes data into file, moving its modification time forward so a change is always noticed.
+func writeFile(t *testing.T, file string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	writes++
+	modTime := time.Now().Add(time.Duration(writes) * time.Second)
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMutualTLS checks clients are required a certificate signed by the client CAs and that its identity
+// is exposed to handlers.
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+	serverCert := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	certFile, keyFile := serverCert.writeFiles(t, dir, "server")
+	spiffeID, _ := url.Parse("spiffe://example.org/billing")
+	clientCert := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "billing"},
+		URIs:        []*url.URL{spiffeID},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	reloader, err := NewReloader(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFiles: []string{caFile}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := ClientIdentity(r)
+		if !ok {
+			t.Errorf("Expected the client identity to be exposed")
+		}
+		io.WriteString(w, identity.String())
+	}))
+	srv.TLS = reloader.TLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+	}
+
+	res, err := client(clientCert.tlsCertificate()).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected the handshake with a client certificate to succeed, got %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "spiffe://example.org/billing" {
+		t.Errorf("Expected the identity spiffe://example.org/billing, got %q", body)
+	}
+
+	if _, err := client().Get(srv.URL); err == nil {
+		t.Errorf("Expected the handshake without a client certificate to fail")
+	}
+}
+
+// TestReloaderReload checks the certificate is reloaded when its files change and that a failed reload
+// keeps the previous one.
+func TestReloaderReload(t *testing.T) {
+	dir := t.TempDir()
+	first := newTestCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "first"}}, nil)
+	certFile, keyFile := first.writeFiles(t, dir, "server")
+	var reloadErr error
+	reloader, err := NewReloader(Config{CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Minute}, func(err error) {
+		reloadErr = err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	reloader.now = func() time.Time { return now }
+	served := func() []byte {
+		return reloader.Current().Certificates[0].Certificate[0]
+	}
+
+	second := newTestCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "second"}}, nil)
+	second.writeFiles(t, dir, "server")
+	if !bytes.Equal(served(), first.cert.Raw) {
+		t.Errorf("Expected the files not to be checked before the reload interval")
+	}
+	now = now.Add(time.Minute)
+	if !bytes.Equal(served(), second.cert.Raw) {
+		t.Errorf("Expected the changed certificate to be reloaded")
+	}
+
+	writeFile(t, certFile, []byte("not a certificate"))
+	now = now.Add(time.Minute)
+	if !bytes.Equal(served(), second.cert.Raw) || reloadErr == nil {
+		t.Errorf("Expected a failed reload to keep the previous certificate and be reported")
+	}
+}
@@ -6,44 +6,98 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/lucastomic/msBaseProj/internal/contextypes"
 )
 
 var (
-	translations map[string]map[string]string
+	mu           sync.RWMutex
+	translations = make(map[string]map[string]string)
 	defaultLang  = "en"
 )
 
-func init() {
-	translations = make(map[string]map[string]string)
-	loadTranslations("en")
-	loadTranslations("es")
+// Load reads the translations of the given languages from the <lang>.json files in dir, replacing the ones
+// loaded before. Nothing is replaced if any of them can't be loaded.
+func Load(dir string, langs ...string) error {
+	loaded := make(map[string]map[string]string, len(langs))
+	for _, lang := range langs {
+		trans, err := loadTranslations(dir, lang)
+		if err != nil {
+			return err
+		}
+		loaded[lang] = trans
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	translations = loaded
+	return nil
 }
 
+// SetDefaultLang sets the language used when the requested one isn't loaded or lacks a key.
+func SetDefaultLang(lang string) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultLang = lang
+}
+
+// Languages returns the loaded translations by language, e.g. to check every language has the same keys.
+func Languages() map[string]map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	languages := make(map[string]map[string]string, len(translations))
+	for lang, trans := range translations {
+		languages[lang] = trans
+	}
+	return languages
+}
+
+// Translate returns the translation of key into lang, which can be an Accept-Language header value.
+// It falls back to the default language and then to the key itself.
 func Translate(lang string, key string) string {
-	if trans, ok := translations[lang]; ok {
+	mu.RLock()
+	defer mu.RUnlock()
+	if trans, ok := translations[resolveLang(lang)]; ok {
 		if val, ok := trans[key]; ok {
 			return val
 		}
 	}
+	if val, ok := translations[defaultLang][key]; ok {
+		return val
+	}
 	return key
 }
 
+// TranslateGivenCtx translates key into the language stored in ctx by the language middleware.
 func TranslateGivenCtx(ctx context.Context, key string) string {
-	lang := ctx.Value(contextypes.ContextLangKey{}).(string)
+	lang, _ := ctx.Value(contextypes.ContextLangKey{}).(string)
 	return Translate(lang, key)
 }
 
-func loadTranslations(lang string) {
-	filePath := filepath.Join("locales", fmt.Sprintf("%s.json", lang))
+// resolveLang returns the loaded language best matching lang: the first language of an Accept-Language
+// value, or its primary subtag if the exact one isn't loaded, e.g. "es" for "es-ES,es;q=0.9".
+// The caller must hold the lock.
+func resolveLang(lang string) string {
+	lang, _, _ = strings.Cut(lang, ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	lang = strings.TrimSpace(lang)
+	if _, ok := translations[lang]; ok {
+		return lang
+	}
+	primary, _, _ := strings.Cut(lang, "-")
+	return strings.ToLower(primary)
+}
+
+func loadTranslations(dir string, lang string) (map[string]string, error) {
+	filePath := filepath.Join(dir, fmt.Sprintf("%s.json", lang))
 	bytes, err := os.ReadFile(filePath)
 	if err != nil {
-		panic(fmt.Sprintf("error loading translation %s", lang))
+		return nil, fmt.Errorf("error loading translation %s: %w", lang, err)
 	}
 	var trans map[string]string
 	if err := json.Unmarshal(bytes, &trans); err != nil {
-		panic(fmt.Sprintf("error loading translation %s", lang))
+		return nil, fmt.Errorf("error loading translation %s: %w", lang, err)
 	}
-	translations[lang] = trans
+	return trans, nil
 }
@@ -0,0 +1,89 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DiskStorage stores files in a directory of the local disk, each one in a file named after its key.
+type DiskStorage struct {
+	dir string
+}
+
+// NewDiskStorage creates a DiskStorage keeping files in dir, which is created if it doesn't exist.
+func NewDiskStorage(dir string) (*DiskStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &DiskStorage{dir: dir}, nil
+}
+
+// Put implements Storage. The content is written to a temporary file which is renamed once complete,
+// so readers never see a partial file.
+func (d *DiskStorage) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open implements Storage.
+func (d *DiskStorage) Open(ctx context.Context, key string) (Object, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Object{}, notFound(key)
+	}
+	if err != nil {
+		return Object{}, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return Object{}, err
+	}
+	return Object{ReadSeekCloser: file, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete implements Storage.
+func (d *DiskStorage) Delete(ctx context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path returns the path of the file stored under key. Keys are single file names, so they can't
+// escape the directory.
+func (d *DiskStorage) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || strings.HasPrefix(key, ".upload-") || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(d.dir, key), nil
+}
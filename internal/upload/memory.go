@@ -0,0 +1,76 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps files in memory. It's meant for tests and small deployments, as files are lost on restart.
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	files map[string]memoryFile
+}
+
+// memoryFile is a file kept by MemoryStorage.
+type memoryFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemoryStorage creates an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: map[string]memoryFile{}}
+}
+
+// Put implements Storage.
+func (m *MemoryStorage) Put(ctx context.Context, key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[key] = memoryFile{data: data, modTime: time.Now()}
+	return nil
+}
+
+// Open implements Storage.
+func (m *MemoryStorage) Open(ctx context.Context, key string) (Object, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	file, ok := m.files[key]
+	if !ok {
+		return Object{}, notFound(key)
+	}
+	reader := nopCloser{bytes.NewReader(file.data)}
+	return Object{ReadSeekCloser: reader, Size: int64(len(file.data)), ModTime: file.modTime}, nil
+}
+
+// Delete implements Storage.
+func (m *MemoryStorage) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, key)
+	return nil
+}
+
+// Keys returns the keys of the files stored.
+func (m *MemoryStorage) Keys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.files))
+	for key := range m.files {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// nopCloser adds a no-op Close to a bytes.Reader.
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
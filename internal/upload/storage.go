@@ -0,0 +1,38 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// Storage is where uploaded files are kept. Implementations must be safe for concurrent use, so remote
+// stores, like an object storage bucket, can be plugged in instead of the local disk.
+type Storage interface {
+	// Put stores the content read from r under key, replacing any previous file. If it fails, like when
+	// reading r does, it must clean up what it wrote and leave any previous file under key untouched, as
+	// Receive doesn't delete the key of a failed upload.
+	Put(ctx context.Context, key string, r io.Reader) error
+
+	// Open opens the file stored under key for reading, failing with an error wrapping errs.ErrNotFound
+	// if there is none.
+	Open(ctx context.Context, key string) (Object, error)
+
+	// Delete removes the file stored under key. Deleting a missing file isn't an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Object is a stored file opened for reading. It must be closed once read.
+type Object struct {
+	io.ReadSeekCloser
+	Size    int64     // Size is the size of the file in bytes.
+	ModTime time.Time // ModTime is when the file was stored.
+}
+
+// notFound returns the error of opening a missing file.
+func notFound(key string) error {
+	return errs.NewI18NError("file not found: %w", fmt.Errorf("%q: %w", key, errs.ErrNotFound), "resourcenotfound")
+}
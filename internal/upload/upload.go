@@ -0,0 +1,275 @@
+package upload
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/lucastomic/msBaseProj/internal/codec"
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+const (
+	// DefaultMaxFileSize is the size in bytes of the largest file accepted by default.
+	DefaultMaxFileSize = 10 << 20
+	// DefaultMaxFiles is the number of files accepted in a request by default.
+	DefaultMaxFiles = 10
+	// DefaultMaxValueSize is the size in bytes of the largest form value accepted by default.
+	DefaultMaxValueSize = 64 << 10
+	// sniffLen is the number of bytes the content type of a file is detected from.
+	sniffLen = 512
+)
+
+// Options limits what an upload accepts. The route's body limit still applies on top, so upload routes
+// should raise their MaxBodyBytes and accept the multipart/form-data content type.
+type Options struct {
+	// MaxFileSize is the size in bytes of the largest file accepted. DefaultMaxFileSize when zero.
+	MaxFileSize int64
+	// MaxTotalSize is the size in bytes of all the files of a request together. Unlimited when zero.
+	MaxTotalSize int64
+	// MaxFiles is the number of files accepted in a request. DefaultMaxFiles when zero.
+	MaxFiles int
+	// MaxValueSize is the size in bytes of the largest form value accepted. DefaultMaxValueSize when zero.
+	MaxValueSize int64
+	// AllowedTypes are the media types accepted, like image/png, or wildcards like image/*. The type of
+	// a file is detected from its content, not trusted from the client. Any type is accepted when empty.
+	AllowedTypes []string
+	// Key returns the key a file is stored under. Random keys keeping the file's extension are used when nil.
+	Key func(file File) string
+}
+
+// File is a file received and stored.
+type File struct {
+	Field       string // Field is the name of the form field the file was sent in.
+	Filename    string // Filename is the name of the file on the client, without its directories.
+	Key         string // Key is the key the file was stored under.
+	ContentType string // ContentType is the media type detected from the content of the file.
+	Size        int64  // Size is the size of the file in bytes.
+	Checksum    string // Checksum is the hex encoded SHA-256 of the file.
+}
+
+// Form is a multipart form received.
+type Form struct {
+	Values url.Values // Values are the form's fields which aren't files.
+	Files  []File     // Files are the files received, in the order they were sent.
+}
+
+// Receive streams the files of a multipart/form-data request to storage as they're read, without
+// buffering them, computing their checksums and detecting their content types on the way.
+// If a file breaks a limit or its type isn't allowed, the files already stored are deleted and an
+// errs.I18nError is returned: wrapping errs.ErrPayloadTooLarge for the size limits, errs.ErrUnsupportedMediaType
+// for disallowed types and requests which aren't multipart, and errs.ErrInvalidInput for malformed bodies.
+// The returned error is meant to be passed straight to the controller's ParseError.
+func Receive(r *http.Request, storage Storage, opts Options) (Form, error) {
+	opts = opts.withDefaults()
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return Form{}, errs.NewI18NError("request isn't a multipart form: %w", errs.ErrUnsupportedMediaType, "unsupportedmediatype")
+	}
+
+	ctx := r.Context()
+	form := Form{Values: url.Values{}}
+	var total int64
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			discard(ctx, storage, form.Files)
+			return Form{}, readError(err)
+		}
+		if part.FileName() == "" {
+			value, err := readValue(part, opts.MaxValueSize)
+			part.Close()
+			if err != nil {
+				discard(ctx, storage, form.Files)
+				return Form{}, err
+			}
+			form.Values.Add(part.FormName(), value)
+			continue
+		}
+		if len(form.Files) == opts.MaxFiles {
+			part.Close()
+			discard(ctx, storage, form.Files)
+			return Form{}, errs.NewI18NError("too many files: %w", errs.ErrPayloadTooLarge, "toomanyfiles")
+		}
+		file, err := receiveFile(ctx, part, storage, opts, total)
+		part.Close()
+		if err != nil {
+			discard(ctx, storage, form.Files)
+			return Form{}, err
+		}
+		total += file.Size
+		form.Files = append(form.Files, file)
+	}
+}
+
+// withDefaults returns the options with the defaults of the unset limits.
+func (o Options) withDefaults() Options {
+	if o.MaxFileSize <= 0 {
+		o.MaxFileSize = DefaultMaxFileSize
+	}
+	if o.MaxFiles <= 0 {
+		o.MaxFiles = DefaultMaxFiles
+	}
+	if o.MaxValueSize <= 0 {
+		o.MaxValueSize = DefaultMaxValueSize
+	}
+	if o.Key == nil {
+		o.Key = randomKey
+	}
+	return o
+}
+
+// receiveFile streams a file part to storage. total is the size of the files received before it.
+func receiveFile(ctx context.Context, part *multipart.Part, storage Storage, opts Options, total int64) (File, error) {
+	buffered := bufio.NewReaderSize(part, sniffLen)
+	head, err := buffered.Peek(sniffLen)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return File{}, readError(err)
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !allowed(contentType, opts.AllowedTypes) {
+		return File{}, errs.NewI18NError("file type not allowed: %w", fmt.Errorf("%s: %w", contentType, errs.ErrUnsupportedMediaType), "filetypenotallowed")
+	}
+
+	file := File{
+		Field:       part.FormName(),
+		Filename:    path.Base(strings.ReplaceAll(part.FileName(), `\`, "/")),
+		ContentType: contentType,
+	}
+	file.Key = opts.Key(file)
+	limited := &limitedReader{r: buffered, hash: sha256.New(), fileLimit: opts.MaxFileSize, totalLimit: -1}
+	if opts.MaxTotalSize > 0 {
+		limited.totalLimit = opts.MaxTotalSize - total
+	}
+	// A failed Put cleans up after itself, and deleting the key would remove the file it may have replaced.
+	if err := storage.Put(ctx, file.Key, limited); err != nil {
+		var limitErr *limitError
+		var readErr *clientReadError
+		switch {
+		case errors.As(err, &limitErr):
+			return File{}, limitErr.err
+		case errors.As(err, &readErr):
+			return File{}, readError(readErr.err)
+		default:
+			return File{}, errs.NewI18NError("storing the upload: %w", errors.Join(errs.ErrinternalError, err), "internalerror")
+		}
+	}
+	file.Size = limited.n
+	file.Checksum = hex.EncodeToString(limited.hash.Sum(nil))
+	return file, nil
+}
+
+// limitedReader reads a file, hashing it and failing with a *limitError once it exceeds a limit.
+// Errors reading the request are wrapped in a *clientReadError, telling them apart from the storage's.
+type limitedReader struct {
+	r          io.Reader
+	hash       hash.Hash
+	n          int64 // n is the number of bytes read.
+	fileLimit  int64
+	totalLimit int64 // totalLimit is what remains of the total size, or -1 when unlimited.
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	l.hash.Write(p[:n])
+	switch {
+	case l.n > l.fileLimit:
+		return n, &limitError{errs.NewI18NError("file too large: %w", errs.ErrPayloadTooLarge, "filetoolarge")}
+	case l.totalLimit >= 0 && l.n > l.totalLimit:
+		return n, &limitError{errs.NewI18NError("upload too large: %w", errs.ErrPayloadTooLarge, "uploadtoolarge")}
+	case err != nil && err != io.EOF:
+		return n, &clientReadError{err}
+	}
+	return n, err
+}
+
+// limitError is the error of a file exceeding a limit, carrying the error returned to the client.
+type limitError struct {
+	err error
+}
+
+func (e *limitError) Error() string { return e.err.Error() }
+
+// clientReadError is an error reading the request.
+type clientReadError struct {
+	err error
+}
+
+func (e *clientReadError) Error() string { return e.err.Error() }
+
+func (e *clientReadError) Unwrap() error { return e.err }
+
+// readValue reads a form value of at most maxSize bytes.
+func readValue(part io.Reader, maxSize int64) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxSize+1))
+	if err != nil {
+		return "", readError(err)
+	}
+	if int64(len(value)) > maxSize {
+		return "", errs.NewI18NError("form value too large: %w", errs.ErrPayloadTooLarge, "payloadtoolarge")
+	}
+	return string(value), nil
+}
+
+// readError maps an error reading the request to an errs.I18nError, reporting bodies cut off by the
+// server's body limit as too large and anything else as malformed.
+func readError(err error) error {
+	maxBytesErr := &http.MaxBytesError{}
+	if errors.As(err, &maxBytesErr) {
+		return errs.NewI18NError("request body too large: %w", errs.ErrPayloadTooLarge, "payloadtoolarge")
+	}
+	return errs.NewI18NError("malformed multipart body: %w", errors.Join(errs.ErrInvalidInput, err), "malformedmultipart")
+}
+
+// allowed reports whether contentType matches one of the allowed types, or any is allowed.
+func allowed(contentType string, allowedTypes []string) bool {
+	if len(allowedTypes) == 0 {
+		return true
+	}
+	for _, pattern := range allowedTypes {
+		if codec.MatchMediaType(pattern, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// discard deletes the files stored, once the upload failed. It outlives the request, whose failure
+// may have been its cancellation.
+func discard(ctx context.Context, storage Storage, files []File) {
+	ctx = context.WithoutCancel(ctx)
+	for _, file := range files {
+		_ = storage.Delete(ctx, file.Key)
+	}
+}
+
+// extension matches the extensions kept by random keys.
+var extension = regexp.MustCompile(`^\.[A-Za-z0-9]{1,10}$`)
+
+// randomKey returns a random key keeping the extension of the file, if it looks like one.
+func randomKey(file File) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	key := hex.EncodeToString(b)
+	if ext := path.Ext(file.Filename); extension.MatchString(ext) {
+		key += strings.ToLower(ext)
+	}
+	return key
+}
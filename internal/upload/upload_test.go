@@ -0,0 +1,171 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lucastomic/msBaseProj/internal/errs"
+)
+
+// png is the start of a PNG file, enough for its type to be detected.
+var png = "\x89PNG\r\n\x1a\n" + strings.Repeat("x", 100)
+
+// part is a part of a multipart form; files have a filename.
+type part struct {
+	field, filename, content string
+}
+
+// newRequest builds a multipart/form-data request with the given parts.
+func newRequest(parts ...part) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, p := range parts {
+		var w io.Writer
+		if p.filename != "" {
+			w, _ = writer.CreateFormFile(p.field, p.filename)
+		} else {
+			w, _ = writer.CreateFormField(p.field)
+		}
+		io.WriteString(w, p.content)
+	}
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// TestReceive checks that files are stored with their detected type and checksum, along with the form values.
+func TestReceive(t *testing.T) {
+	storage := NewMemoryStorage()
+	form, err := Receive(newRequest(
+		part{field: "title", content: "holidays"},
+		part{field: "photo", filename: `C:\photos\Beach.PNG`, content: png},
+		part{field: "notes", filename: "notes.txt", content: "sunny"},
+	), storage, Options{AllowedTypes: []string{"image/*", "text/plain"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Values.Get("title") != "holidays" || len(form.Files) != 2 {
+		t.Fatalf("Expected a value and 2 files, got %+v", form)
+	}
+	photo := form.Files[0]
+	sum := sha256.Sum256([]byte(png))
+	if photo.Field != "photo" || photo.Filename != "Beach.PNG" || photo.ContentType != "image/png" ||
+		photo.Size != int64(len(png)) || photo.Checksum != hex.EncodeToString(sum[:]) || !strings.HasSuffix(photo.Key, ".png") {
+		t.Errorf("Unexpected file %+v", photo)
+	}
+	object, err := storage.Open(context.Background(), photo.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer object.Close()
+	if content, _ := io.ReadAll(object); string(content) != png || object.Size != int64(len(png)) {
+		t.Errorf("Expected the stored file to hold the upload, got %q", content)
+	}
+}
+
+// TestReceiveViolations checks that uploads breaking a limit fail with the matching error, leaving no file behind.
+func TestReceiveViolations(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *http.Request
+		opts Options
+		code string
+		err  error
+	}{
+		{"file too large", newRequest(part{field: "a", filename: "a.txt", content: "123456"}), Options{MaxFileSize: 5}, "filetoolarge", errs.ErrPayloadTooLarge},
+		{"upload too large", newRequest(
+			part{field: "a", filename: "a.txt", content: "1234"},
+			part{field: "b", filename: "b.txt", content: "1234"},
+		), Options{MaxTotalSize: 6}, "uploadtoolarge", errs.ErrPayloadTooLarge},
+		{"too many files", newRequest(
+			part{field: "a", filename: "a.txt", content: "1"},
+			part{field: "b", filename: "b.txt", content: "2"},
+		), Options{MaxFiles: 1}, "toomanyfiles", errs.ErrPayloadTooLarge},
+		{"type not allowed", newRequest(
+			part{field: "a", filename: "a.png", content: png},
+			part{field: "b", filename: "fake.png", content: "not an image"},
+		), Options{AllowedTypes: []string{"image/png"}}, "filetypenotallowed", errs.ErrUnsupportedMediaType},
+		{"value too large", newRequest(part{field: "a", content: "123456"}), Options{MaxValueSize: 5}, "payloadtoolarge", errs.ErrPayloadTooLarge},
+		{"not multipart", httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("{}")), Options{}, "unsupportedmediatype", errs.ErrUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		storage := NewMemoryStorage()
+		_, err := Receive(tt.req, storage, tt.opts)
+		i18n := &errs.I18nError{}
+		if !errors.As(err, i18n) || i18n.Code != tt.code || !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected the error %s, got %v", tt.name, tt.code, err)
+		}
+		if keys := storage.Keys(); len(keys) != 0 {
+			t.Errorf("%s: expected no file to be left behind, got %v", tt.name, keys)
+		}
+	}
+}
+
+// TestReceiveKeepsReplacedFile checks that a failed upload doesn't delete the file stored under its key before.
+func TestReceiveKeepsReplacedFile(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.Put(context.Background(), "report.txt", strings.NewReader("v1"))
+	byName := func(file File) string { return file.Filename }
+	_, err := Receive(newRequest(part{field: "a", filename: "report.txt", content: "123456"}), storage, Options{MaxFileSize: 5, Key: byName})
+	if !errors.Is(err, errs.ErrPayloadTooLarge) {
+		t.Fatalf("Expected the upload to be too large, got %v", err)
+	}
+	object, err := storage.Open(context.Background(), "report.txt")
+	if err != nil {
+		t.Fatalf("Expected the previous file to be kept, got %v", err)
+	}
+	defer object.Close()
+	if content, _ := io.ReadAll(object); string(content) != "v1" {
+		t.Errorf("Expected the previous content, got %q", content)
+	}
+}
+
+// TestDiskStorage checks that files are stored on disk, and that keys can't escape its directory.
+func TestDiskStorage(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(ctx, "a.txt", strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+	object, err := storage.Open(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(object)
+	object.Close()
+	if string(content) != "hello" || object.Size != 5 {
+		t.Errorf("Expected the stored content, got %q", content)
+	}
+	if err := storage.Put(ctx, "../escape", strings.NewReader("x")); err == nil {
+		t.Errorf("Expected keys with separators to be rejected")
+	}
+	if err := storage.Put(ctx, "b.txt", io.MultiReader(strings.NewReader("partial"), errReader{})); err == nil {
+		t.Errorf("Expected the failed read to be reported")
+	}
+	if err := storage.Delete(ctx, "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a.txt", "b.txt"} {
+		if _, err := storage.Open(ctx, key); !errors.Is(err, errs.ErrNotFound) {
+			t.Errorf("Expected %s not to be found, got %v", key, err)
+		}
+	}
+}
+
+// errReader is a reader which always fails.
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
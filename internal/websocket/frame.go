@@ -0,0 +1,221 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"sync"
+	"unicode/utf8"
+)
+
+// Opcodes of the frames, as defined by RFC 6455, section 5.2.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+const (
+	// maxControlPayload is the size of the largest payload of a control frame.
+	maxControlPayload = 125
+	// compressionThreshold is the size of the smallest message compressed, as smaller ones don't
+	// make up for the overhead.
+	compressionThreshold = 128
+	// maxWindow is the size of the deflate window, the data later messages can refer to.
+	maxWindow = 32 << 10
+)
+
+// deflateTail ends the payload of a compressed message before it's inflated: the empty stored block
+// stripped by the sender, followed by a final one so the reader ends cleanly.
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// frame is a WebSocket frame.
+type frame struct {
+	fin     bool
+	rsv1    bool // rsv1 marks the first frame of a compressed message.
+	opcode  int
+	payload []byte
+}
+
+// readFrame reads a frame sent by a client, unmasking its payload. Frames breaking the protocol, or whose
+// payload is larger than maxSize, are reported as a *CloseError with the status code to close with.
+// The rsv1 bit is only allowed if compressed is true.
+func readFrame(r *bufio.Reader, compressed bool, maxSize int64) (frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{fin: header[0]&0x80 != 0, rsv1: header[0]&0x40 != 0, opcode: int(header[0] & 0x0F)}
+	control := f.opcode >= opClose
+	switch {
+	case header[0]&0x30 != 0:
+		return frame{}, protocolError("reserved bits set")
+	case f.rsv1 && (!compressed || control || f.opcode == opContinuation):
+		return frame{}, protocolError("unexpected compressed frame")
+	case f.opcode > opBinary && f.opcode < opClose, f.opcode > opPong:
+		return frame{}, protocolError("unknown opcode")
+	case header[1]&0x80 == 0:
+		return frame{}, protocolError("unmasked client frame")
+	}
+
+	length := int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return frame{}, err
+		}
+		length = int64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return frame{}, err
+		}
+		if extended[0]&0x80 != 0 {
+			return frame{}, protocolError("invalid payload length")
+		}
+		length = int64(binary.BigEndian.Uint64(extended[:]))
+	}
+	if control && (!f.fin || length > maxControlPayload) {
+		return frame{}, protocolError("invalid control frame")
+	}
+	if length > maxSize {
+		return frame{}, &CloseError{Code: StatusMessageTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return frame{}, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return frame{}, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// encode returns the frame as the server sends it, unmasked.
+func (f frame) encode() []byte {
+	b := make([]byte, 0, len(f.payload)+10)
+	first := byte(f.opcode)
+	if f.fin {
+		first |= 0x80
+	}
+	if f.rsv1 {
+		first |= 0x40
+	}
+	b = append(b, first)
+	switch length := len(f.payload); {
+	case length < 126:
+		b = append(b, byte(length))
+	case length <= 0xFFFF:
+		b = append(b, 126)
+		b = binary.BigEndian.AppendUint16(b, uint16(length))
+	default:
+		b = append(b, 127)
+		b = binary.BigEndian.AppendUint64(b, uint64(length))
+	}
+	return append(b, f.payload...)
+}
+
+// parseClose returns the *CloseError described by the payload of a close frame.
+func parseClose(payload []byte) error {
+	if len(payload) == 0 {
+		return &CloseError{Code: StatusNoStatusReceived}
+	}
+	if len(payload) == 1 {
+		return protocolError("invalid close frame")
+	}
+	code := StatusCode(binary.BigEndian.Uint16(payload))
+	if !validCloseCode(code) {
+		return protocolError("invalid close status code")
+	}
+	if !utf8.Valid(payload[2:]) {
+		return &CloseError{Code: StatusInvalidPayloadData, Reason: "invalid UTF-8 close reason"}
+	}
+	return &CloseError{Code: code, Reason: string(payload[2:])}
+}
+
+// validCloseCode reports whether code can be sent in a close frame: the ones defined by RFC 6455 and
+// registered with IANA, or the ones reserved for libraries and applications.
+func validCloseCode(code StatusCode) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	default:
+		return code >= 3000 && code <= 4999
+	}
+}
+
+// protocolError returns the *CloseError closing the connection because the client broke the protocol.
+func protocolError(reason string) error {
+	return &CloseError{Code: StatusProtocolError, Reason: reason}
+}
+
+// validUTF8 reports whether the payload of a text message is valid UTF-8.
+func validUTF8(data []byte) bool {
+	return utf8.Valid(data)
+}
+
+// deflate implements the permessage-deflate extension of RFC 7692. The server compresses every message
+// on its own, and the client may compress them referring to the previous ones unless it agreed not to.
+type deflate struct {
+	contextTakeover bool   // contextTakeover reports whether the client's messages can refer to the previous ones.
+	window          []byte // window holds the end of the previous messages, when contextTakeover is true.
+
+	mu     sync.Mutex // mu serializes the compression of the messages written.
+	writer *flate.Writer
+	buf    bytes.Buffer
+}
+
+// newDeflate creates the extension's state for a connection.
+func newDeflate(contextTakeover bool) *deflate {
+	writer, _ := flate.NewWriter(nil, flate.DefaultCompression)
+	return &deflate{contextTakeover: contextTakeover, writer: writer}
+}
+
+// compress returns the payload of the compressed message holding data.
+func (d *deflate) compress(data []byte) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.buf.Reset()
+	d.writer.Reset(&d.buf)
+	if _, err := d.writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := d.writer.Flush(); err != nil {
+		return nil, err
+	}
+	// The flush ends the data with an empty stored block, which the receiver adds back.
+	compressed := bytes.TrimSuffix(d.buf.Bytes(), deflateTail[:4])
+	return append([]byte(nil), compressed...), nil
+}
+
+// decompress returns the message held by the compressed payload, failing with a *CloseError if it's
+// invalid or larger than maxSize once decompressed. It's only called by the read loop.
+func (d *deflate) decompress(payload []byte, maxSize int64) ([]byte, error) {
+	reader := flate.NewReaderDict(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail)), d.window)
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, &CloseError{Code: StatusInvalidPayloadData, Reason: "invalid compressed message"}
+	}
+	if int64(len(data)) > maxSize {
+		return nil, &CloseError{Code: StatusMessageTooBig, Reason: "message too big"}
+	}
+	if d.contextTakeover {
+		d.window = append(d.window, data...)
+		if len(d.window) > maxWindow {
+			d.window = append([]byte(nil), d.window[len(d.window)-maxWindow:]...)
+		}
+	}
+	return data, nil
+}
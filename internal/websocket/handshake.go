@@ -0,0 +1,196 @@
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// acceptGUID is appended to the client's key to compute the Sec-WebSocket-Accept header.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// HandshakeError is the error returned by Upgrade when the request can't be upgraded, before anything
+// was written to the response, so it can be answered with Status.
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+// HandshakeStatus returns the status code the request should be answered with if err is a *HandshakeError,
+// or 500.
+func HandshakeStatus(err error) int {
+	var handshakeErr *HandshakeError
+	if errors.As(err, &handshakeErr) {
+		return handshakeErr.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// Upgrade performs the opening handshake of RFC 6455 on a request and takes over its connection, which is
+// returned as a Conn configured by endpoint. The headers set on w so far, like ones set by middlewares,
+// are sent with the handshake response. The request must come over HTTP/1.1.
+func Upgrade(w http.ResponseWriter, r *http.Request, endpoint Endpoint) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, &HandshakeError{http.StatusMethodNotAllowed, "the handshake must be a GET request"}
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		return nil, &HandshakeError{http.StatusUpgradeRequired, "the request isn't a WebSocket upgrade"}
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, &HandshakeError{http.StatusUpgradeRequired, "unsupported WebSocket version"}
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, &HandshakeError{http.StatusBadRequest, "invalid Sec-WebSocket-Key"}
+	}
+	checkOrigin := endpoint.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, &HandshakeError{http.StatusForbidden, "origin not allowed"}
+	}
+	subprotocol := selectSubprotocol(r.Header, endpoint.Subprotocols)
+	var extension string
+	var compression *deflate
+	if endpoint.Compression {
+		extension, compression = negotiateDeflate(r.Header)
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, &HandshakeError{http.StatusInternalServerError, "the connection can't be taken over: " + err.Error()}
+	}
+	// The deadlines set by the server for the request no longer apply to the connection.
+	_ = netConn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	fmt.Fprintf(&b, "Sec-WebSocket-Accept: %s\r\n", acceptKey(key))
+	if subprotocol != "" {
+		fmt.Fprintf(&b, "Sec-WebSocket-Protocol: %s\r\n", subprotocol)
+	}
+	if extension != "" {
+		fmt.Fprintf(&b, "Sec-WebSocket-Extensions: %s\r\n", extension)
+	}
+	for name, values := range w.Header() {
+		for _, value := range values {
+			fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+		}
+	}
+	b.WriteString("\r\n")
+	_ = netConn.SetWriteDeadline(time.Now().Add(DefaultWriteTimeout))
+	if _, err := netConn.Write([]byte(b.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return newConn(r.Context(), netConn, rw.Reader, endpoint, subprotocol, compression), nil
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value proving the server understood the handshake with key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// sameOrigin reports whether the request comes from a page of the same host, or from a client which isn't
+// a browser, as those don't send an Origin header. Browsers don't apply CORS to WebSockets, so this keeps
+// other sites from connecting with their visitors' cookies.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// selectSubprotocol returns the first subprotocol offered by the client which is supported.
+func selectSubprotocol(header http.Header, supported []string) string {
+	for _, offered := range headerTokens(header, "Sec-WebSocket-Protocol") {
+		if slices.Contains(supported, offered) {
+			return offered
+		}
+	}
+	return ""
+}
+
+// negotiateDeflate accepts the first permessage-deflate offer of the client the server can honour,
+// returning the extension's response and state. The server never keeps the context between its messages,
+// and only decompresses the client's ones as independent if the client offered so. Offers restricting
+// the server's window below the 32KB compress/flate always uses are declined.
+func negotiateDeflate(header http.Header) (string, *deflate) {
+	for _, offer := range headerTokens(header, "Sec-WebSocket-Extensions") {
+		name, params, _ := strings.Cut(offer, ";")
+		if strings.TrimSpace(name) != "permessage-deflate" {
+			continue
+		}
+		accepted, clientNoContextTakeover := true, false
+		seen := map[string]bool{}
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			if key == "" {
+				continue
+			}
+			if seen[key] {
+				accepted = false
+			}
+			seen[key] = true
+			switch key {
+			case "server_no_context_takeover":
+			case "client_no_context_takeover":
+				clientNoContextTakeover = true
+			case "server_max_window_bits":
+				accepted = accepted && value == "15"
+			case "client_max_window_bits":
+			default:
+				accepted = false
+			}
+		}
+		if !accepted {
+			continue
+		}
+		response := "permessage-deflate; server_no_context_takeover"
+		if clientNoContextTakeover {
+			response += "; client_no_context_takeover"
+		}
+		return response, newDeflate(!clientNoContextTakeover)
+	}
+	return "", nil
+}
+
+// headerContains reports whether the comma-separated header name holds token, ignoring case.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range headerTokens(header, name) {
+		if strings.EqualFold(value, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerTokens returns the comma-separated values of every header name.
+func headerTokens(header http.Header, name string) []string {
+	var tokens []string
+	for _, value := range header.Values(name) {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
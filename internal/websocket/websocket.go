@@ -0,0 +1,360 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxMessageSize is the size in bytes of the largest message read by default, once decompressed.
+	DefaultMaxMessageSize = 1 << 20
+	// DefaultPingInterval is how often the server pings idle clients by default.
+	DefaultPingInterval = 30 * time.Second
+	// DefaultWriteTimeout is the time allowed to write a message by default.
+	DefaultWriteTimeout = 10 * time.Second
+	// closeTimeout is how long the closing handshake waits for the client's close frame.
+	closeTimeout = time.Second
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+const (
+	// TextMessage is a message holding UTF-8 text, like JSON.
+	TextMessage MessageType = opText
+	// BinaryMessage is a message holding arbitrary bytes.
+	BinaryMessage MessageType = opBinary
+)
+
+// StatusCode is the code of a close frame, telling why the connection is closed.
+type StatusCode int
+
+// Status codes defined by RFC 6455, section 7.4.1.
+const (
+	StatusNormalClosure      StatusCode = 1000
+	StatusGoingAway          StatusCode = 1001
+	StatusProtocolError      StatusCode = 1002
+	StatusUnsupportedData    StatusCode = 1003
+	StatusNoStatusReceived   StatusCode = 1005 // StatusNoStatusReceived is reported for close frames without a code. It's never sent.
+	StatusAbnormalClosure    StatusCode = 1006 // StatusAbnormalClosure is reported for connections lost without a close frame. It's never sent.
+	StatusInvalidPayloadData StatusCode = 1007
+	StatusPolicyViolation    StatusCode = 1008
+	StatusMessageTooBig      StatusCode = 1009
+	StatusMandatoryExtension StatusCode = 1010
+	StatusInternalError      StatusCode = 1011
+)
+
+// CloseError is the error returned by reads once the connection is closed, holding the status code and
+// reason of the close frame.
+type CloseError struct {
+	Code   StatusCode
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: closed with status %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: closed with status %d: %s", e.Code, e.Reason)
+}
+
+// CloseStatus returns the status code the connection was closed with if err is a *CloseError, or -1.
+func CloseStatus(err error) StatusCode {
+	var closeErr *CloseError
+	if errors.As(err, &closeErr) {
+		return closeErr.Code
+	}
+	return -1
+}
+
+// Handler handles a WebSocket connection. The request's context carries the values stored by the middlewares,
+// like the authenticated principal and the request ID, and is canceled once the connection is closed.
+// The connection is closed with StatusNormalClosure when the handler returns, if it's still open.
+type Handler func(conn *Conn, r *http.Request)
+
+// Endpoint describes a WebSocket endpoint: its handler and how connections are negotiated and kept.
+type Endpoint struct {
+	Handler Handler // Handler handles every connection.
+	// Subprotocols are the subprotocols supported, in order of preference. The first one offered by the
+	// client is selected; none is when the client offers none of them.
+	Subprotocols []string
+	// CheckOrigin reports whether a handshake with the given Origin header is accepted. When nil, only
+	// requests from the same host or without an Origin header, i.e. from non-browser clients, are.
+	CheckOrigin func(r *http.Request) bool
+	// MaxMessageSize is the size in bytes of the largest message read, once decompressed.
+	// Larger messages close the connection with StatusMessageTooBig. DefaultMaxMessageSize when zero.
+	MaxMessageSize int64
+	// Compression enables the permessage-deflate extension when clients offer it.
+	Compression bool
+	// PingInterval is how often the client is pinged. Clients which don't answer, or send anything,
+	// within twice the interval are disconnected. DefaultPingInterval when zero, disabled when negative.
+	PingInterval time.Duration
+	// WriteTimeout is the time allowed to write a message. DefaultWriteTimeout when zero.
+	WriteTimeout time.Duration
+}
+
+// message is a data message read, or the error which ended the reads.
+type message struct {
+	typ  MessageType
+	data []byte
+	err  error
+}
+
+// Conn is a WebSocket connection on the server side. Reads and writes can be made from different goroutines,
+// and writes from several ones at once.
+type Conn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	subprotocol string
+	deflate     *deflate // deflate compresses and decompresses messages, if the extension was negotiated.
+	maxSize     int64
+	pingEvery   time.Duration
+	writeWait   time.Duration
+
+	messages chan message // messages are the data messages read by the read loop.
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	writeMu   sync.Mutex // writeMu serializes the writes of frames.
+	closeOnce sync.Once  // closeOnce ensures a single close frame is sent.
+	closeSent chan struct{}
+	done      chan struct{} // done is closed once the read loop ends.
+	err       error         // err is the error which ended the read loop, set before done is closed.
+}
+
+// newConn creates the Conn over the hijacked connection and starts its read and ping loops,
+// which end with the connection.
+func newConn(ctx context.Context, conn net.Conn, reader *bufio.Reader, endpoint Endpoint, subprotocol string, deflate *deflate) *Conn {
+	c := &Conn{
+		conn:        conn,
+		reader:      reader,
+		subprotocol: subprotocol,
+		deflate:     deflate,
+		maxSize:     endpoint.MaxMessageSize,
+		pingEvery:   endpoint.PingInterval,
+		writeWait:   endpoint.WriteTimeout,
+		messages:    make(chan message),
+		closeSent:   make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if c.maxSize <= 0 {
+		c.maxSize = DefaultMaxMessageSize
+	}
+	if c.pingEvery == 0 {
+		c.pingEvery = DefaultPingInterval
+	}
+	if c.writeWait <= 0 {
+		c.writeWait = DefaultWriteTimeout
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	go c.readLoop()
+	if c.pingEvery > 0 {
+		go c.pingLoop()
+	}
+	return c
+}
+
+// Context returns a context canceled once the connection is closed.
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+// Subprotocol returns the subprotocol negotiated, if any.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// ReadMessage returns the next data message. Control frames are handled by the connection.
+// Once the connection is closed it returns a *CloseError, or the error which broke the connection.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	select {
+	case msg := <-c.messages:
+		return msg.typ, msg.data, msg.err
+	case <-c.done:
+		return 0, nil, c.err
+	}
+}
+
+// WriteMessage sends a data message. Text messages must be valid UTF-8.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", typ)
+	}
+	select {
+	case <-c.closeSent:
+		return net.ErrClosed
+	default:
+	}
+	rsv1 := false
+	if c.deflate != nil && len(data) >= compressionThreshold {
+		compressed, err := c.deflate.compress(data)
+		if err != nil {
+			return err
+		}
+		data, rsv1 = compressed, true
+	}
+	return c.writeFrame(frame{fin: true, rsv1: rsv1, opcode: int(typ), payload: data})
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v. Binary messages are rejected,
+// and so are messages which aren't valid JSON, leaving the connection open.
+func (c *Conn) ReadJSON(v any) error {
+	typ, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	if typ != TextMessage {
+		return errors.New("websocket: expected a text message holding JSON")
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteJSON sends v encoded as JSON in a text message.
+func (c *Conn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// Close closes the connection with the given status code and reason: it sends a close frame, waits a moment
+// for the client to answer with its own and closes the underlying connection.
+func (c *Conn) Close(code StatusCode, reason string) error {
+	err := c.sendClose(code, reason)
+	select {
+	case <-c.done:
+	case <-time.After(closeTimeout):
+	}
+	c.conn.Close()
+	<-c.done
+	return err
+}
+
+// sendClose sends the close frame, unless one was sent already.
+func (c *Conn) sendClose(code StatusCode, reason string) error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		payload := []byte{byte(code >> 8), byte(code)}
+		if len(reason) > maxControlPayload-2 {
+			reason = reason[:maxControlPayload-2]
+		}
+		err = c.writeFrame(frame{fin: true, opcode: opClose, payload: append(payload, reason...)})
+		close(c.closeSent)
+	})
+	return err
+}
+
+// readLoop reads frames until the connection is closed, answering control frames and passing data messages
+// to ReadMessage. Protocol violations close the connection with the status code the RFC mandates.
+func (c *Conn) readLoop() {
+	defer close(c.done)
+	defer c.cancel()
+	err := c.read()
+	var closeErr *CloseError
+	if errors.As(err, &closeErr) {
+		if closeErr.Code != StatusNoStatusReceived && closeErr.Code != StatusAbnormalClosure {
+			_ = c.sendClose(closeErr.Code, closeErr.Reason)
+		} else {
+			_ = c.sendClose(StatusNormalClosure, "")
+		}
+	} else {
+		err = &CloseError{Code: StatusAbnormalClosure, Reason: err.Error()}
+	}
+	c.conn.Close()
+	c.err = err
+}
+
+// read reads frames until the client closes the connection or breaks the protocol, returning the
+// resulting *CloseError, or until the connection fails, returning its error.
+func (c *Conn) read() error {
+	var (
+		typ        MessageType
+		data       []byte
+		compressed bool
+		fragmented bool
+	)
+	for {
+		if c.pingEvery > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(2 * c.pingEvery))
+		}
+		f, err := readFrame(c.reader, c.deflate != nil, c.maxSize)
+		if err != nil {
+			return err
+		}
+		switch f.opcode {
+		case opPing:
+			if err := c.writeFrame(frame{fin: true, opcode: opPong, payload: f.payload}); err != nil {
+				return err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return parseClose(f.payload)
+		case opContinuation:
+			if !fragmented {
+				return &CloseError{Code: StatusProtocolError, Reason: "unexpected continuation frame"}
+			}
+		default:
+			if fragmented {
+				return &CloseError{Code: StatusProtocolError, Reason: "expected a continuation frame"}
+			}
+			typ, data, compressed, fragmented = MessageType(f.opcode), nil, f.rsv1, true
+		}
+		if int64(len(data))+int64(len(f.payload)) > c.maxSize {
+			return &CloseError{Code: StatusMessageTooBig, Reason: "message too big"}
+		}
+		data = append(data, f.payload...)
+		if !f.fin {
+			continue
+		}
+		fragmented = false
+
+		if compressed {
+			if data, err = c.deflate.decompress(data, c.maxSize); err != nil {
+				return err
+			}
+		}
+		if typ == TextMessage && !validUTF8(data) {
+			return &CloseError{Code: StatusInvalidPayloadData, Reason: "invalid UTF-8 text"}
+		}
+		select {
+		case c.messages <- message{typ: typ, data: data}:
+		case <-c.closeSent:
+			// Nobody reads messages once the server started closing, so it only waits for the client's close frame.
+		}
+	}
+}
+
+// pingLoop pings the client every pingEvery until the connection is closed.
+func (c *Conn) pingLoop() {
+	ticker := time.NewTicker(c.pingEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(frame{fin: true, opcode: opPing}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeFrame writes f within the write timeout.
+func (c *Conn) writeFrame(f frame) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeWait))
+	_, err := c.conn.Write(f.encode())
+	return err
+}
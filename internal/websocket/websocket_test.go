@@ -0,0 +1,327 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient is a minimal WebSocket client sending the frames given as they are, so tests can break the protocol.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newTestServer serves endpoint, closing every connection once its handler returns.
+func newTestServer(t *testing.T, endpoint Endpoint) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, endpoint)
+		if err != nil {
+			http.Error(w, err.Error(), HandshakeStatus(err))
+			return
+		}
+		defer conn.Close(StatusNormalClosure, "")
+		endpoint.Handler(conn, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// echo sends back every message received.
+func echo(conn *Conn, r *http.Request) {
+	for {
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := conn.WriteMessage(typ, data); err != nil {
+			return
+		}
+	}
+}
+
+// dial sends the opening handshake with the given extra headers and returns the client and the response.
+func dial(t *testing.T, ts *httptest.Server, headers map[string]string) (*testClient, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	request := "GET / HTTP/1.1\r\nHost: " + ts.Listener.Addr().String() + "\r\n"
+	all := map[string]string{
+		"Upgrade":               "websocket",
+		"Connection":            "keep-alive, Upgrade",
+		"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+		"Sec-WebSocket-Version": "13",
+	}
+	for name, value := range headers {
+		all[name] = value
+	}
+	for name, value := range all {
+		if value != "" {
+			request += name + ": " + value + "\r\n"
+		}
+	}
+	if _, err := conn.Write([]byte(request + "\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{t, conn, reader}, res
+}
+
+// send writes a masked frame.
+func (c *testClient) send(fin, rsv1 bool, opcode int, payload []byte) {
+	c.sendRaw(fin, rsv1, opcode, payload, true)
+}
+
+// sendRaw writes a frame, masking it if masked is true.
+func (c *testClient) sendRaw(fin, rsv1 bool, opcode int, payload []byte, masked bool) {
+	c.t.Helper()
+	first := byte(opcode)
+	if fin {
+		first |= 0x80
+	}
+	if rsv1 {
+		first |= 0x40
+	}
+	b := []byte{first}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		b = append(b, maskBit|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		b = append(b, maskBit|126)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	default:
+		b = append(b, maskBit|127)
+		b = binary.BigEndian.AppendUint64(b, uint64(len(payload)))
+	}
+	if masked {
+		mask := []byte{0x12, 0x34, 0x56, 0x78}
+		b = append(b, mask...)
+		for i, p := range payload {
+			b = append(b, p^mask[i%4])
+		}
+	} else {
+		b = append(b, payload...)
+	}
+	if _, err := c.conn.Write(b); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// receive reads a frame sent by the server.
+func (c *testClient) receive() (rsv1 bool, opcode int, payload []byte) {
+	c.t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	length := int(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		io.ReadFull(c.reader, extended[:])
+		length = int(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		io.ReadFull(c.reader, extended[:])
+		length = int(binary.BigEndian.Uint64(extended[:]))
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return header[0]&0x40 != 0, int(header[0] & 0x0F), payload
+}
+
+// expectClose reads frames until a close frame and checks its status code.
+func (c *testClient) expectClose(code StatusCode) {
+	c.t.Helper()
+	for {
+		_, opcode, payload := c.receive()
+		if opcode != opClose {
+			continue
+		}
+		if got := StatusCode(binary.BigEndian.Uint16(payload)); got != code {
+			c.t.Errorf("Expected the connection to be closed with %d, got %d (%s)", code, got, payload[2:])
+		}
+		return
+	}
+}
+
+// closePayload returns the payload of a close frame with the given code.
+func closePayload(code StatusCode) []byte {
+	return binary.BigEndian.AppendUint16(nil, uint16(code))
+}
+
+// TestHandshake checks the handshake response and the requests which are refused.
+func TestHandshake(t *testing.T) {
+	ts := newTestServer(t, Endpoint{Handler: echo, Subprotocols: []string{"chat.v2", "chat.v1"}})
+	_, res := dial(t, ts, map[string]string{"Sec-WebSocket-Protocol": "chat.v1, chat.v2"})
+	if res.StatusCode != http.StatusSwitchingProtocols ||
+		res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" ||
+		res.Header.Get("Sec-WebSocket-Protocol") != "chat.v1" {
+		t.Errorf("Expected the handshake to be accepted with chat.v1, got %v %v", res.Status, res.Header)
+	}
+
+	for name, test := range map[string]struct {
+		headers  map[string]string
+		expected int
+	}{
+		"not an upgrade":  {map[string]string{"Upgrade": ""}, http.StatusUpgradeRequired},
+		"old version":     {map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		"invalid key":     {map[string]string{"Sec-WebSocket-Key": "c2hvcnQ="}, http.StatusBadRequest},
+		"other origin":    {map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		"same origin":     {map[string]string{"Origin": "http://" + ts.Listener.Addr().String()}, http.StatusSwitchingProtocols},
+		"no subprotocols": {map[string]string{"Sec-WebSocket-Protocol": "mqtt"}, http.StatusSwitchingProtocols},
+	} {
+		if _, res := dial(t, ts, test.headers); res.StatusCode != test.expected {
+			t.Errorf("%s: expected %d, got %d", name, test.expected, res.StatusCode)
+		}
+	}
+}
+
+// TestMessages checks fragmented messages are reassembled, pings interleaved with them are answered
+// and the closing handshake is completed.
+func TestMessages(t *testing.T) {
+	client, _ := dial(t, newTestServer(t, Endpoint{Handler: echo}), nil)
+	client.send(false, false, opText, []byte("hello, "))
+	client.send(true, false, opPing, []byte("are you there?"))
+	client.send(true, false, opContinuation, []byte("world"))
+
+	if _, opcode, payload := client.receive(); opcode != opPong || string(payload) != "are you there?" {
+		t.Errorf("Expected a pong echoing the ping, got %d %q", opcode, payload)
+	}
+	if _, opcode, payload := client.receive(); opcode != opText || string(payload) != "hello, world" {
+		t.Errorf("Expected the message to be echoed, got %d %q", opcode, payload)
+	}
+	client.send(true, false, opBinary, []byte{0, 1, 2})
+	if _, opcode, payload := client.receive(); opcode != opBinary || !bytes.Equal(payload, []byte{0, 1, 2}) {
+		t.Errorf("Expected the binary message to be echoed, got %d %v", opcode, payload)
+	}
+	client.send(true, false, opClose, append(closePayload(StatusGoingAway), "bye"...))
+	client.expectClose(StatusGoingAway)
+}
+
+// TestProtocolErrors checks clients breaking the protocol are disconnected with the right status code.
+func TestProtocolErrors(t *testing.T) {
+	ts := newTestServer(t, Endpoint{Handler: echo, MaxMessageSize: 16})
+	for name, test := range map[string]struct {
+		send     func(c *testClient)
+		expected StatusCode
+	}{
+		"unmasked frame": {func(c *testClient) { c.sendRaw(true, false, opText, []byte("hi"), false) }, StatusProtocolError},
+		"unknown opcode": {func(c *testClient) { c.send(true, false, 0x3, nil) }, StatusProtocolError},
+		"invalid text":   {func(c *testClient) { c.send(true, false, opText, []byte{0xff, 0xfe}) }, StatusInvalidPayloadData},
+		"too big":        {func(c *testClient) { c.send(true, false, opBinary, make([]byte, 17)) }, StatusMessageTooBig},
+		"too big fragments": {func(c *testClient) {
+			c.send(false, false, opBinary, make([]byte, 10))
+			c.send(true, false, opContinuation, make([]byte, 10))
+		}, StatusMessageTooBig},
+		"orphan continuation":    {func(c *testClient) { c.send(true, false, opContinuation, []byte("hi")) }, StatusProtocolError},
+		"fragmented ping":        {func(c *testClient) { c.send(false, false, opPing, nil) }, StatusProtocolError},
+		"uncompressed extension": {func(c *testClient) { c.send(true, true, opText, []byte("hi")) }, StatusProtocolError},
+		"invalid close code":     {func(c *testClient) { c.send(true, false, opClose, closePayload(1005)) }, StatusProtocolError},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, _ := dial(t, ts, nil)
+			test.send(client)
+			client.expectClose(test.expected)
+		})
+	}
+}
+
+// TestCompression checks compressed messages are decompressed, including ones referring to the previous
+// messages, and large messages are sent compressed.
+func TestCompression(t *testing.T) {
+	ts := newTestServer(t, Endpoint{Handler: echo, Compression: true})
+	client, res := dial(t, ts, map[string]string{"Sec-WebSocket-Extensions": "permessage-deflate; client_max_window_bits"})
+	if extension := res.Header.Get("Sec-WebSocket-Extensions"); extension != "permessage-deflate; server_no_context_takeover" {
+		t.Fatalf("Expected permessage-deflate to be negotiated, got %q", extension)
+	}
+
+	message := strings.Repeat("compress me, ", 20)
+	var buf bytes.Buffer
+	writer, _ := flate.NewWriter(&buf, flate.BestCompression)
+	for i := 0; i < 2; i++ {
+		buf.Reset()
+		writer.Write([]byte(message))
+		writer.Flush()
+		// The second message refers to the first one, as the client keeps its context.
+		client.send(true, true, opText, bytes.TrimSuffix(buf.Bytes(), []byte{0, 0, 0xff, 0xff}))
+
+		rsv1, opcode, payload := client.receive()
+		if !rsv1 || opcode != opText {
+			t.Fatalf("Expected a compressed text message, got %v %d", rsv1, opcode)
+		}
+		decompressed, err := io.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail))))
+		if err != nil || string(decompressed) != message {
+			t.Errorf("Expected the message to be echoed, got %q %v", decompressed, err)
+		}
+	}
+
+	_, res = dial(t, ts, map[string]string{"Sec-WebSocket-Extensions": "permessage-deflate; server_max_window_bits=10"})
+	if extension := res.Header.Get("Sec-WebSocket-Extensions"); extension != "" {
+		t.Errorf("Expected a reduced server window to be declined, got %q", extension)
+	}
+}
+
+// TestJSON checks handlers exchange typed messages and learn when the client leaves.
+func TestJSON(t *testing.T) {
+	type greeting struct {
+		Name string `json:"name"`
+	}
+	closed := make(chan StatusCode, 1)
+	ts := newTestServer(t, Endpoint{PingInterval: 20 * time.Millisecond, Handler: func(conn *Conn, r *http.Request) {
+		for {
+			var in greeting
+			if err := conn.ReadJSON(&in); err != nil {
+				closed <- CloseStatus(err)
+				return
+			}
+			conn.WriteJSON(map[string]string{"greeting": fmt.Sprintf("hello %s", in.Name)})
+		}
+	}})
+	client, _ := dial(t, ts, nil)
+	client.send(true, false, opText, []byte(`{"name": "Ada"}`))
+	for {
+		_, opcode, payload := client.receive()
+		if opcode == opPing {
+			// Idle clients are pinged.
+			continue
+		}
+		if string(payload) != `{"greeting":"hello Ada"}` {
+			t.Errorf("Expected a greeting, got %q", payload)
+		}
+		break
+	}
+	client.send(true, false, opClose, closePayload(StatusNormalClosure))
+	select {
+	case code := <-closed:
+		if code != StatusNormalClosure {
+			t.Errorf("Expected the handler to see a normal closure, got %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the handler to learn the client left")
+	}
+}
@@ -1 +1,236 @@
 package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/lucastomic/msBaseProj/internal/config"
+	"github.com/lucastomic/msBaseProj/internal/controller"
+	"github.com/lucastomic/msBaseProj/internal/errs"
+	"github.com/lucastomic/msBaseProj/internal/logging"
+	"github.com/lucastomic/msBaseProj/internal/middleware"
+	"github.com/lucastomic/msBaseProj/internal/translator"
+)
+
+// envPrefix prefixes the environment variables configuring the service, e.g. APP_SERVER_LISTEN.
+const envPrefix = "APP"
+
+// Exit codes of the commands.
+const (
+	exitOK      = 0 // exitOK means the command succeeded.
+	exitFailure = 1 // exitFailure means the command ran and failed, e.g. the config is invalid.
+	exitUsage   = 2 // exitUsage means the command was called wrongly.
+)
+
+const usage = `Usage: %[1]s <command> [config flags]
+
+Commands:
+  serve            start the server, reloading its config on SIGHUP or when its files change
+  routes           print the method, path and authentication requirement of every route
+  config validate  load and validate the config
+  i18n check       check every locale file translates the same keys, including the template's ones
+
+The config is loaded from the files given with -config or %[2]s_CONFIG, the %[2]s_* environment variables
+and the flags, e.g. -server.listen=:9000. Run '%[1]s config validate -h' to list them.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command given by args, writing its output to stdout and its errors to stderr,
+// and returns the exit code of the process.
+func run(args []string, stdout, stderr io.Writer) int {
+	command, args := subcommand(args)
+	switch command {
+	case "serve":
+		return serve(args, stderr)
+	case "routes":
+		return printRoutes(args, stdout, stderr)
+	case "config validate":
+		return validateConfig(args, stdout, stderr)
+	case "i18n check":
+		return checkTranslations(args, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprintf(stdout, usage, os.Args[0], envPrefix)
+		return exitOK
+	default:
+		if command != "" {
+			fmt.Fprintf(stderr, "unknown command %q\n", command)
+		}
+		fmt.Fprintf(stderr, usage, os.Args[0], envPrefix)
+		return exitUsage
+	}
+}
+
+// subcommand splits args into the command, made of one or two words, and its arguments.
+func subcommand(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if (args[0] == "config" || args[0] == "i18n") && len(args) > 1 {
+		return args[0] + " " + args[1], args[2:]
+	}
+	return args[0], args[1:]
+}
+
+// serve starts the server described by the config until the process receives SIGINT or SIGTERM.
+func serve(args []string, stderr io.Writer) int {
+	cfg, loader, code := loadConfig(args, stderr)
+	if code != exitOK {
+		return code
+	}
+	logger, err := cfg.NewLogger()
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to create the logger: %v\n", err)
+		return exitFailure
+	}
+	if err := cfg.LoadTranslations(); err != nil {
+		fmt.Fprintf(stderr, "failed to load the translations: %v\n", err)
+		return exitFailure
+	}
+	cfg.SetFeatures()
+	srv, err := cfg.NewServer(controllers(logger), logger, middlewares(logger), authMiddleware())
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to create the server: %v\n", err)
+		return exitFailure
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	newConfig := func() any {
+		cfg := config.Default()
+		return &cfg
+	}
+	go config.NewReloader(loader, newConfig, &cfg, &srv, logger).Watch(ctx)
+	if err := srv.Serve(ctx); err != nil {
+		logger.Error(context.Background(), "Failed to serve: %v", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// printRoutes prints the route table of the server described by the config.
+func printRoutes(args []string, stdout, stderr io.Writer) int {
+	cfg, _, code := loadConfig(args, stderr)
+	if code != exitOK {
+		return code
+	}
+	logger := logging.NewLogrusLogger()
+	srv, err := cfg.NewServer(controllers(logger), logger, middlewares(logger), authMiddleware())
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to create the server: %v\n", err)
+		return exitFailure
+	}
+	table := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "METHOD\tPATH\tAUTH")
+	for _, route := range srv.Routes() {
+		auth := "no"
+		if route.RequireAuth {
+			auth = "yes"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", route.Method, route.Pattern, auth)
+	}
+	if err := table.Flush(); err != nil {
+		fmt.Fprintf(stderr, "failed to print the routes: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// validateConfig loads and validates the config, reporting every problem found.
+func validateConfig(args []string, stdout, stderr io.Writer) int {
+	if _, _, code := loadConfig(args, stderr); code != exitOK {
+		return code
+	}
+	fmt.Fprintln(stdout, "The config is valid")
+	return exitOK
+}
+
+// checkTranslations checks the locale files of the config's languages can be loaded, translate every key
+// the template uses and have the same keys as the default language's one.
+func checkTranslations(args []string, stdout, stderr io.Writer) int {
+	cfg, _, code := loadConfig(args, stderr)
+	if code != exitOK {
+		return code
+	}
+	if err := cfg.LoadTranslations(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailure
+	}
+	languages := translator.Languages()
+	reference := languages[cfg.I18n.DefaultLang]
+	var problems []string
+	for _, lang := range cfg.I18n.Languages {
+		trans := languages[lang]
+		for _, key := range errs.Codes {
+			if trans[key] == "" {
+				problems = append(problems, fmt.Sprintf("%s: missing template key %q", lang, key))
+			}
+		}
+		for key := range reference {
+			if _, ok := trans[key]; !ok && !slices.Contains(errs.Codes, key) {
+				problems = append(problems, fmt.Sprintf("%s: missing key %q", lang, key))
+			}
+		}
+		for key := range trans {
+			if _, ok := reference[key]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: key %q isn't in the default language %s", lang, key, cfg.I18n.DefaultLang))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		for _, problem := range problems {
+			fmt.Fprintln(stderr, problem)
+		}
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "The translations of %d languages are complete\n", len(cfg.I18n.Languages))
+	return exitOK
+}
+
+// loadConfig loads the config with the given flags, returning the loader used so it can be reloaded.
+// It reports the problems found to stderr and returns the exit code of the command if it can't be loaded.
+func loadConfig(args []string, stderr io.Writer) (config.Config, config.Loader, int) {
+	loader := config.Loader{EnvPrefix: envPrefix, Args: args}
+	cfg := config.Default()
+	if err := loader.Load(&cfg); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return cfg, loader, exitUsage
+		}
+		fmt.Fprintln(stderr, err)
+		return cfg, loader, exitFailure
+	}
+	return cfg, loader, exitOK
+}
+
+// controllers returns the controllers served by the service. Services built on the template add theirs here.
+func controllers(logger logging.Logger) []controller.Controller {
+	return []controller.Controller{}
+}
+
+// middlewares returns the middlewares applied to every route, outermost first.
+func middlewares(logger logging.Logger) []middleware.Middleware {
+	return []middleware.Middleware{
+		middleware.NewRequestIDMiddleware(),
+		middleware.NewLoggingMiddleware(logger),
+		middleware.NewLangMiddleware(),
+		middleware.NewCompressionMiddleware(0),
+	}
+}
+
+// authMiddleware returns the middleware authenticating the routes which require it. It accepts any verified
+// client certificate, so those routes are rejected unless mutual TLS is enabled. Services replace it with
+// their own authentication.
+func authMiddleware() middleware.Middleware {
+	return middleware.NewMTLSAuthMiddleware()
+}
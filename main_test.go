@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRun checks the commands report their outcome through their output and exit code.
+func TestRun(t *testing.T) {
+	incomplete := t.TempDir()
+	for lang, content := range map[string]string{"en": `{"custom": "Custom"}`, "es": `{}`} {
+		if err := os.WriteFile(filepath.Join(incomplete, lang+".json"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, test := range map[string]struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		"routes":                {[]string{"routes"}, exitOK, "METHOD  PATH  AUTH"},
+		"valid config":          {[]string{"config", "validate", "-server.listen=:9000"}, exitOK, "The config is valid"},
+		"invalid config":        {[]string{"config", "validate", "-log.format=xml"}, exitFailure, `unknown format "xml"`},
+		"complete translations": {[]string{"i18n", "check", "-i18n.localesDir=locales"}, exitOK, "are complete"},
+		"incomplete translations": {
+			[]string{"i18n", "check", "-i18n.localesDir=" + incomplete}, exitFailure, `es: missing key "custom"`,
+		},
+		"unknown command": {[]string{"deploy"}, exitUsage, `unknown command "deploy"`},
+		"no command":      {nil, exitUsage, "Usage:"},
+	} {
+		var stdout, stderr bytes.Buffer
+		code := run(test.args, &stdout, &stderr)
+		output := stdout.String() + stderr.String()
+		if code != test.code || !strings.Contains(output, test.expected) {
+			t.Errorf("%s: expected exit code %d and output containing %q, got %d and\n%s", name, test.code, test.expected, code, output)
+		}
+	}
+}